@@ -1,408 +1,734 @@
-package auth
-
-import (
-	"crypto/rsa"
-	"encoding/base64"
-	"encoding/json"
-	"errors"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/golang-jwt/jwt/v4"
-	"github.com/google/wire"
-	"github.com/labstack/echo/v4"
-	"github.com/rs/zerolog"
-
-	"github.com/togls/gowarden/config"
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/pkg/crypto"
-	"github.com/togls/gowarden/store"
-)
-
-var WireSet = wire.NewSet(
-	New,
-
-	wire.Bind(new(Authenticator), new(*Core)),
-	wire.Bind(new(JWTDecoder), new(*Core)),
-)
-
-type Authenticator interface {
-	RefreshLogin(token string) (*RespRefreshToken, error)
-	PasswordLogin(cd *ConnectData) (*RespRefreshToken, error)
-}
-
-type JWTDecoder interface {
-	DecodeToken(token string, claims jwt.Claims) error
-}
-
-type Core struct {
-	logger *zerolog.Logger
-
-	devices store.Device
-	users   store.User
-	uos     store.UserOrganization
-	ucs     store.UserCollection
-
-	validity time.Duration
-
-	sm jwt.SigningMethod
-
-	mailEnabled bool
-	priKey      *rsa.PrivateKey
-	pubKey      *rsa.PublicKey
-}
-
-var _ Authenticator = (*Core)(nil)
-
-var _ JWTDecoder = (*Core)(nil)
-
-func New(
-	cfg *config.Core,
-	d store.Device,
-	u store.User,
-	uos store.UserOrganization,
-	ucs store.UserCollection,
-) *Core {
-	return &Core{
-		priKey:      cfg.PriKey,
-		pubKey:      cfg.PubKey,
-		mailEnabled: false,
-		logger:      cfg.Logger,
-
-		devices: d,
-		users:   u,
-		uos:     uos,
-		ucs:     ucs,
-
-		validity: time.Hour * 2,
-		sm:       jwt.GetSigningMethod("RS256"),
-	}
-}
-
-func (core Core) RefreshLogin(token string) (*RespRefreshToken, error) {
-	d, err := core.devices.FindByRefreshToken(token)
-	if err != nil {
-		core.logger.Debug().Err(err).Msg("device not found")
-		return nil, err
-	}
-
-	u, err := core.users.FindByUuid(d.UserUuid)
-	if err != nil {
-		core.logger.Debug().Err(err).Msg("user not found")
-		return nil, err
-	}
-
-	accessToken, err := core.refreshToken(u, d)
-	if err != nil {
-		core.logger.Debug().Err(err).Msg("refresh token")
-		return nil, err
-	}
-
-	err = core.devices.Save(d)
-	if err != nil {
-		core.logger.Debug().Err(err).Msg("save device")
-		return nil, err
-	}
-
-	return &RespRefreshToken{
-		AccessToken:  accessToken,
-		ExpiresIn:    core.validity.Seconds(),
-		TokenType:    "Bearer",
-		RefreshToken: d.RefreshToken,
-		Key:          u.Akey,
-		PrivateKey:   u.PrivateKey,
-
-		Kdf:                 u.ClientKdfType,
-		KdfIterations:       u.ClientKdfIter,
-		ResetMasterPassword: false,
-		Scope:               "api offline_access",
-		UnofficialServer:    true,
-	}, nil
-}
-
-func (core Core) PasswordLogin(cd *ConnectData) (*RespRefreshToken, error) {
-	if cd.Scope != "api offline_access" {
-		return nil, ErrScopeNotSupported
-	}
-
-	u, err := core.users.FindByEmail(cd.Username)
-	if err != nil {
-		core.logger.Info().
-			Err(err).
-			Str("email", cd.Username).
-			Msg("user not found")
-		return nil, echo.NewHTTPError(http.StatusUnauthorized,
-			"invalid username or password")
-	}
-
-	ok := crypto.VerifyPassword(
-		cd.Password,
-		u.Salt,
-		u.PasswordHash,
-		u.PasswordIterations,
-	)
-	if !ok {
-		core.logger.Info().
-			Str("password", cd.Password).
-			Str("email", cd.Username).
-			Msg("password mismatch")
-		return nil, echo.NewHTTPError(http.StatusUnauthorized,
-			"Username or password is incorrect. Try again")
-	}
-
-	if !u.Enabled {
-		core.logger.Info().Str("email", cd.Username).Msg("user disabled")
-		return nil, ErrUserDisabled
-	}
-
-	if core.mailEnabled && u.VerifiedAt == nil {
-		core.logger.Info().Str("email", cd.Username).Msg("user not verified")
-		return nil, echo.NewHTTPError(http.StatusUnauthorized, "User is not verified")
-	}
-
-	d, err := core.devices.FindByUuid(cd.DeviceIdentifier)
-	if err != nil || d.UserUuid != u.Uuid {
-		// create new device
-		t, _ := strconv.Atoi(cd.DeviceType)
-		newDevice := &model.Device{
-			Uuid:     cd.DeviceIdentifier,
-			UserUuid: u.Uuid,
-			Name:     cd.DeviceName,
-			Atype:    t,
-
-			CreatedAt: time.Now(),
-		}
-
-		d = newDevice
-
-	}
-	// TODO: send mail
-
-	// TODO: twofactor_auth
-
-	accessToken, err := core.refreshToken(u, d)
-	if err != nil {
-		core.logger.Debug().Err(err).Msg("refresh token")
-		return nil, err
-	}
-
-	d.UpdatedAt = time.Now()
-	if err := core.devices.Save(d); err != nil {
-		core.logger.Debug().Err(err).Str("device uuid", d.Uuid).Msg("save device")
-		return nil, err
-	}
-
-	return &RespRefreshToken{
-		AccessToken:  accessToken,
-		ExpiresIn:    core.validity.Seconds(),
-		TokenType:    "Bearer",
-		RefreshToken: d.RefreshToken,
-		Key:          u.Akey,
-		PrivateKey:   u.PrivateKey,
-
-		Kdf:                 u.ClientKdfType,
-		KdfIterations:       u.ClientKdfIter,
-		ResetMasterPassword: false,
-		Scope:               "api offline_access",
-		UnofficialServer:    true,
-	}, nil
-}
-
-func (core Core) refreshToken(u *model.User, d *model.Device) (string, error) {
-	if d.RefreshToken == "" {
-		src, err := crypto.GenerateBytes(64)
-		if err != nil {
-			return "", err
-		}
-
-		dst := make([]byte, base64.StdEncoding.EncodedLen(len(src)))
-		base64.StdEncoding.Encode(dst, src)
-
-		d.RefreshToken = string(dst)
-	}
-
-	confirmed := model.UOStatusConfirmed
-	filter := &model.UOFilter{UserUuid: &u.Uuid, Status: &confirmed}
-	userOrgs, err := core.uos.Find(filter)
-	if err != nil {
-		return "", err
-	}
-
-	orgowner := make([]string, 0)
-	orgadmin := make([]string, 0)
-	orguser := make([]string, 0)
-	orgmanager := make([]string, 0)
-	for _, uo := range userOrgs {
-		switch uo.Atype {
-		case model.UOTypeOwner:
-			orgowner = append(orgowner, uo.OrgUuid)
-		case model.UOTypeAdmin:
-			orgadmin = append(orgadmin, uo.OrgUuid)
-		case model.UOTypeUser:
-			orguser = append(orguser, uo.OrgUuid)
-		case model.UOTypeManager:
-			orgmanager = append(orgmanager, uo.OrgUuid)
-		}
-	}
-
-	now := time.Now()
-
-	claims := &LoginJwtClaims{
-		RegisteredClaims: &jwt.RegisteredClaims{
-			NotBefore: jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(core.validity)),
-			Issuer:    "|login",
-			Subject:   u.Uuid,
-		},
-
-		Orgowner:   orgowner,
-		Orgadmin:   orgadmin,
-		Orguser:    orguser,
-		Orgmanager: orgmanager,
-
-		Device:        d.Uuid,
-		Name:          u.Name,
-		Email:         u.Email,
-		EmailVerified: (!core.mailEnabled || u.VerifiedAt != nil),
-
-		Sstamp:  u.SecurityStamp,
-		Premium: true,
-		Scope:   []string{"api", "offline_access"},
-		Amr:     []string{"Application"},
-	}
-
-	if !core.mailEnabled || u.VerifiedAt != nil {
-		claims.EmailVerified = true
-	}
-
-	t := jwt.New(core.sm)
-	t.Claims = claims
-	accessToken, err := t.SignedString(core.priKey)
-	if err != nil {
-		return "", err
-	}
-
-	d.UpdatedAt = now
-	return accessToken, nil
-}
-
-func (core Core) DecodeToken(token string, claims jwt.Claims) error {
-	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
-		return core.pubKey, nil
-	})
-	if err != nil {
-		return err
-	}
-
-	if !t.Valid {
-		return errors.New("invalid claim")
-	}
-
-	return nil
-}
-
-type GrantType int
-
-const (
-	GTRefreshToken GrantType = iota
-	GTPassword
-	ClientCredentials
-)
-
-func (gt *GrantType) UnmarshalParam(param string) error {
-	switch param {
-	case "refresh_token":
-		*gt = GTRefreshToken
-	case "password":
-		*gt = GTPassword
-	case "client_credentials":
-		*gt = ClientCredentials
-	default:
-		return errors.New("invalid grant_type")
-	}
-
-	return nil
-}
-
-func (gt *GrantType) UnmarshalJSON(data []byte) error {
-	var s string
-	if err := json.Unmarshal(data, &s); err != nil {
-		return err
-	}
-
-	switch s {
-	case "refresh_token":
-		*gt = GTRefreshToken
-	case "password":
-		*gt = GTPassword
-	case "client_credentials":
-		*gt = ClientCredentials
-	default:
-		return errors.New("UnmarshalJSON: unknown grant type")
-	}
-
-	return nil
-}
-
-type ConnectData struct {
-	GrantType GrantType `form:"grant_type"`
-
-	RefreshToken string `form:"refresh_token"`
-
-	// Needed for password auth
-	ClientID string `form:"client_id"`
-	Password string `form:"password"`
-	Scope    string `form:"scope"`
-	Username string `form:"username"`
-
-	DeviceIdentifier string `form:"deviceIdentifier"`
-	DeviceName       string `form:"deviceName"`
-	DeviceType       string `form:"deviceType"`
-	DevicePushToken  string `form:"devicePushToken"`
-
-	// Needed for two-factor auth
-	TwoFactorProvider int32
-	TwoFactorToken    string
-	TwoFactorRemember int32
-}
-
-func (cd ConnectData) Validate() error {
-	if cd.GrantType == GTRefreshToken {
-		if cd.RefreshToken == "" {
-			return errors.New("refresh_token is required")
-		}
-
-		return nil
-	}
-
-	if cd.ClientID == "" {
-		return errors.New("client_id is required")
-	}
-
-	if cd.Password == "" {
-		return errors.New("password is required")
-	}
-
-	if cd.Scope == "" {
-		return errors.New("scope is required")
-	}
-
-	if cd.Username == "" {
-		return errors.New("username is required")
-	}
-
-	if cd.DeviceIdentifier == "" {
-		return errors.New("device_identifier is required")
-	}
-
-	if cd.DeviceName == "" {
-		return errors.New("device_name is required")
-	}
-
-	if cd.DeviceType == "" {
-		return errors.New("device_type is required")
-	}
-
-	return nil
-}
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/wire"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+var WireSet = wire.NewSet(
+	New,
+
+	wire.Bind(new(Authenticator), new(*Core)),
+	wire.Bind(new(JWTDecoder), new(*Core)),
+)
+
+type Authenticator interface {
+	RefreshLogin(ctx context.Context, token string) (*RespRefreshToken, error)
+	PasswordLogin(ctx context.Context, cd *ConnectData) (*RespRefreshToken, error)
+	ClientCredentialsLogin(ctx context.Context, cd *ConnectData) (*RespAccessToken, error)
+}
+
+type JWTDecoder interface {
+	DecodeToken(token string, claims jwt.Claims) error
+}
+
+type Core struct {
+	logger *zerolog.Logger
+
+	devices store.Device
+	users   store.User
+	uos     store.UserOrganization
+	ucs     store.UserCollection
+	ops     store.OrgPolicy
+	events  store.Event
+	apiKeys store.OrgApiKey
+
+	validity time.Duration
+
+	sm jwt.SigningMethod
+
+	mailEnabled bool
+	priKey      *rsa.PrivateKey
+	pubKey      *rsa.PublicKey
+}
+
+var _ Authenticator = (*Core)(nil)
+
+var _ JWTDecoder = (*Core)(nil)
+
+func New(
+	cfg *config.Core,
+	d store.Device,
+	u store.User,
+	uos store.UserOrganization,
+	ucs store.UserCollection,
+	ops store.OrgPolicy,
+	events store.Event,
+	apiKeys store.OrgApiKey,
+) *Core {
+	return &Core{
+		priKey:      cfg.PriKey,
+		pubKey:      cfg.PubKey,
+		mailEnabled: false,
+		logger:      cfg.Logger,
+
+		devices: d,
+		users:   u,
+		uos:     uos,
+		ucs:     ucs,
+		ops:     ops,
+		events:  events,
+		apiKeys: apiKeys,
+
+		validity: time.Hour * 2,
+		sm:       jwt.GetSigningMethod("RS256"),
+	}
+}
+
+func (core Core) RefreshLogin(ctx context.Context, token string) (*RespRefreshToken, error) {
+	d, err := core.devices.FindByRefreshToken(ctx, token)
+	if err != nil {
+		core.logger.Debug().Err(err).Msg("device not found")
+		return nil, err
+	}
+
+	u, err := core.users.FindByUuid(ctx, d.UserUuid)
+	if err != nil {
+		core.logger.Debug().Err(err).Msg("user not found")
+		return nil, err
+	}
+
+	accessToken, err := core.refreshToken(ctx, u, d)
+	if err != nil {
+		core.logger.Debug().Err(err).Msg("refresh token")
+		return nil, err
+	}
+
+	err = core.devices.Save(ctx, d)
+	if err != nil {
+		core.logger.Debug().Err(err).Msg("save device")
+		return nil, err
+	}
+
+	return &RespRefreshToken{
+		AccessToken:  accessToken,
+		ExpiresIn:    core.validity.Seconds(),
+		TokenType:    "Bearer",
+		RefreshToken: d.RefreshToken,
+		Key:          u.Akey,
+		PrivateKey:   u.PrivateKey,
+
+		Kdf:                 u.ClientKdfType,
+		KdfIterations:       u.ClientKdfIter,
+		ResetMasterPassword: false,
+		Scope:               "api offline_access",
+		UnofficialServer:    true,
+	}, nil
+}
+
+func (core Core) PasswordLogin(ctx context.Context, cd *ConnectData) (*RespRefreshToken, error) {
+	if cd.Scope != "api offline_access" {
+		return nil, ErrScopeNotSupported
+	}
+
+	u, err := core.users.FindByEmail(ctx, cd.Username)
+	if err != nil {
+		core.logger.Info().
+			Err(err).
+			Str("email", cd.Username).
+			Msg("user not found")
+		return nil, echo.NewHTTPError(http.StatusUnauthorized,
+			"invalid username or password")
+	}
+
+	ok := crypto.VerifyPassword(
+		cd.Password,
+		u.Salt,
+		u.PasswordHash,
+		u.PasswordIterations,
+	)
+	if !ok {
+		core.logger.Info().
+			Str("password", cd.Password).
+			Str("email", cd.Username).
+			Msg("password mismatch")
+		core.logUserEvent(ctx, model.EventUserFailedLogIn, u.Uuid, cd)
+		return nil, echo.NewHTTPError(http.StatusUnauthorized,
+			"Username or password is incorrect. Try again")
+	}
+
+	if !u.Enabled {
+		core.logger.Info().Str("email", cd.Username).Msg("user disabled")
+		return nil, ErrUserDisabled
+	}
+
+	if core.mailEnabled && u.VerifiedAt == nil {
+		core.logger.Info().Str("email", cd.Username).Msg("user not verified")
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "User is not verified")
+	}
+
+	d, err := core.devices.FindByUuid(ctx, cd.DeviceIdentifier)
+	if err != nil || d.UserUuid != u.Uuid {
+		// create new device
+		t, _ := strconv.Atoi(cd.DeviceType)
+		newDevice := &model.Device{
+			Uuid:     cd.DeviceIdentifier,
+			UserUuid: u.Uuid,
+			Name:     cd.DeviceName,
+			Atype:    t,
+
+			CreatedAt: time.Now(),
+		}
+
+		d = newDevice
+
+	}
+	// TODO: send mail
+
+	// TODO: twofactor_auth
+
+	accessToken, err := core.refreshToken(ctx, u, d)
+	if err != nil {
+		core.logger.Debug().Err(err).Msg("refresh token")
+		return nil, err
+	}
+
+	d.UpdatedAt = time.Now()
+	if err := core.devices.Save(ctx, d); err != nil {
+		core.logger.Debug().Err(err).Str("device uuid", d.Uuid).Msg("save device")
+		return nil, err
+	}
+
+	mpp, err := core.masterPasswordPolicy(ctx, u.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	core.logUserEvent(ctx, model.EventUserLoggedIn, u.Uuid, cd)
+
+	return &RespRefreshToken{
+		AccessToken:  accessToken,
+		ExpiresIn:    core.validity.Seconds(),
+		TokenType:    "Bearer",
+		RefreshToken: d.RefreshToken,
+		Key:          u.Akey,
+		PrivateKey:   u.PrivateKey,
+
+		Kdf:                  u.ClientKdfType,
+		KdfIterations:        u.ClientKdfIter,
+		ResetMasterPassword:  false,
+		MasterPasswordPolicy: mpp,
+		Scope:                "api offline_access",
+		UnofficialServer:     true,
+	}, nil
+}
+
+// orgClientPrefix prefixes the organization uuid in the client id of the
+// organization API key.
+const orgClientPrefix = "organization."
+
+// ClientCredentialsLogin logs in the client of an organization API key,
+// the client id is organization.<uuid> and the secret the API key.
+func (core Core) ClientCredentialsLogin(ctx context.Context, cd *ConnectData) (*RespAccessToken, error) {
+	if cd.Scope != "api.organization" || !strings.HasPrefix(cd.ClientID, orgClientPrefix) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, ErrScopeNotSupported.Error())
+	}
+
+	oUuid := strings.TrimPrefix(cd.ClientID, orgClientPrefix)
+
+	key, err := core.apiKeys.FindByOrgAndType(ctx, oUuid, model.OrgApiKeyTypeDefault)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+
+	if key == nil || subtle.ConstantTimeCompare([]byte(cd.ClientSecret), []byte(key.ApiKey)) != 1 {
+		core.logger.Info().Str("client id", cd.ClientID).Msg("invalid client credentials")
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "Invalid client credentials")
+	}
+
+	now := time.Now()
+
+	claims := &OrgApiClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(core.validity)),
+			Issuer:    IssuerOrgApi,
+			Subject:   oUuid,
+		},
+		ClientId: cd.ClientID,
+		Scope:    []string{"api.organization"},
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+	accessToken, err := t.SignedString(core.priKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RespAccessToken{
+		AccessToken: accessToken,
+		ExpiresIn:   core.validity.Seconds(),
+		TokenType:   "Bearer",
+		Scope:       "api.organization",
+	}, nil
+}
+
+// logUserEvent records the login event atype in the event log of every
+// organization the user is a confirmed member of. A failure is only logged,
+// it must not decide the login.
+func (core Core) logUserEvent(ctx context.Context, atype model.EventType, uUuid string, cd *ConnectData) {
+	confirmed := model.UOStatusConfirmed
+	uos, err := core.uos.Find(ctx, &model.UOFilter{UserUuid: &uUuid, Status: &confirmed})
+	if err != nil {
+		core.logger.Error().Err(err).Str("user uuid", uUuid).Msg("find user organizations")
+		return
+	}
+
+	deviceType, _ := strconv.Atoi(cd.DeviceType)
+	now := time.Now()
+
+	for _, uo := range uos {
+		eUuid, err := crypto.GenerateUuid()
+		if err != nil {
+			core.logger.Error().Err(err).Msg("generate event uuid")
+			return
+		}
+
+		event := &model.Event{
+			Uuid:        eUuid,
+			Atype:       atype,
+			UserUuid:    &uUuid,
+			OrgUuid:     &uo.OrgUuid,
+			OrgUserUuid: &uo.Uuid,
+			ActUserUuid: &uUuid,
+			DeviceType:  &deviceType,
+			IpAddress:   &cd.IpAddress,
+			EventDate:   now,
+		}
+
+		if err := core.events.Create(ctx, event); err != nil {
+			core.logger.Error().Err(err).Str("user uuid", uUuid).Msg("create event")
+		}
+	}
+}
+
+// masterPasswordPolicy merges the MasterPassword policies binding the user,
+// nil when there are none.
+func (core Core) masterPasswordPolicy(ctx context.Context, uUuid string) (*MasterPasswordPolicy, error) {
+	policies, err := core.ops.FindBindingByUser(ctx, uUuid, model.OPTypeMasterPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	merged := new(model.MasterPasswordPolicy)
+	for _, policy := range policies {
+		p, err := policy.MasterPassword()
+		if err != nil {
+			core.logger.Warn().Err(err).Str("policy", policy.Uuid).Msg("invalid master password policy")
+			continue
+		}
+
+		merged.Merge(p)
+	}
+
+	return &MasterPasswordPolicy{
+		MinComplexity:  merged.MinComplexity,
+		MinLength:      merged.MinLength,
+		RequireUpper:   merged.RequireUpper,
+		RequireLower:   merged.RequireLower,
+		RequireNumbers: merged.RequireNumbers,
+		RequireSpecial: merged.RequireSpecial,
+		EnforceOnLogin: merged.EnforceOnLogin != nil && *merged.EnforceOnLogin,
+		Object:         "masterPasswordPolicy",
+	}, nil
+}
+
+func (core Core) refreshToken(ctx context.Context, u *model.User, d *model.Device) (string, error) {
+	if d.RefreshToken == "" {
+		src, err := crypto.GenerateBytes(64)
+		if err != nil {
+			return "", err
+		}
+
+		dst := make([]byte, base64.StdEncoding.EncodedLen(len(src)))
+		base64.StdEncoding.Encode(dst, src)
+
+		d.RefreshToken = string(dst)
+	}
+
+	confirmed := model.UOStatusConfirmed
+	filter := &model.UOFilter{UserUuid: &u.Uuid, Status: &confirmed}
+	userOrgs, err := core.uos.Find(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+
+	orgowner := make([]string, 0)
+	orgadmin := make([]string, 0)
+	orguser := make([]string, 0)
+	orgmanager := make([]string, 0)
+	for _, uo := range userOrgs {
+		switch uo.Atype {
+		case model.UOTypeOwner:
+			orgowner = append(orgowner, uo.OrgUuid)
+		case model.UOTypeAdmin:
+			orgadmin = append(orgadmin, uo.OrgUuid)
+		case model.UOTypeUser:
+			orguser = append(orguser, uo.OrgUuid)
+		case model.UOTypeManager:
+			orgmanager = append(orgmanager, uo.OrgUuid)
+		}
+	}
+
+	now := time.Now()
+
+	claims := &LoginJwtClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(core.validity)),
+			Issuer:    IssuerLogin,
+			Subject:   u.Uuid,
+		},
+
+		Orgowner:   orgowner,
+		Orgadmin:   orgadmin,
+		Orguser:    orguser,
+		Orgmanager: orgmanager,
+
+		Device:        d.Uuid,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: (!core.mailEnabled || u.VerifiedAt != nil),
+
+		Sstamp:  u.SecurityStamp,
+		Premium: true,
+		Scope:   []string{"api", "offline_access"},
+		Amr:     []string{"Application"},
+	}
+
+	if !core.mailEnabled || u.VerifiedAt != nil {
+		claims.EmailVerified = true
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+	accessToken, err := t.SignedString(core.priKey)
+	if err != nil {
+		return "", err
+	}
+
+	d.UpdatedAt = now
+	return accessToken, nil
+}
+
+// fileDownloadValidity is how long a signed attachment url stays usable.
+const fileDownloadValidity = 5 * time.Minute
+
+func (core Core) EncodeFileDownload(cipher, file string) (string, error) {
+	now := time.Now()
+
+	claims := &FileDownloadClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(fileDownloadValidity)),
+			Issuer:    IssuerFileDownload,
+			Subject:   cipher,
+		},
+		FileId: file,
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+
+	return t.SignedString(core.priKey)
+}
+
+// DecodeFileDownload checks that token grants access to the file of the cipher.
+func (core Core) DecodeFileDownload(token, cipher, file string) error {
+	claims := new(FileDownloadClaims)
+	if err := core.DecodeToken(token, claims); err != nil {
+		return err
+	}
+
+	if claims.Issuer != IssuerFileDownload {
+		return errors.New("invalid token issuer")
+	}
+
+	if claims.Subject != cipher || claims.FileId != file {
+		return errors.New("token doesn't match the file")
+	}
+
+	return nil
+}
+
+// sendDownloadValidity is how long a send file download url stays usable.
+const sendDownloadValidity = 2 * time.Minute
+
+// EncodeSendDownload signs a download url for a send file. Every token gets
+// its own id, so it can be spent once.
+func (core Core) EncodeSendDownload(send, file string) (string, error) {
+	now := time.Now()
+
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &FileDownloadClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			ID:        id,
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(sendDownloadValidity)),
+			Issuer:    IssuerSendDownload,
+			Subject:   send,
+		},
+		FileId: file,
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+
+	return t.SignedString(core.priKey)
+}
+
+// DecodeSendDownload checks that token grants access to the file of the send.
+func (core Core) DecodeSendDownload(token, send, file string) (*FileDownloadClaims, error) {
+	claims := new(FileDownloadClaims)
+	if err := core.DecodeToken(token, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != IssuerSendDownload {
+		return nil, errors.New("invalid token issuer")
+	}
+
+	if claims.Subject != send || claims.FileId != file || claims.ID == "" {
+		return nil, errors.New("token doesn't match the file")
+	}
+
+	return claims, nil
+}
+
+// inviteValidity is how long organization and emergency contact invites
+// are valid.
+const inviteValidity = 5 * 24 * time.Hour
+
+func (core Core) EncodeInvite(uo *model.UserOrganization, email, invitedBy string) (string, error) {
+	now := time.Now()
+
+	claims := &InviteClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(inviteValidity)),
+			Issuer:    IssuerInvite,
+			Subject:   email,
+		},
+		OrgId:          uo.OrgUuid,
+		UserOrgId:      uo.Uuid,
+		InvitedByEmail: invitedBy,
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+
+	return t.SignedString(core.priKey)
+}
+
+func (core Core) DecodeInvite(token string) (*InviteClaims, error) {
+	claims := new(InviteClaims)
+	if err := core.DecodeToken(token, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != IssuerInvite {
+		return nil, errors.New("invalid token issuer")
+	}
+
+	return claims, nil
+}
+
+func (core Core) EncodeEmergencyInvite(ea *model.EmergencyAccess, email string, grantor *model.User) (string, error) {
+	now := time.Now()
+
+	claims := &EmergencyInviteClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(inviteValidity)),
+			Issuer:    IssuerEmergencyInvite,
+			Subject:   email,
+		},
+		EmerId:       ea.Uuid,
+		GrantorName:  grantor.Name,
+		GrantorEmail: grantor.Email,
+	}
+
+	t := jwt.New(core.sm)
+	t.Claims = claims
+
+	return t.SignedString(core.priKey)
+}
+
+func (core Core) DecodeEmergencyInvite(token string) (*EmergencyInviteClaims, error) {
+	claims := new(EmergencyInviteClaims)
+	if err := core.DecodeToken(token, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Issuer != IssuerEmergencyInvite {
+		return nil, errors.New("invalid token issuer")
+	}
+
+	return claims, nil
+}
+
+func (core Core) DecodeToken(token string, claims jwt.Claims) error {
+	t, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return core.pubKey, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !t.Valid {
+		return errors.New("invalid claim")
+	}
+
+	return nil
+}
+
+type GrantType int
+
+const (
+	GTRefreshToken GrantType = iota
+	GTPassword
+	ClientCredentials
+)
+
+func (gt *GrantType) UnmarshalParam(param string) error {
+	switch param {
+	case "refresh_token":
+		*gt = GTRefreshToken
+	case "password":
+		*gt = GTPassword
+	case "client_credentials":
+		*gt = ClientCredentials
+	default:
+		return errors.New("invalid grant_type")
+	}
+
+	return nil
+}
+
+func (gt *GrantType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	switch s {
+	case "refresh_token":
+		*gt = GTRefreshToken
+	case "password":
+		*gt = GTPassword
+	case "client_credentials":
+		*gt = ClientCredentials
+	default:
+		return errors.New("UnmarshalJSON: unknown grant type")
+	}
+
+	return nil
+}
+
+type ConnectData struct {
+	GrantType GrantType `form:"grant_type"`
+
+	RefreshToken string `form:"refresh_token"`
+
+	// Needed for password auth
+	ClientID string `form:"client_id"`
+	Password string `form:"password"`
+	Scope    string `form:"scope"`
+	Username string `form:"username"`
+
+	// Needed for client credentials, the secret is the API key
+	ClientSecret string `form:"client_secret"`
+
+	DeviceIdentifier string `form:"deviceIdentifier"`
+	DeviceName       string `form:"deviceName"`
+	DeviceType       string `form:"deviceType"`
+	DevicePushToken  string `form:"devicePushToken"`
+
+	// IpAddress is the client address, set by the handler
+	IpAddress string `form:"-"`
+
+	// Needed for two-factor auth
+	TwoFactorProvider int32
+	TwoFactorToken    string
+	TwoFactorRemember int32
+}
+
+func (cd ConnectData) Validate() error {
+	if cd.GrantType == GTRefreshToken {
+		if cd.RefreshToken == "" {
+			return errors.New("refresh_token is required")
+		}
+
+		return nil
+	}
+
+	if cd.ClientID == "" {
+		return errors.New("client_id is required")
+	}
+
+	if cd.GrantType == ClientCredentials {
+		if cd.ClientSecret == "" {
+			return errors.New("client_secret is required")
+		}
+
+		if cd.Scope == "" {
+			return errors.New("scope is required")
+		}
+
+		return nil
+	}
+
+	if cd.Password == "" {
+		return errors.New("password is required")
+	}
+
+	if cd.Scope == "" {
+		return errors.New("scope is required")
+	}
+
+	if cd.Username == "" {
+		return errors.New("username is required")
+	}
+
+	if cd.DeviceIdentifier == "" {
+		return errors.New("device_identifier is required")
+	}
+
+	if cd.DeviceName == "" {
+		return errors.New("device_name is required")
+	}
+
+	if cd.DeviceType == "" {
+		return errors.New("device_type is required")
+	}
+
+	return nil
+}
@@ -1,28 +1,74 @@
-package auth
-
-import (
-	"github.com/golang-jwt/jwt/v4"
-)
-
-type LoginJwtClaims struct {
-	// NotBefore int64  `json:"nbf"` // Not before
-	// ExpiresAt int64  `json:"exp"` // Expiration time
-	// Issuer    string `json:"iss"` // Issuer
-	// Subject   string `json:"sub"` // Subject
-	*jwt.RegisteredClaims
-
-	Device        string `json:"device"` // device uuid
-	Email         string `json:"email"`
-	EmailVerified bool   `json:"email_verified"`
-	Name          string `json:"name"`
-
-	Orgowner   []string `json:"orgowner"`
-	Orgadmin   []string `json:"orgadmin"`
-	Orguser    []string `json:"orguser"`
-	Orgmanager []string `json:"orgmanager"`
-
-	Premium bool     `json:"premium"`
-	Sstamp  string   `json:"sstamp"` // user security_stamp
-	Scope   []string `json:"scope"`  // [ "api", "offline_access" ]
-	Amr     []string `json:"amr"`    // [ "Application" ]
-}
+package auth
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	IssuerLogin        = "|login"
+	IssuerFileDownload = "|file_download"
+	IssuerSendDownload = "|send_download"
+	IssuerOrgApi       = "|api.organization"
+
+	IssuerInvite          = "|invite"
+	IssuerEmergencyInvite = "|emergencyaccessinvite"
+)
+
+type LoginJwtClaims struct {
+	// NotBefore int64  `json:"nbf"` // Not before
+	// ExpiresAt int64  `json:"exp"` // Expiration time
+	// Issuer    string `json:"iss"` // Issuer
+	// Subject   string `json:"sub"` // Subject
+	*jwt.RegisteredClaims
+
+	Device        string `json:"device"` // device uuid
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+
+	Orgowner   []string `json:"orgowner"`
+	Orgadmin   []string `json:"orgadmin"`
+	Orguser    []string `json:"orguser"`
+	Orgmanager []string `json:"orgmanager"`
+
+	Premium bool     `json:"premium"`
+	Sstamp  string   `json:"sstamp"` // user security_stamp
+	Scope   []string `json:"scope"`  // [ "api", "offline_access" ]
+	Amr     []string `json:"amr"`    // [ "Application" ]
+}
+
+// OrgApiClaims authenticates a client of the public API acting for the
+// subject organization.
+type OrgApiClaims struct {
+	*jwt.RegisteredClaims
+
+	ClientId string   `json:"client_id"`
+	Scope    []string `json:"scope"`
+}
+
+// FileDownloadClaims grants access to a single attachment or send file, the
+// subject is the cipher or send uuid.
+type FileDownloadClaims struct {
+	*jwt.RegisteredClaims
+
+	FileId string `json:"file_id"`
+}
+
+// InviteClaims invites the subject email into an organization, tied to the
+// membership created for it.
+type InviteClaims struct {
+	*jwt.RegisteredClaims
+
+	OrgId          string `json:"org_id"`
+	UserOrgId      string `json:"user_org_id"`
+	InvitedByEmail string `json:"invited_by_email"`
+}
+
+// EmergencyInviteClaims invites the subject email as emergency contact.
+type EmergencyInviteClaims struct {
+	*jwt.RegisteredClaims
+
+	EmerId       string `json:"emer_id"`
+	GrantorName  string `json:"grantor_name"`
+	GrantorEmail string `json:"grantor_email"`
+}
@@ -1,17 +1,40 @@
-package auth
-
-type RespRefreshToken struct {
-	AccessToken  string  `json:"access_token"`
-	ExpiresIn    float64 `json:"expires_in"`
-	TokenType    string  `json:"token_type"`
-	RefreshToken string  `json:"refresh_token"`
-	Key          *string `json:"Key"`
-	PrivateKey   *string `json:"PrivateKey"`
-
-	Kdf              int    `json:"Kdf"`
-	KdfIterations    int    `json:"KdfIterations"`
-	Scope            string `json:"scope"`
-	UnofficialServer bool   `json:"unofficialServer"`
-
-	ResetMasterPassword bool `json:"ResetMasterPassword"`
-}
+package auth
+
+type RespRefreshToken struct {
+	AccessToken  string  `json:"access_token"`
+	ExpiresIn    float64 `json:"expires_in"`
+	TokenType    string  `json:"token_type"`
+	RefreshToken string  `json:"refresh_token"`
+	Key          *string `json:"Key"`
+	PrivateKey   *string `json:"PrivateKey"`
+
+	Kdf              int    `json:"Kdf"`
+	KdfIterations    int    `json:"KdfIterations"`
+	Scope            string `json:"scope"`
+	UnofficialServer bool   `json:"unofficialServer"`
+
+	ResetMasterPassword  bool                  `json:"ResetMasterPassword"`
+	MasterPasswordPolicy *MasterPasswordPolicy `json:"MasterPasswordPolicy,omitempty"`
+}
+
+// MasterPasswordPolicy tells the client the master password requirements it
+// has to check, the server only ever sees the password hash.
+type MasterPasswordPolicy struct {
+	MinComplexity  *int   `json:"MinComplexity"`
+	MinLength      *int   `json:"MinLength"`
+	RequireUpper   bool   `json:"RequireUpper"`
+	RequireLower   bool   `json:"RequireLower"`
+	RequireNumbers bool   `json:"RequireNumbers"`
+	RequireSpecial bool   `json:"RequireSpecial"`
+	EnforceOnLogin bool   `json:"EnforceOnLogin"`
+	Object         string `json:"Object"`
+}
+
+// RespAccessToken answers a client credentials login, there is no refresh
+// token, the client logs in again.
+type RespAccessToken struct {
+	AccessToken string  `json:"access_token"`
+	ExpiresIn   float64 `json:"expires_in"`
+	TokenType   string  `json:"token_type"`
+	Scope       string  `json:"scope"`
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/togls/gowarden/config"
+)
+
+const maxRetryDelay = 30 * time.Second
+
+func OpenDB(cfg *config.Core) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database url: %w", err)
+	}
+
+	// bound every statement at the driver level unless the url
+	// already carries explicit timeouts
+	timeout := time.Duration(cfg.DatabaseTimeout) * time.Second
+	if dsn.Timeout == 0 {
+		dsn.Timeout = timeout
+	}
+
+	if dsn.ReadTimeout == 0 {
+		dsn.ReadTimeout = timeout
+	}
+
+	if dsn.WriteTimeout == 0 {
+		dsn.WriteTimeout = timeout
+	}
+
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(cfg.DatabaseMaxConns)
+	db.SetMaxIdleConns(cfg.DatabaseMaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.DatabaseConnLifetime) * time.Second)
+
+	if err := waitDB(db, cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// waitDB pings the database until it answers, backing off between attempts.
+// DBConnectionRetries of 0 keeps retrying forever.
+func waitDB(db *sql.DB, cfg *config.Core) error {
+	log := cfg.Logger
+	timeout := time.Duration(cfg.DatabaseTimeout) * time.Second
+	delay := time.Second
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			log.Info().Int("attempt", attempt).Msg("database connected")
+			return nil
+		}
+
+		if cfg.DBConnectionRetries > 0 && attempt >= cfg.DBConnectionRetries {
+			return fmt.Errorf("failed to connect db after %d attempts: %w", attempt, err)
+		}
+
+		log.Warn().Err(err).
+			Int("attempt", attempt).
+			Dur("retry_in", delay).
+			Msg("database not reachable")
+
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
@@ -12,13 +12,12 @@ import (
 	"github.com/togls/gowarden/config"
 	"github.com/togls/gowarden/handler"
 	"github.com/togls/gowarden/handler/middleware"
+	"github.com/togls/gowarden/scheduler"
+	"github.com/togls/gowarden/service"
+	"github.com/togls/gowarden/store/blob"
 	"github.com/togls/gowarden/store/raw"
 )
 
-import (
-	_ "github.com/go-sql-driver/mysql"
-)
-
 // Injectors from wire.go:
 
 func createApp(configFile string, log *zerolog.Logger) (*Apllication, error) {
@@ -30,33 +29,56 @@ func createApp(configFile string, log *zerolog.Logger) (*Apllication, error) {
 	if err != nil {
 		return nil, err
 	}
-	user := raw.NewUserStore(db)
-	device := raw.NewDeviceStore(db)
-	userOrganization := raw.NewUserOrganizationStore(db)
-	send := raw.NewSendStore(db)
-	emergencyAccess := raw.NewEmergencyAccessStore(db)
-	cipher := raw.NewCipherStore(db)
-	favorite := raw.NewFavoriteStore(db)
-	folder := raw.NewFolderStore(db)
-	twoFactor := raw.NewTwoFactorStore(db)
-	twoFactorIncomplete := raw.NewTwoFactorIncompleteStore(db)
-	invitation := raw.NewInvitationStore(db)
-	userCollection := raw.NewUserCollectionStore(db)
-	authCore := auth.New(core, device, user, userOrganization, userCollection)
-	accountHandler := handler.NewAccountHandler(user, device, userOrganization, send, emergencyAccess, cipher, favorite, folder, twoFactor, twoFactorIncomplete, invitation, authCore, authCore)
+	rawDB := raw.NewDB(db, core)
+	user := raw.NewUserStore(rawDB)
+	device := raw.NewDeviceStore(rawDB)
+	userOrganization := raw.NewUserOrganizationStore(rawDB)
+	send := raw.NewSendStore(rawDB)
+	emergencyAccess := raw.NewEmergencyAccessStore(rawDB)
+	cipher := raw.NewCipherStore(rawDB)
+	favorite := raw.NewFavoriteStore(rawDB)
+	folder := raw.NewFolderStore(rawDB)
+	twoFactor := raw.NewTwoFactorStore(rawDB)
+	twoFactorIncomplete := raw.NewTwoFactorIncompleteStore(rawDB)
+	invitation := raw.NewInvitationStore(rawDB)
+	userCollection := raw.NewUserCollectionStore(rawDB)
+	orgPolicy := raw.NewOrgPolicyStore(rawDB)
+	event := raw.NewEventStore(rawDB)
+	orgApiKey := raw.NewOrgApiKeyStore(rawDB)
+	authCore := auth.New(core, device, user, userOrganization, userCollection, orgPolicy, event, orgApiKey)
+	attachment := raw.NewAttachmentStore(rawDB)
+	storeBlob, err := blob.New(core)
+	if err != nil {
+		return nil, err
+	}
+	collection := raw.NewCollectionStore(rawDB)
+	tx := raw.NewTxStore(db)
+	cipherPurge := service.NewCipherPurge(core, attachment, storeBlob, cipher, collection, favorite, folder, tx)
+	group := raw.NewGroupStore(rawDB)
 	globalDomains, err := config.LoadGlobalDomain()
 	if err != nil {
 		return nil, err
 	}
-	attachment := raw.NewAttachmentStore(db)
-	collection := raw.NewCollectionStore(db)
-	orgPolicy := raw.NewOrgPolicyStore(db)
-	cipherHandler := handler.NewCipherHandler(log, globalDomains, authCore, attachment, cipher, collection, favorite, folder, orgPolicy, send, userCollection, user, userOrganization)
+	organization := raw.NewOrganizationStore(rawDB)
+	cipherHandler := handler.NewCipherHandler(log, core, globalDomains, authCore, attachment, storeBlob, cipher, collection, event, favorite, folder, orgPolicy, organization, send, userCollection, user, userOrganization, tx)
 	folderHandler := handler.NewFolderHandler(core, folder, authCore)
-	organization := raw.NewOrganizationStore(db)
-	organizationHandler := handler.NewOrganizationHandler(user, cipher, organization, collection, orgPolicy, userOrganization, userCollection, invitation, authCore, core)
+	organizationHandler := handler.NewOrganizationHandler(user, cipher, organization, collection, orgPolicy, userOrganization, userCollection, invitation, attachment, twoFactor, group, event, device, tx, orgApiKey, authCore, core)
+	accountHandler := handler.NewAccountHandler(user, device, userOrganization, userCollection, group, send, emergencyAccess, cipher, favorite, folder, twoFactor, twoFactorIncomplete, invitation, attachment, storeBlob, cipherPurge, organizationHandler, authCore, authCore, core)
+	sendHandler := handler.NewSendHandler(core, authCore, storeBlob, orgPolicy, send, user)
+	emergencyAccessHandler := handler.NewEmergencyAccessHandler(core, emergencyAccess, user, userOrganization, orgPolicy, twoFactor, device, invitation, cipherHandler, authCore)
+	eventHandler := handler.NewEventHandler(event, cipher, userOrganization, authCore)
 	iconHandler := handler.NewIconHandler()
 	identityHandler := handler.NewIdentityHandler(log, authCore)
+	health := raw.NewHealthStore(db)
+	healthHandler := handler.NewHealthHandler(core, health)
+	sendPurge := scheduler.NewSendPurge(core, storeBlob, send, user)
+	emergencyTimeout := scheduler.NewEmergencyTimeout(core, emergencyAccess)
+	trashPurge := scheduler.NewTrashPurge(core, cipher, userOrganization, user, cipherPurge)
+	schedulerScheduler, err := scheduler.New(core, trashPurge, sendPurge, emergencyTimeout)
+	if err != nil {
+		return nil, err
+	}
+	adminHandler := handler.NewAdminHandler(core, organization, schedulerScheduler)
 	appHeader := middleware.NewAppHeader(core)
 	middlewareRecover := middleware.NewRecover(log)
 	logger := middleware.NewLogger(log)
@@ -66,18 +88,24 @@ func createApp(configFile string, log *zerolog.Logger) (*Apllication, error) {
 		Cipher:       cipherHandler,
 		Folder:       folderHandler,
 		Organization: organizationHandler,
+		Send:         sendHandler,
+		Emergency:    emergencyAccessHandler,
+		Event:        eventHandler,
 		Icon:         iconHandler,
 		Identity:     identityHandler,
+		Health:       healthHandler,
+		Admin:        adminHandler,
 		AppHeader:    appHeader,
 		Recover:      middlewareRecover,
 		LoggerMW:     logger,
 	}
 	httpHandler := handler.NewMux(muxOptions)
 	mainOptions := options{
-		cfg:     core,
-		handler: httpHandler,
-		logger:  log,
-		db:      db,
+		cfg:       core,
+		handler:   httpHandler,
+		logger:    log,
+		db:        db,
+		scheduler: schedulerScheduler,
 	}
 	apllication := NewApplication(mainOptions)
 	return apllication, nil
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestJobsCheck(t *testing.T) {
+	if err := defaultConfig().Jobs.check(); err != nil {
+		t.Fatalf("default schedules: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		jobs    Jobs
+		wantErr bool
+	}{
+		{"disabled", Jobs{}, false},
+		{"valid", Jobs{Incomplete2fa: "30 * * * * *"}, false},
+		{"invalid job without runner", Jobs{Incomplete2fa: "30 * * *"}, true},
+		{"invalid purge", Jobs{TrashPurge: "0 5 0 * * * *"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.jobs.check(); (err != nil) != tt.wantErr {
+				t.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package config
+
+const (
+	StorageFilesystem = "filesystem"
+	StorageS3         = "s3"
+)
+
+type Storage struct {
+	StorageBackend string `json:"storage_backend"` // filesystem or s3
+
+	S3Endpoint  string `json:"s3_endpoint"` // empty uses AWS
+	S3Region    string `json:"s3_region"`
+	S3Bucket    string `json:"s3_bucket"`
+	S3AccessKey string `json:"s3_access_key"`
+	S3SecretKey string `json:"s3_secret_key"`
+	S3PathStyle bool   `json:"s3_path_style"` // required by most self-hosted servers
+}
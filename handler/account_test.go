@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestProfileEmailVerified(t *testing.T) {
+	ts := newTestServer(t)
+
+	unverified, unverifiedToken := ts.addUser("unverified@example.com")
+	verified, verifiedToken := ts.addUser("verified@example.com")
+
+	now := time.Now()
+	verified.VerifiedAt = &now
+	unverified.VerifiedAt = nil
+
+	tests := []struct {
+		name        string
+		mailEnabled bool
+		token       string
+		want        bool
+	}{
+		{"no mail", false, unverifiedToken, true},
+		{"unverified", true, unverifiedToken, false},
+		{"verified", true, verifiedToken, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.ah.mailEnabled = tt.mailEnabled
+			ts.routes()
+
+			rec := ts.do(http.MethodGet, "/api/accounts/profile", tt.token, nil)
+			ts.expect(rec, http.StatusOK)
+
+			var profile struct{ EmailVerified bool }
+			decodeJSON(t, rec, &profile)
+
+			if profile.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", profile.EmailVerified, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+
+	ts := newTestServer(t)
+
+	user, token := ts.addUser("user@example.com")
+	other, _ := ts.addUser("other@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	otherMember := ts.addMember(org, other, model.UOTypeOwner)
+	member := ts.addMember(org, user, model.UOTypeUser)
+
+	ts.addCollection(org, user, other)
+
+	group := &model.Group{Uuid: newTestUuid(t), OrgUuid: org.Uuid}
+	ts.db.groups[group.Uuid] = group
+	ts.db.groupMembers[group.Uuid] = []string{member.Uuid, otherMember.Uuid}
+
+	cipher := ts.addCipher(user, nil)
+	kept := ts.addCipher(other, nil)
+
+	attachment := &model.Attachment{ID: newTestUuid(t), CipherUuid: cipher.Uuid, FileName: "file", FileSize: 1}
+	ts.db.attachments[attachment.ID] = attachment
+	if err := ts.blobs.Put(ctx, attachment.FileKey(), bytes.NewReader([]byte("x")), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := ts.do(http.MethodDelete, "/api/accounts", token, map[string]string{"masterPasswordHash": "wrong"})
+	ts.expect(rec, http.StatusUnauthorized)
+
+	if _, ok := ts.db.ciphers[cipher.Uuid]; !ok {
+		t.Fatalf("a wrong password deleted the cipher")
+	}
+
+	rec = ts.do(http.MethodDelete, "/api/accounts", token, map[string]string{"masterPasswordHash": "password"})
+	ts.expect(rec, http.StatusOK)
+
+	if _, ok := ts.db.ciphers[cipher.Uuid]; ok {
+		t.Errorf("the cipher of the deleted account is left")
+	}
+	if _, ok := ts.db.ciphers[kept.Uuid]; !ok {
+		t.Errorf("the cipher of another account is deleted")
+	}
+
+	if _, ok := ts.db.attachments[attachment.ID]; ok {
+		t.Errorf("the attachment of the deleted account is left")
+	}
+	if _, err := ts.blobs.Size(ctx, attachment.FileKey()); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("attachment file = %v, want ErrNotFound", err)
+	}
+
+	if _, ok := ts.db.uos[member.Uuid]; ok {
+		t.Errorf("the membership of the deleted account is left")
+	}
+
+	for _, uc := range ts.db.ucs {
+		if uc.UserUuid == user.Uuid {
+			t.Errorf("the access of the deleted account to collection %s is left", uc.CollectionUuid)
+		}
+	}
+
+	if members := ts.db.groupMembers[group.Uuid]; len(members) != 1 || members[0] != otherMember.Uuid {
+		t.Errorf("group members = %v, want [%s]", members, otherMember.Uuid)
+	}
+}
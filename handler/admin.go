@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/scheduler"
+	"github.com/togls/gowarden/store"
+)
+
+type AdminHandler struct {
+	orgs      store.Organization
+	scheduler *scheduler.Scheduler
+
+	token        string
+	disableToken bool
+}
+
+func NewAdminHandler(cfg *config.Core, orgs store.Organization, scheduler *scheduler.Scheduler) *AdminHandler {
+	return &AdminHandler{
+		orgs:      orgs,
+		scheduler: scheduler,
+
+		token:        cfg.AdminToken,
+		disableToken: cfg.DisableAdminToken,
+	}
+}
+
+func (ah *AdminHandler) Routes(e *echo.Echo) {
+	admin := e.Group("/admin", ah.RequireAdmin)
+
+	admin.GET("/jobs", ah.GetJobs)
+	admin.GET("/organizations/:uuid/limits", ah.GetOrgLimits)
+	admin.PUT("/organizations/:uuid/limits", ah.PutOrgLimits)
+}
+
+// RequireAdmin checks the admin token sent as bearer token. Without a token
+// configured the admin endpoints are disabled, unless disable_admin_token
+// opens them to everyone.
+func (ah *AdminHandler) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if ah.disableToken {
+			return next(c)
+		}
+
+		if ah.token == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "The admin panel is disabled, please configure the 'admin_token' variable to enable it")
+		}
+
+		token := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(ah.token)) != 1 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid admin token")
+		}
+
+		return next(c)
+	}
+}
+
+func (ah *AdminHandler) GetJobs(c echo.Context) error {
+	resp := struct {
+		Data   []scheduler.Status `json:"Data"`
+		Object string             `json:"Object"`
+	}{
+		Data:   ah.scheduler.Status(),
+		Object: "list",
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// OrgLimitsData are the limits of an organization, null is unlimited.
+type OrgLimitsData struct {
+	MaxSeats       *int `json:"MaxSeats"`
+	MaxCollections *int `json:"MaxCollections"`
+	MaxStorageGb   *int `json:"MaxStorageGb"`
+}
+
+func (ah *AdminHandler) GetOrgLimits(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	org, err := ah.findOrg(ctx, c.Param("uuid"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, OrgLimitsData(org.Limits))
+}
+
+func (ah *AdminHandler) PutOrgLimits(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	org, err := ah.findOrg(ctx, c.Param("uuid"))
+	if err != nil {
+		return err
+	}
+
+	data := new(OrgLimitsData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	for _, limit := range []*int{data.MaxSeats, data.MaxCollections, data.MaxStorageGb} {
+		if limit != nil && *limit < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Limits can't be negative")
+		}
+	}
+
+	org.Limits = model.OrgLimits(*data)
+
+	if err := ah.orgs.Save(ctx, org); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, data)
+}
+
+func (ah *AdminHandler) findOrg(ctx context.Context, uuid string) (*model.Organization, error) {
+	org, err := ah.orgs.FindByUuid(ctx, uuid)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Organization not found")
+	}
+
+	return org, err
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+// serveBlob streams a stored file to the client. http.ServeContent answers
+// the range requests, so interrupted downloads can be resumed.
+func serveBlob(c echo.Context, blobs store.Blob, key string) error {
+	ctx := c.Request().Context()
+
+	size, err := blobs.Size(ctx, key)
+	if errors.Is(err, model.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "File doesn't exist")
+	} else if err != nil {
+		return err
+	}
+
+	r := &blobReader{ctx: ctx, blobs: blobs, key: key, size: size}
+	defer r.Close()
+
+	// the content is encrypted, there is nothing to sniff
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	http.ServeContent(c.Response(), c.Request(), "", time.Time{}, r)
+
+	return nil
+}
+
+// blobReader reads a stored file from the position it was last seeked to,
+// seeking only opens the file again on the next read.
+type blobReader struct {
+	ctx   context.Context
+	blobs store.Blob
+	key   string
+	size  int64
+
+	offset int64
+	rc     io.ReadCloser
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.rc == nil {
+		rc, err := r.blobs.Get(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.rc = rc
+	}
+
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+		r.offset = offset
+	}
+
+	return offset, nil
+}
+
+func (r *blobReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+
+	err := r.rc.Close()
+	r.rc = nil
+
+	return err
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/store/blob"
+)
+
+func TestServeBlob(t *testing.T) {
+	content := []byte("0123456789")
+
+	blobs := blob.NewFilesystem(t.TempDir())
+	if err := blobs.Put(context.Background(), "attachments/cipher/file", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		header map[string]string
+		want   int
+		body   string
+		ranges string
+	}{
+		{"whole file", "attachments/cipher/file", nil, http.StatusOK, "0123456789", ""},
+		{"range", "attachments/cipher/file", map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"open range", "attachments/cipher/file", map[string]string{"Range": "bytes=7-"}, http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"suffix range", "attachments/cipher/file", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"range past the end", "attachments/cipher/file", map[string]string{"Range": "bytes=8-20"}, http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"unsatisfiable range", "attachments/cipher/file", map[string]string{"Range": "bytes=10-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+		{"stale if-range", "attachments/cipher/file", map[string]string{"Range": "bytes=2-5", "If-Range": `"other"`}, http.StatusOK, "0123456789", ""},
+		{"missing file", "attachments/cipher/missing", nil, http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			if err := serveBlob(c, blobs, tt.key); err != nil {
+				e.HTTPErrorHandler(err, c)
+			}
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if tt.body != "" && rec.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.body)
+			}
+
+			if got := rec.Header().Get("Content-Range"); got != tt.ranges {
+				t.Errorf("Content-Range = %q, want %q", got, tt.ranges)
+			}
+		})
+	}
+
+	t.Run("multiple ranges", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Range", "bytes=0-1,8-9")
+
+		rec := httptest.NewRecorder()
+		if err := serveBlob(echo.New().NewContext(req, rec), blobs, "attachments/cipher/file"); err != nil {
+			t.Fatal(err)
+		}
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusPartialContent)
+		}
+
+		if ct := rec.Header().Get(echo.HeaderContentType); !strings.HasPrefix(ct, "multipart/byteranges") {
+			t.Errorf("Content-Type = %q, want multipart/byteranges", ct)
+		}
+
+		for _, part := range []string{"\r\n\r\n01\r\n", "\r\n\r\n89\r\n"} {
+			if !strings.Contains(rec.Body.String(), part) {
+				t.Errorf("body doesn't hold the part %q: %q", part, rec.Body.String())
+			}
+		}
+	})
+}
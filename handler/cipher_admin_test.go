@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestPostCiphersAdmin(t *testing.T) {
+	ts := newTestServer(t)
+
+	user, token := ts.addUser("user@example.com")
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, user, model.UOTypeAdmin)
+
+	writable := ts.addCollection(org, user)
+	readOnly := ts.addCollection(org)
+	ts.db.ucs = append(ts.db.ucs, &model.UserCollection{CollectionUuid: readOnly.Uuid, UserUuid: user.Uuid, ReadOnly: true})
+
+	existing := ts.addCipher(user, nil)
+
+	tests := []struct {
+		name        string
+		org         *string
+		id          *string
+		collections []string
+		want        int
+		// whether the cipher lands in the organization and gets logged
+		shared bool
+	}{
+		{"org cipher", &org.Uuid, nil, []string{writable.Uuid}, http.StatusOK, true},
+		{"clone into the org", &org.Uuid, &existing.Uuid, []string{writable.Uuid}, http.StatusOK, true},
+		{"personal cipher", nil, nil, nil, http.StatusOK, false},
+		{"no collection", &org.Uuid, nil, nil, http.StatusBadRequest, false},
+		{"read only collection", &org.Uuid, nil, []string{readOnly.Uuid}, http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := len(ts.db.findEvents(model.EventCipherCreated))
+
+			rec := ts.do(http.MethodPost, "/api/ciphers/admin", token, map[string]any{
+				"Cipher": map[string]any{
+					"Id":             tt.id,
+					"Type":           1,
+					"Name":           "name",
+					"OrganizationId": tt.org,
+					"Login":          map[string]any{},
+				},
+				"CollectionIds": tt.collections,
+			})
+			ts.expect(rec, tt.want)
+
+			created := ts.db.findEvents(model.EventCipherCreated)
+			if !tt.shared {
+				if len(created) != events {
+					t.Fatalf("logged %d created events, want %d", len(created)-events, 0)
+				}
+				return
+			}
+
+			if len(created) != events+1 {
+				t.Fatalf("logged %d created events, want 1", len(created)-events)
+			}
+
+			event := created[len(created)-1]
+			if event.OrgUuid == nil || *event.OrgUuid != org.Uuid {
+				t.Errorf("event org = %v, want %s", event.OrgUuid, org.Uuid)
+			}
+			if event.ActUserUuid == nil || *event.ActUserUuid != user.Uuid {
+				t.Errorf("event actor = %v, want %s", event.ActUserUuid, user.Uuid)
+			}
+
+			cipher, ok := ts.db.ciphers[*event.CipherUuid]
+			if !ok {
+				t.Fatalf("logged cipher %s doesn't exist", *event.CipherUuid)
+			}
+
+			if cipher.OrganizationUuid == nil || *cipher.OrganizationUuid != org.Uuid || cipher.UserUuid != nil {
+				t.Errorf("cipher owned by user %v and org %v, want org %s", cipher.UserUuid, cipher.OrganizationUuid, org.Uuid)
+			}
+
+			if cls := ts.db.cipherCollections[cipher.Uuid]; len(cls) != 1 || cls[0] != writable.Uuid {
+				t.Errorf("cipher collections = %v, want [%s]", cls, writable.Uuid)
+			}
+
+			if cipher.Uuid == existing.Uuid {
+				t.Errorf("clone reused the uuid of the original cipher")
+			}
+		})
+	}
+}
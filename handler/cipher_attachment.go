@@ -1,69 +1,586 @@
-package handler
-
-import "github.com/labstack/echo/v4"
-
-func (ch *CipherHandler) GetAttachment(c echo.Context) error {
-	cUuid := c.Param("uuid")
-	if cUuid == "" {
-		return echo.NewHTTPError(400, "Cipher doesn't exist")
-	}
-
-	aUuid := c.Param("attachment")
-	if aUuid == "" {
-		return echo.NewHTTPError(400, "Attachment doesn't exist")
-	}
-
-	attachment, err := ch.as.FindByUuid(aUuid)
-	if err != nil {
-		return err
-	}
-
-	if attachment.CipherUuid != cUuid {
-		return echo.NewHTTPError(400, "Attachment doesn't belong to cipher")
-	}
-
-	// TODO: add hostname to url
-	host := ""
-
-	return c.JSON(200, attachment.ToJson(host))
-}
-
-// post_attachment_v2
-
-func (ch *CipherHandler) PostAttachmentV2(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-// post_attachment_v2_data
-
-func (ch *CipherHandler) PostAttachmentV2Data(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) PostAttachment(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) PostAttachmentAdmin(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) PostAttachmentShare(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) DeleteAttachmentPost(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) DeleteAttachmentPostAdmin(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) DeleteAttachment(c echo.Context) error {
-	panic("TODO: not implemented")
-}
-
-func (ch *CipherHandler) DeleteAttachmentAdmin(c echo.Context) error {
-	panic("TODO: not implemented")
-}
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+// attachmentSizeLeeway is how far the uploaded data may differ from the size
+// announced by the client in the v2 metadata request.
+const attachmentSizeLeeway = 1_000_000
+
+func (ch *CipherHandler) GetAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	cUuid := c.Param("uuid")
+	if cUuid == "" {
+		return echo.NewHTTPError(400, "Cipher doesn't exist")
+	}
+
+	aUuid := c.Param("attachment")
+	if aUuid == "" {
+		return echo.NewHTTPError(400, "Attachment doesn't exist")
+	}
+
+	user := auth.GetUser(c)
+
+	// the response carries a signed download url, it is only handed out
+	// to the users who can read the cipher
+	if _, err := ch.readableCipher(ctx, cUuid, user.Uuid); err != nil {
+		return err
+	}
+
+	attachment, err := ch.as.FindByUuid(ctx, aUuid)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && attachment.CipherUuid != cUuid) {
+		return echo.NewHTTPError(http.StatusNotFound, "Attachment doesn't exist")
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, response.NewAttachment(attachment, ch.attachmentURL))
+}
+
+// DownloadAttachment serves the file without the bearer header, access is
+// granted by the signed token in the url.
+func (ch *CipherHandler) DownloadAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	cUuid, aUuid := c.Param("cipher"), c.Param("file")
+
+	if err := ch.auth.DecodeFileDownload(c.QueryParam("token"), cUuid, aUuid); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid download token").SetInternal(err)
+	}
+
+	attachment, err := ch.as.FindByUuid(ctx, aUuid)
+	if err != nil || attachment.CipherUuid != cUuid {
+		return echo.NewHTTPError(http.StatusNotFound, "Attachment doesn't exist")
+	}
+
+	return serveBlob(c, ch.blobs, attachment.FileKey())
+}
+
+type AttachmentData struct {
+	Key          string `json:"Key"`
+	FileName     string `json:"FileName"`
+	FileSize     int    `json:"FileSize"`
+	AdminRequest bool   `json:"AdminRequest"`
+}
+
+// post_attachment_v2
+// Reserves an attachment for the cipher, the file data is uploaded
+// afterwards to the returned url.
+
+func (ch *CipherHandler) PostAttachmentV2(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(AttachmentData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.writableCipher(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if err := ch.checkStorage(ctx, cipher, int64(data.FileSize)); err != nil {
+		return err
+	}
+
+	id, err := crypto.GenerateAttachmentId()
+	if err != nil {
+		return err
+	}
+
+	attachment := &model.Attachment{
+		ID:         id,
+		CipherUuid: cipher.Uuid,
+		FileName:   data.FileName,
+		FileSize:   data.FileSize,
+		Akey:       &data.Key,
+	}
+
+	if err := ch.as.Create(ctx, attachment); err != nil {
+		return err
+	}
+
+	resp, err := ch.cipherResponse(ctx, cipher, user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, response.NewAttachmentUpload(attachment, resp, data.AdminRequest))
+}
+
+// post_attachment_v2_data
+
+func (ch *CipherHandler) PostAttachmentV2Data(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.writableCipher(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	attachment, err := ch.as.FindByUuid(ctx, c.Param("attachment"))
+	if err != nil || attachment.CipherUuid != cipher.Uuid {
+		return echo.NewHTTPError(400, "Attachment doesn't exist")
+	}
+
+	file, err := c.FormFile("data")
+	if err != nil {
+		return echo.NewHTTPError(400, "No attachment data provided")
+	}
+
+	size := int(file.Size)
+	minSize := attachment.FileSize - attachmentSizeLeeway
+	maxSize := attachment.FileSize + attachmentSizeLeeway
+	if size < minSize || size > maxSize {
+		if err := ch.as.Delete(ctx, attachment.ID); err != nil {
+			return err
+		}
+
+		return echo.NewHTTPError(400, fmt.Sprintf(
+			"Attachment size mismatch (expected within [%d, %d], got %d)",
+			minSize, maxSize, size))
+	}
+
+	// the announced size is already counted, only the difference has to fit
+	if size > attachment.FileSize {
+		if err := ch.checkStorage(ctx, cipher, int64(size-attachment.FileSize)); err != nil {
+			return err
+		}
+	}
+
+	if err := ch.saveAttachmentFile(ctx, attachment, file); err != nil {
+		return err
+	}
+
+	if size != attachment.FileSize {
+		attachment.FileSize = size
+
+		if err := ch.as.Save(ctx, attachment); err != nil {
+			return err
+		}
+	}
+
+	if err := ch.logCipherEvent(ctx, model.EventCipherAttachmentCreated, cipher); err != nil {
+		return err
+	}
+
+	return c.NoContent(200)
+}
+
+// post_attachment
+// Legacy single request upload, the key and data come as multipart fields.
+
+func (ch *CipherHandler) PostAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.writableCipher(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	key := c.FormValue("key")
+	if key == "" {
+		return echo.NewHTTPError(400, "No attachment key provided")
+	}
+
+	file, err := c.FormFile("data")
+	if err != nil {
+		return echo.NewHTTPError(400, "No attachment data provided")
+	}
+
+	if err := ch.checkStorage(ctx, cipher, file.Size); err != nil {
+		return err
+	}
+
+	id, err := crypto.GenerateAttachmentId()
+	if err != nil {
+		return err
+	}
+
+	attachment := &model.Attachment{
+		ID:         id,
+		CipherUuid: cipher.Uuid,
+		FileName:   file.Filename,
+		FileSize:   int(file.Size),
+		Akey:       &key,
+	}
+
+	if err := ch.saveAttachmentFile(ctx, attachment, file); err != nil {
+		return err
+	}
+
+	if err := ch.as.Create(ctx, attachment); err != nil {
+		ch.removeAttachmentFile(ctx, attachment)
+		return err
+	}
+
+	if err := ch.logCipherEvent(ctx, model.EventCipherAttachmentCreated, cipher); err != nil {
+		return err
+	}
+
+	resp, err := ch.cipherResponse(ctx, cipher, user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, resp)
+}
+
+func (ch *CipherHandler) PostAttachmentAdmin(c echo.Context) error {
+	return ch.PostAttachment(c)
+}
+
+// post_attachment_share
+// Stages the attachment re-encrypted with the organization key, the stored
+// file is only replaced once the cipher itself is shared.
+
+func (ch *CipherHandler) PostAttachmentShare(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.writableCipher(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	attachment, err := ch.as.FindByUuid(ctx, c.Param("attachment"))
+	if err != nil || attachment.CipherUuid != cipher.Uuid {
+		return echo.NewHTTPError(400, "Attachment doesn't exist")
+	}
+
+	oUuid := c.QueryParam("organizationId")
+	if oUuid == "" {
+		return echo.NewHTTPError(400, "Organization id is required")
+	}
+
+	if _, err := ch.uos.FindByUserAndOrg(ctx, user.Uuid, oUuid); err != nil {
+		return echo.NewHTTPError(400, "You are not a member of the organization")
+	}
+
+	file, err := c.FormFile("data")
+	if err != nil {
+		return echo.NewHTTPError(400, "No attachment data provided")
+	}
+
+	// the file will count towards the organization storage
+	storage, err := ch.orgStorage(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	if err := storageError(storage, file.Size); err != nil {
+		return err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := ch.blobs.Put(ctx, sharedAttachmentKey(oUuid, attachment), src, file.Size); err != nil {
+		return fmt.Errorf("failed to store shared attachment: %w", err)
+	}
+
+	return c.NoContent(200)
+}
+
+func (ch *CipherHandler) DeleteAttachmentPost(c echo.Context) error {
+	return ch.DeleteAttachment(c)
+}
+
+func (ch *CipherHandler) DeleteAttachmentPostAdmin(c echo.Context) error {
+	return ch.DeleteAttachment(c)
+}
+
+func (ch *CipherHandler) DeleteAttachment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.writableCipher(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if err := ch.deleteAttachment(ctx, cipher.Uuid, c.Param("attachment")); err != nil {
+		return err
+	}
+
+	if err := ch.logCipherEvent(ctx, model.EventCipherAttachmentDeleted, cipher); err != nil {
+		return err
+	}
+
+	return c.NoContent(200)
+}
+
+func (ch *CipherHandler) DeleteAttachmentAdmin(c echo.Context) error {
+	return ch.DeleteAttachment(c)
+}
+
+// readableCipher returns the cipher cUuid when the user can access it, a
+// cipher out of reach doesn't exist for the user.
+func (ch *CipherHandler) readableCipher(ctx context.Context, cUuid, uUuid string) (*model.Cipher, error) {
+	cipher, err := ch.ciphers.FindByUuid(ctx, cUuid)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Cipher doesn't exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := ch.accessRestrictions(ctx, cipher, uUuid); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Cipher doesn't exist")
+		}
+		return nil, err
+	}
+
+	return cipher, nil
+}
+
+func (ch *CipherHandler) writableCipher(ctx context.Context, cUuid, uUuid string) (*model.Cipher, error) {
+	cipher, err := ch.ciphers.FindByUuid(ctx, cUuid)
+	if err != nil {
+		return nil, echo.NewHTTPError(400, "Cipher doesn't exist")
+	}
+
+	ro, _, err := ch.accessRestrictions(ctx, cipher, uUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ro {
+		return nil, echo.NewHTTPError(400, "Cipher is not write accessible")
+	}
+
+	return cipher, nil
+}
+
+// checkStorage rejects size more bytes that don't fit in the attachment limit
+// of the cipher owner.
+func (ch *CipherHandler) checkStorage(ctx context.Context, cipher *model.Cipher, size int64) error {
+	var storage model.Storage
+	var err error
+
+	switch {
+	case cipher.UserUuid != nil:
+		storage, err = ch.userStorage(ctx, *cipher.UserUuid)
+	case cipher.OrganizationUuid != nil:
+		storage, err = ch.orgStorage(ctx, *cipher.OrganizationUuid)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return storageError(storage, size)
+}
+
+// storageError reports whether size more bytes don't fit in storage.
+func storageError(storage model.Storage, size int64) error {
+	left, limited := storage.Left()
+	if !limited {
+		return nil
+	}
+
+	if left <= 0 {
+		return echo.NewHTTPError(400, "Attachment storage limit reached! Delete some attachments to free up space")
+	}
+
+	if size > left {
+		return echo.NewHTTPError(400, "Attachment storage limit exceeded with this file")
+	}
+
+	return nil
+}
+
+func (ch *CipherHandler) userStorage(ctx context.Context, uUuid string) (model.Storage, error) {
+	used, err := ch.as.SizeByUser(ctx, uUuid)
+	if err != nil {
+		return model.Storage{}, err
+	}
+
+	return model.Storage{Used: used, Limit: ch.userStorageLimit}, nil
+}
+
+func (ch *CipherHandler) orgStorage(ctx context.Context, oUuid string) (model.Storage, error) {
+	org, err := ch.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return model.Storage{}, err
+	}
+
+	used, err := ch.as.SizeByOrg(ctx, oUuid)
+	if err != nil {
+		return model.Storage{}, err
+	}
+
+	return model.Storage{Used: used, Limit: org.Limits.StorageLimit(ch.orgStorageLimit)}, nil
+}
+
+func (ch *CipherHandler) deleteAttachment(ctx context.Context, cUuid, aUuid string) error {
+	attachment, err := ch.as.FindByUuid(ctx, aUuid)
+	if err != nil || attachment.CipherUuid != cUuid {
+		return echo.NewHTTPError(400, "Attachment doesn't exist")
+	}
+
+	if err := ch.as.Delete(ctx, attachment.ID); err != nil {
+		return err
+	}
+
+	ch.removeAttachmentFile(ctx, attachment)
+
+	return nil
+}
+
+// deleteAttachments removes every attachment of the cipher, it's called
+// before the cipher itself is deleted.
+func (ch *CipherHandler) deleteAttachments(ctx context.Context, cUuid string) error {
+	attachments, err := ch.as.Find(ctx, cUuid)
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		if err := ch.as.Delete(ctx, attachment.ID); err != nil {
+			return err
+		}
+
+		ch.removeAttachmentFile(ctx, attachment)
+	}
+
+	return nil
+}
+
+// attachmentURL returns the absolute download url carrying a short-lived token.
+func (ch *CipherHandler) attachmentURL(attachment *model.Attachment) string {
+	token, err := ch.auth.EncodeFileDownload(attachment.CipherUuid, attachment.ID)
+	if err != nil {
+		ch.logger.Error().Err(err).Str("attachment", attachment.ID).Msg("failed to sign attachment url")
+		return ""
+	}
+
+	return fmt.Sprintf("%s/attachments/%s/%s?token=%s", ch.domain, attachment.CipherUuid, attachment.ID, token)
+}
+
+// sharedAttachmentKey is where the attachment encrypted for the organization
+// waits until the cipher is shared.
+func sharedAttachmentKey(oUuid string, attachment *model.Attachment) string {
+	return path.Join("tmp", "attachments", oUuid, attachment.CipherUuid, attachment.ID)
+}
+
+// promoteSharedAttachments replaces the stored files with the staged ones,
+// keeping a backup of each. The returned func puts the backups back, it's
+// nil when promoting failed since the files are restored already.
+func (ch *CipherHandler) promoteSharedAttachments(ctx context.Context, oUuid string, attachments []*model.Attachment) (func(ctx context.Context), error) {
+	promoted := make([]*model.Attachment, 0, len(attachments))
+
+	restore := func(ctx context.Context) {
+		for _, attachment := range promoted {
+			backup := sharedAttachmentKey(oUuid, attachment) + ".bak"
+			if err := ch.copyBlob(ctx, backup, attachment.FileKey()); err != nil {
+				ch.logger.Error().Err(err).Str("attachment", attachment.ID).Msg("failed to restore attachment")
+			}
+		}
+	}
+
+	for _, attachment := range attachments {
+		shared := sharedAttachmentKey(oUuid, attachment)
+
+		if _, err := ch.blobs.Size(ctx, shared); err != nil {
+			restore(ctx)
+			if errors.Is(err, model.ErrNotFound) {
+				return nil, echo.NewHTTPError(400, "Attachment data was not uploaded for sharing")
+			}
+			return nil, err
+		}
+
+		if err := ch.copyBlob(ctx, attachment.FileKey(), shared+".bak"); err != nil {
+			restore(ctx)
+			return nil, err
+		}
+
+		promoted = append(promoted, attachment)
+
+		if err := ch.copyBlob(ctx, shared, attachment.FileKey()); err != nil {
+			restore(ctx)
+			return nil, err
+		}
+	}
+
+	return restore, nil
+}
+
+// dropSharedAttachments removes the staged files and their backups.
+func (ch *CipherHandler) dropSharedAttachments(ctx context.Context, oUuid string, attachments []*model.Attachment) {
+	for _, attachment := range attachments {
+		shared := sharedAttachmentKey(oUuid, attachment)
+
+		for _, key := range []string{shared, shared + ".bak"} {
+			if err := ch.blobs.Delete(ctx, key); err != nil {
+				ch.logger.Warn().Err(err).Str("key", key).Msg("failed to remove shared attachment file")
+			}
+		}
+	}
+}
+
+func (ch *CipherHandler) copyBlob(ctx context.Context, from, to string) error {
+	size, err := ch.blobs.Size(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	rc, err := ch.blobs.Get(ctx, from, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return ch.blobs.Put(ctx, to, rc, size)
+}
+
+func (ch *CipherHandler) saveAttachmentFile(ctx context.Context, attachment *model.Attachment, fh *multipart.FileHeader) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := ch.blobs.Put(ctx, attachment.FileKey(), src, fh.Size); err != nil {
+		return fmt.Errorf("failed to store attachment: %w", err)
+	}
+
+	return nil
+}
+
+func (ch *CipherHandler) removeAttachmentFile(ctx context.Context, attachment *model.Attachment) {
+	key := attachment.FileKey()
+
+	if err := ch.blobs.Delete(ctx, key); err != nil {
+		ch.logger.Warn().Err(err).Str("key", key).Msg("failed to remove attachment file")
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestGetAttachmentAccess(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	_, strangerToken := ts.addUser("stranger@example.com")
+	member, memberToken := ts.addUser("member@example.com")
+	outsider, outsiderToken := ts.addUser("outsider@example.com")
+	admin, adminToken := ts.addUser("admin@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, member, model.UOTypeUser)
+	ts.addMember(org, outsider, model.UOTypeUser)
+	ts.addMember(org, admin, model.UOTypeAdmin)
+
+	personal := ts.addCipher(owner, nil)
+	shared := ts.addCipher(nil, org, ts.addCollection(org, member))
+
+	for _, cipher := range []*model.Cipher{personal, shared} {
+		ts.db.attachments[cipher.Uuid+"-file"] = &model.Attachment{
+			ID:         cipher.Uuid + "-file",
+			CipherUuid: cipher.Uuid,
+			FileName:   "file",
+			FileSize:   10,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		cipher     string
+		attachment string
+		want       int
+	}{
+		{"owner", ownerToken, personal.Uuid, personal.Uuid + "-file", http.StatusOK},
+		{"stranger", strangerToken, personal.Uuid, personal.Uuid + "-file", http.StatusNotFound},
+		{"collection member", memberToken, shared.Uuid, shared.Uuid + "-file", http.StatusOK},
+		{"member outside the collection", outsiderToken, shared.Uuid, shared.Uuid + "-file", http.StatusNotFound},
+		{"org admin", adminToken, shared.Uuid, shared.Uuid + "-file", http.StatusOK},
+		{"attachment of another cipher", memberToken, shared.Uuid, personal.Uuid + "-file", http.StatusNotFound},
+		{"missing cipher", ownerToken, newTestUuid(t), personal.Uuid + "-file", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(http.MethodGet, "/api/ciphers/"+tt.cipher+"/attachment/"+tt.attachment, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if rec.Code == http.StatusOK && strings.Contains(rec.Body.String(), `"Url":""`) {
+				t.Errorf("response has no download url: %s", rec.Body.String())
+			}
+			if rec.Code != http.StatusOK && strings.Contains(rec.Body.String(), "token=") {
+				t.Errorf("rejected response leaks a download url: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAttachmentStorageLimit(t *testing.T) {
+	const kb = 1024
+
+	ts := newTestServer(t)
+	ts.ch.userStorageLimit = 10 * kb
+	ts.ch.orgStorageLimit = 10 * kb
+	ts.routes()
+
+	user, token := ts.addUser("user@example.com")
+
+	gb := 1
+	limited := ts.addOrg(model.OrgLimits{MaxStorageGb: &gb})
+	fallback := ts.addOrg(model.OrgLimits{})
+	for _, org := range []*model.Organization{limited, fallback} {
+		ts.addMember(org, user, model.UOTypeAdmin)
+	}
+
+	personal := ts.addCipher(user, nil)
+	full := ts.addCipher(user, nil)
+	shared := ts.addCipher(nil, limited)
+	other := ts.addCipher(nil, fallback)
+
+	attach := func(cipher *model.Cipher, size int) {
+		id := newTestUuid(t)
+		ts.db.attachments[id] = &model.Attachment{ID: id, CipherUuid: cipher.Uuid, FileName: "file", FileSize: size}
+	}
+	attach(personal, 8*kb)
+	attach(shared, 1<<30-8*kb)
+	attach(other, 8*kb)
+
+	tests := []struct {
+		name   string
+		cipher *model.Cipher
+		size   int
+		want   int
+	}{
+		{"exceeds the user limit", personal, 3 * kb, http.StatusBadRequest},
+		{"fits the user limit", personal, 2 * kb, http.StatusOK},
+		{"user limit reached", full, 1, http.StatusBadRequest},
+		{"exceeds the org limit", shared, 9 * kb, http.StatusBadRequest},
+		{"fits the org limit", shared, 4 * kb, http.StatusOK},
+		// the org limit replaces the server wide one
+		{"org limit above the server limit", shared, 2 * kb, http.StatusOK},
+		{"exceeds the server wide org limit", other, 4 * kb, http.StatusBadRequest},
+		{"fits the server wide org limit", other, 2 * kb, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(http.MethodPost, "/api/ciphers/"+tt.cipher.Uuid+"/attachment/v2", token, map[string]any{
+				"Key":      "key",
+				"FileName": "file",
+				"FileSize": tt.size,
+			})
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			if rec.Code != http.StatusOK && !strings.Contains(rec.Body.String(), "storage limit") {
+				t.Errorf("rejected for another reason: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAttachmentUploadAndDownload(t *testing.T) {
+	ts := newTestServer(t)
+
+	user, token := ts.addUser("user@example.com")
+	cipher := ts.addCipher(user, nil)
+
+	content := []byte("encrypted attachment data")
+
+	rec := ts.do(http.MethodPost, "/api/ciphers/"+cipher.Uuid+"/attachment/v2", token, map[string]any{
+		"Key":      "key",
+		"FileName": "file",
+		"FileSize": len(content),
+	})
+	ts.expect(rec, http.StatusOK)
+
+	var upload struct{ AttachmentId string }
+	decodeJSON(t, rec, &upload)
+
+	rec = ts.upload("/api/ciphers/"+cipher.Uuid+"/attachment/"+upload.AttachmentId, token, nil, content)
+	ts.expect(rec, http.StatusOK)
+
+	rec = ts.do(http.MethodGet, "/api/ciphers/"+cipher.Uuid+"/attachment/"+upload.AttachmentId, token, nil)
+	ts.expect(rec, http.StatusOK)
+
+	var attachment struct{ Url string }
+	decodeJSON(t, rec, &attachment)
+
+	path := strings.TrimPrefix(attachment.Url, ts.cfg.Domain)
+	if path == attachment.Url {
+		t.Fatalf("download url %q is not on the domain %q", attachment.Url, ts.cfg.Domain)
+	}
+
+	rec = ts.do(http.MethodGet, path, "", nil)
+	ts.expect(rec, http.StatusOK)
+
+	if rec.Body.String() != string(content) {
+		t.Errorf("downloaded %q, want %q", rec.Body.String(), content)
+	}
+
+	// the clients resume interrupted downloads with a range request
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Range", "bytes=10-")
+	rec = httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+	ts.expect(rec, http.StatusPartialContent)
+
+	if rec.Body.String() != string(content[10:]) {
+		t.Errorf("downloaded %q, want %q", rec.Body.String(), content[10:])
+	}
+
+	rec = ts.do(http.MethodGet, path+"x", "", nil)
+	ts.expect(rec, http.StatusUnauthorized)
+}
@@ -1,114 +1,182 @@
-package handler
-
-import (
-	"github.com/labstack/echo/v4"
-	"github.com/togls/gowarden/auth"
-	"github.com/togls/gowarden/model"
-)
-
-// Share Cipher to Organization
-
-type ShareCipherData struct {
-	Cipher        CipherData `json:"Cipher"`
-	CollectionIds []string   `json:"CollectionIds"`
-}
-
-func (ch *CipherHandler) PostCipherShare(c echo.Context) error {
-	data := new(ShareCipherData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	cUuid := c.Param("uuid")
-
-	user := auth.GetUser(c)
-
-	cipher, err := ch.ciphers.FindByUuid(cUuid)
-	if err != nil {
-		return err
-	}
-
-	if err := ch.shareCipher(cipher, *data.Cipher.OrganizationId, user.Uuid, data.CollectionIds); err != nil {
-		return err
-	}
-
-	return c.JSON(200, cipher)
-}
-
-func (ch *CipherHandler) PutCipherShare(c echo.Context) error {
-	return ch.PostCipherShare(c)
-}
-
-type ShareSelectedCipherData struct {
-	Ciphers       []CipherData `json:"Ciphers"`
-	CollectionIds []string     `json:"CollectionIds"`
-}
-
-func (ch *CipherHandler) PutCipherShareSelected(c echo.Context) error {
-	data := new(ShareSelectedCipherData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	user := auth.GetUser(c)
-
-	for _, cipher := range data.Ciphers {
-		oriCipher, err := ch.ciphers.FindByUuid(*cipher.ID)
-		if err != nil {
-			return err
-		}
-
-		if err := ch.shareCipher(oriCipher, *cipher.OrganizationId, user.Uuid, data.CollectionIds); err != nil {
-			return err
-		}
-	}
-
-	return c.NoContent(200)
-}
-
-func (ch *CipherHandler) shareCipher(cipher *model.Cipher, orgID, shareID string, collectionIDs []string) error {
-	if cipher == nil || cipher.Uuid == "" || cipher.UserUuid == nil || *cipher.UserUuid != shareID {
-		return echo.NewHTTPError(400, "Cipher not found")
-	}
-
-	if cipher.OrganizationUuid != nil {
-		return echo.NewHTTPError(400, "Already belongs to an organization.")
-	}
-
-	// check cipher ownership
-	ro, _, err := ch.accessRestrictions(cipher, shareID)
-	if err != nil {
-		return err
-	}
-
-	if ro {
-		return echo.NewHTTPError(403, "Cipher is not write accessible")
-	}
-
-	// check collection access
-	for _, collectionID := range collectionIDs {
-		ok, err := ch.cs.CollectionWriteable(collectionID, shareID)
-		if err != nil {
-			return err
-		}
-
-		if !ok {
-			return echo.NewHTTPError(403, "No rights to modify the collection")
-		}
-	}
-
-	// save cipher
-	cipher.OrganizationUuid = &orgID
-	cipher.UserUuid = nil
-	if err := ch.ciphers.Save(cipher); err != nil {
-		return err
-	}
-
-	if err := ch.cs.SaveCipher(collectionIDs, cipher.Uuid); err != nil {
-		return err
-	}
-
-	return nil
-}
+package handler
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+)
+
+// Share Cipher to Organization
+
+type ShareCipherData struct {
+	Cipher        CipherData `json:"Cipher"`
+	CollectionIds []string   `json:"CollectionIds"`
+}
+
+func (ch *CipherHandler) PostCipherShare(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ShareCipherData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	cUuid := c.Param("uuid")
+
+	user := auth.GetUser(c)
+
+	cipher, err := ch.ciphers.FindByUuid(ctx, cUuid)
+	if err != nil {
+		return err
+	}
+
+	keys, err := data.Cipher.attachmentKeys()
+	if err != nil {
+		return err
+	}
+
+	if err := ch.shareCipher(ctx, cipher, *data.Cipher.OrganizationId, user.Uuid, data.CollectionIds, keys); err != nil {
+		return err
+	}
+
+	if err := ch.logCipherEvent(ctx, model.EventCipherShared, cipher); err != nil {
+		return err
+	}
+
+	return c.JSON(200, cipher)
+}
+
+func (ch *CipherHandler) PutCipherShare(c echo.Context) error {
+	return ch.PostCipherShare(c)
+}
+
+type ShareSelectedCipherData struct {
+	Ciphers       []CipherData `json:"Ciphers"`
+	CollectionIds []string     `json:"CollectionIds"`
+}
+
+func (ch *CipherHandler) PutCipherShareSelected(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ShareSelectedCipherData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	for _, cipher := range data.Ciphers {
+		oriCipher, err := ch.ciphers.FindByUuid(ctx, *cipher.ID)
+		if err != nil {
+			return err
+		}
+
+		keys, err := cipher.attachmentKeys()
+		if err != nil {
+			return err
+		}
+
+		if err := ch.shareCipher(ctx, oriCipher, *cipher.OrganizationId, user.Uuid, data.CollectionIds, keys); err != nil {
+			return err
+		}
+
+		if err := ch.logCipherEvent(ctx, model.EventCipherShared, oriCipher); err != nil {
+			return err
+		}
+	}
+
+	return c.NoContent(200)
+}
+
+// shareCipher moves the cipher into the organization. Its attachments are
+// re-keyed with keys, replacing the files staged by PostAttachmentShare.
+func (ch *CipherHandler) shareCipher(ctx context.Context, cipher *model.Cipher, orgID, shareID string, collectionIDs []string, keys map[string]AttachmentKeyData) error {
+	if cipher == nil || cipher.Uuid == "" || cipher.UserUuid == nil || *cipher.UserUuid != shareID {
+		return echo.NewHTTPError(400, "Cipher not found")
+	}
+
+	if cipher.OrganizationUuid != nil {
+		return echo.NewHTTPError(400, "Already belongs to an organization.")
+	}
+
+	// check cipher ownership
+	ro, _, err := ch.accessRestrictions(ctx, cipher, shareID)
+	if err != nil {
+		return err
+	}
+
+	if ro {
+		return echo.NewHTTPError(403, "Cipher is not write accessible")
+	}
+
+	// check collection access
+	for _, collectionID := range collectionIDs {
+		ok, err := ch.cs.CollectionWriteable(ctx, collectionID, shareID)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return echo.NewHTTPError(403, "No rights to modify the collection")
+		}
+	}
+
+	attachments, err := ch.as.Find(ctx, cipher.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if len(attachments) == 0 {
+		return ch.tx.WithTx(ctx, func(ctx context.Context) error {
+			return ch.moveCipher(ctx, cipher, orgID, collectionIDs)
+		})
+	}
+	defer ch.dropSharedAttachments(ctx, orgID, attachments)
+
+	for _, attachment := range attachments {
+		key, ok := keys[attachment.ID]
+		if !ok {
+			return echo.NewHTTPError(400, "Attachment is not re-keyed for the organization")
+		}
+
+		attachment.FileName = key.FileName
+		attachment.Akey = &key.Key
+	}
+
+	var restore func(ctx context.Context)
+	err = ch.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := ch.moveCipher(ctx, cipher, orgID, collectionIDs); err != nil {
+			return err
+		}
+
+		for _, attachment := range attachments {
+			if err := ch.as.Save(ctx, attachment); err != nil {
+				return err
+			}
+		}
+
+		// files go last, they can't be rolled back with the transaction
+		restore, err = ch.promoteSharedAttachments(ctx, orgID, attachments)
+		return err
+	})
+	if err != nil && restore != nil {
+		restore(ctx)
+	}
+
+	return err
+}
+
+func (ch *CipherHandler) moveCipher(ctx context.Context, cipher *model.Cipher, orgID string, collectionIDs []string) error {
+	cipher.OrganizationUuid = &orgID
+	cipher.UserUuid = nil
+	if err := ch.ciphers.Save(ctx, cipher); err != nil {
+		return err
+	}
+
+	return ch.cs.SaveCipher(ctx, collectionIDs, cipher.Uuid)
+}
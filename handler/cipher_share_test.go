@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestShareCipherAttachments(t *testing.T) {
+	ctx := context.Background()
+
+	ts := newTestServer(t)
+
+	user, token := ts.addUser("user@example.com")
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, user, model.UOTypeAdmin)
+	collection := ts.addCollection(org, user)
+
+	// addAttachment stores an attachment of a new personal cipher
+	addAttachment := func(t *testing.T) (*model.Cipher, *model.Attachment) {
+		cipher := ts.addCipher(user, nil)
+
+		attachment := &model.Attachment{ID: newTestUuid(t), CipherUuid: cipher.Uuid, FileName: "name", FileSize: 8}
+		ts.db.attachments[attachment.ID] = attachment
+
+		if err := ts.blobs.Put(ctx, attachment.FileKey(), bytes.NewReader([]byte("personal")), 8); err != nil {
+			t.Fatal(err)
+		}
+
+		return cipher, attachment
+	}
+
+	share := func(cipher *model.Cipher, attachment *model.Attachment) int {
+		rec := ts.do(http.MethodPost, "/api/ciphers/"+cipher.Uuid+"/share", token, map[string]any{
+			"Cipher": map[string]any{
+				"Type":           1,
+				"Name":           "name",
+				"OrganizationId": org.Uuid,
+				"Login":          map[string]any{},
+				"Attachments2": map[string]any{
+					attachment.ID: map[string]any{"FileName": "org name", "Key": "org key"},
+				},
+			},
+			"CollectionIds": []string{collection.Uuid},
+		})
+
+		return rec.Code
+	}
+
+	content := func(t *testing.T, key string) string {
+		rc, err := ts.blobs.Get(ctx, key, 0, -1)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", key, err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	// staged files and their backups are gone once the share is over
+	expectDropped := func(t *testing.T, attachment *model.Attachment) {
+		shared := sharedAttachmentKey(org.Uuid, attachment)
+		for _, key := range []string{shared, shared + ".bak"} {
+			if _, err := ts.blobs.Size(ctx, key); !errors.Is(err, model.ErrNotFound) {
+				t.Errorf("Size(%s) = %v, want ErrNotFound", key, err)
+			}
+		}
+	}
+
+	t.Run("staged", func(t *testing.T) {
+		cipher, attachment := addAttachment(t)
+
+		rec := ts.upload("/api/ciphers/"+cipher.Uuid+"/attachment/"+attachment.ID+"/share?organizationId="+org.Uuid, token, nil, []byte("org data"))
+		ts.expect(rec, http.StatusOK)
+
+		if got := content(t, attachment.FileKey()); got != "personal" {
+			t.Fatalf("staging replaced the file with %q", got)
+		}
+
+		if code := share(cipher, attachment); code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", code, http.StatusOK)
+		}
+
+		if got := content(t, attachment.FileKey()); got != "org data" {
+			t.Errorf("file = %q, want the staged one", got)
+		}
+
+		saved := ts.db.attachments[attachment.ID]
+		if saved.FileName != "org name" || saved.Akey == nil || *saved.Akey != "org key" {
+			t.Errorf("attachment = %s %v, want the organization name and key", saved.FileName, saved.Akey)
+		}
+
+		expectDropped(t, attachment)
+	})
+
+	t.Run("not staged", func(t *testing.T) {
+		cipher, attachment := addAttachment(t)
+
+		if code := share(cipher, attachment); code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", code, http.StatusBadRequest)
+		}
+
+		if got := content(t, attachment.FileKey()); got != "personal" {
+			t.Errorf("file = %q, want it untouched", got)
+		}
+
+		expectDropped(t, attachment)
+	})
+
+	t.Run("partly staged", func(t *testing.T) {
+		cipher, staged := addAttachment(t)
+
+		// sorts after the staged one, which is promoted first
+		missing := &model.Attachment{ID: staged.ID + "-missing", CipherUuid: cipher.Uuid, FileName: "name", FileSize: 8}
+		ts.db.attachments[missing.ID] = missing
+
+		rec := ts.upload("/api/ciphers/"+cipher.Uuid+"/attachment/"+staged.ID+"/share?organizationId="+org.Uuid, token, nil, []byte("org data"))
+		ts.expect(rec, http.StatusOK)
+
+		rec = ts.do(http.MethodPost, "/api/ciphers/"+cipher.Uuid+"/share", token, map[string]any{
+			"Cipher": map[string]any{
+				"Type":           1,
+				"Name":           "name",
+				"OrganizationId": org.Uuid,
+				"Login":          map[string]any{},
+				"Attachments2": map[string]any{
+					staged.ID:  map[string]any{"FileName": "org name", "Key": "org key"},
+					missing.ID: map[string]any{"FileName": "org name", "Key": "org key"},
+				},
+			},
+			"CollectionIds": []string{collection.Uuid},
+		})
+		ts.expect(rec, http.StatusBadRequest)
+
+		// the promoted file is put back when a later one is missing
+		if got := content(t, staged.FileKey()); got != "personal" {
+			t.Errorf("file = %q, want it restored", got)
+		}
+
+		expectDropped(t, staged)
+	})
+}
@@ -1,29 +1,786 @@
-package handler
-
-import (
-	"github.com/labstack/echo/v4"
-
-	"github.com/togls/gowarden/config"
-	"github.com/togls/gowarden/store"
-)
-
-type EmergencyAccessHandler struct {
-	cfg *config.Core
-
-	eas store.EmergencyAccess
-}
-
-func NewEmergencyAccessHandler(
-	cfg *config.Core,
-	eas store.EmergencyAccess,
-) *EmergencyAccessHandler {
-	return &EmergencyAccessHandler{
-		cfg: cfg,
-
-		eas: eas,
-	}
-}
-
-func (eah EmergencyAccessHandler) Routes(e *echo.Echo) {
-	// TODO: implement  EmergencyAccess
-}
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+type EmergencyAccessHandler struct {
+	cfg *config.Core
+
+	eas     store.EmergencyAccess
+	users   store.User
+	uos     store.UserOrganization
+	ops     store.OrgPolicy
+	tfs     store.TwoFactor
+	devices store.Device
+	is      store.Invitation
+
+	ch   *CipherHandler
+	auth *auth.Core
+
+	mailEnabled bool
+}
+
+func NewEmergencyAccessHandler(
+	cfg *config.Core,
+	eas store.EmergencyAccess,
+	users store.User,
+	uos store.UserOrganization,
+	ops store.OrgPolicy,
+	tfs store.TwoFactor,
+	devices store.Device,
+	is store.Invitation,
+	ch *CipherHandler,
+	auth *auth.Core,
+) *EmergencyAccessHandler {
+	return &EmergencyAccessHandler{
+		cfg: cfg,
+
+		eas:     eas,
+		users:   users,
+		uos:     uos,
+		ops:     ops,
+		tfs:     tfs,
+		devices: devices,
+		is:      is,
+
+		ch:   ch,
+		auth: auth,
+
+		mailEnabled: cfg.MailEnabled(),
+	}
+}
+
+func (eah EmergencyAccessHandler) Routes(e *echo.Echo) {
+	ea := e.Group("/api/emergency-access", eah.auth.RequireAuth)
+
+	ea.GET("/trusted", eah.GetTrusted)
+	ea.GET("/granted", eah.GetGranted)
+	ea.POST("/invite", eah.PostInvite, eah.requireAllowed)
+
+	ea.GET("/:id", eah.GetEmergencyAccess, eah.requireAllowed)
+	ea.PUT("/:id", eah.PutEmergencyAccess, eah.requireAllowed)
+	ea.POST("/:id", eah.PutEmergencyAccess, eah.requireAllowed)
+	ea.DELETE("/:id", eah.DeleteEmergencyAccess, eah.requireAllowed)
+	ea.POST("/:id/delete", eah.DeleteEmergencyAccess, eah.requireAllowed)
+
+	ea.POST("/:id/reinvite", eah.PostReinvite, eah.requireAllowed)
+	ea.POST("/:id/accept", eah.PostAccept, eah.requireAllowed)
+	ea.POST("/:id/confirm", eah.PostConfirm, eah.requireAllowed)
+
+	ea.POST("/:id/initiate", eah.PostInitiate, eah.requireAllowed)
+	ea.POST("/:id/approve", eah.PostApprove, eah.requireAllowed)
+	ea.POST("/:id/reject", eah.PostReject, eah.requireAllowed)
+
+	ea.POST("/:id/view", eah.PostView, eah.requireAllowed)
+	ea.POST("/:id/takeover", eah.PostTakeover, eah.requireAllowed)
+	ea.POST("/:id/password", eah.PostPassword, eah.requireAllowed)
+	ea.GET("/:id/policies", eah.GetPolicies, eah.requireAllowed)
+}
+
+func (eah EmergencyAccessHandler) requireAllowed(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !eah.cfg.EmergencyAccessAllowed {
+			return echo.NewHTTPError(http.StatusBadRequest, "Emergency access is not enabled.")
+		}
+
+		return next(c)
+	}
+}
+
+var errEmergencyAccessInvalid = echo.NewHTTPError(http.StatusBadRequest, "Emergency access not valid.")
+
+func (eah *EmergencyAccessHandler) GetTrusted(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	data := make([]*response.EmergencyAccessDetails, 0)
+
+	if eah.cfg.EmergencyAccessAllowed {
+		eas, err := eah.eas.Find(ctx, model.EAFilter{GrantorUuid: &user.Uuid})
+		if err != nil {
+			return err
+		}
+
+		for _, ea := range eas {
+			var grantee *model.User
+			if ea.GranteeUuid != nil {
+				grantee, err = eah.users.FindByUuid(ctx, *ea.GranteeUuid)
+				if err != nil {
+					return err
+				}
+			}
+
+			data = append(data, response.NewEmergencyAccessGrantee(ea, grantee))
+		}
+	}
+
+	resp := &struct {
+		Data              []*response.EmergencyAccessDetails `json:"Data"`
+		Object            string                             `json:"Object"`
+		ContinuationToken any                                `json:"ContinuationToken"`
+	}{
+		Data:              data,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (eah *EmergencyAccessHandler) GetGranted(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	data := make([]*response.EmergencyAccessDetails, 0)
+
+	if eah.cfg.EmergencyAccessAllowed {
+		eas, err := eah.eas.Find(ctx, model.EAFilter{GranteeUuid: &user.Uuid})
+		if err != nil {
+			return err
+		}
+
+		for _, ea := range eas {
+			if ea.GrantorUuid == nil {
+				continue
+			}
+
+			grantor, err := eah.users.FindByUuid(ctx, *ea.GrantorUuid)
+			if err != nil {
+				return err
+			}
+
+			data = append(data, response.NewEmergencyAccessGrantor(ea, grantor))
+		}
+	}
+
+	resp := &struct {
+		Data              []*response.EmergencyAccessDetails `json:"Data"`
+		Object            string                             `json:"Object"`
+		ContinuationToken any                                `json:"ContinuationToken"`
+	}{
+		Data:              data,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (eah *EmergencyAccessHandler) GetEmergencyAccess(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	var grantee *model.User
+	if ea.GranteeUuid != nil {
+		grantee, err = eah.users.FindByUuid(ctx, *ea.GranteeUuid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccessGrantee(ea, grantee))
+}
+
+type EmergencyAccessUpdateData struct {
+	Type         model.EAType `json:"Type"`
+	WaitTimeDays int          `json:"WaitTimeDays"`
+	KeyEncrypted *string      `json:"KeyEncrypted"`
+}
+
+func (data *EmergencyAccessUpdateData) validate() error {
+	if data.Type != model.EATypeView && data.Type != model.EATypeTakeover {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid emergency access type.")
+	}
+
+	if data.WaitTimeDays < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wait time must be at least one day.")
+	}
+
+	return nil
+}
+
+func (eah *EmergencyAccessHandler) PutEmergencyAccess(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(EmergencyAccessUpdateData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if err := data.validate(); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	ea.Atype = data.Type
+	ea.WaitTimeDays = data.WaitTimeDays
+	if data.KeyEncrypted != nil {
+		ea.KeyEncrypted = data.KeyEncrypted
+	}
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccess(ea))
+}
+
+func (eah *EmergencyAccessHandler) DeleteEmergencyAccess(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.findAccess(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	isGrantor := ea.GrantorUuid != nil && *ea.GrantorUuid == user.Uuid
+	isGrantee := ea.GranteeUuid != nil && *ea.GranteeUuid == user.Uuid
+	if !isGrantor && !isGrantee {
+		return errEmergencyAccessInvalid
+	}
+
+	if err := eah.eas.Delete(ctx, ea.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type EmergencyAccessInviteData struct {
+	Email string `json:"Email"`
+	EmergencyAccessUpdateData
+}
+
+func (eah *EmergencyAccessHandler) PostInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(EmergencyAccessInviteData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if err := data.validate(); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	email := strings.ToLower(data.Email)
+	if email == user.Email {
+		return echo.NewHTTPError(http.StatusBadRequest, "You can not set yourself as an emergency contact.")
+	}
+
+	grantee, err := eah.users.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	if errors.Is(err, model.ErrNotFound) {
+		if !eah.cfg.IsInvitationsAllowed() {
+			return echo.NewHTTPError(http.StatusBadRequest, "Grantee user does not exist: "+email)
+		}
+
+		if !eah.cfg.IsEmailDomainAllowed(email) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Email domain not eligible for invitations")
+		}
+
+		if !eah.mailEnabled {
+			if err := eah.is.Save(ctx, &model.Invitation{Email: email}); err != nil {
+				return err
+			}
+		}
+
+		grantee, err = newInvitedUser(email, eah.cfg.PasswordIterations)
+		if err != nil {
+			return err
+		}
+		if err := eah.users.Create(ctx, grantee); err != nil {
+			return err
+		}
+	}
+
+	invited, err := eah.invited(ctx, user.Uuid, grantee)
+	if err != nil {
+		return err
+	}
+
+	if invited {
+		return echo.NewHTTPError(http.StatusBadRequest, "Emergency contact already invited: "+email)
+	}
+
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	ea := &model.EmergencyAccess{
+		Uuid:         id,
+		Status:       model.EAStatusInvited,
+		Atype:        data.Type,
+		WaitTimeDays: data.WaitTimeDays,
+		GrantorUuid:  &user.Uuid,
+		Email:        &email,
+	}
+
+	// without mail the invite can't be delivered, so it's accepted right away,
+	// otherwise the grantee has to accept it with the emailed token
+	if !eah.mailEnabled {
+		ea.GranteeUuid = &grantee.Uuid
+		ea.Email = nil
+		ea.Status = model.EAStatusAccepted
+	}
+
+	if err := eah.eas.Create(ctx, ea); err != nil {
+		return err
+	}
+
+	// TODO: send email with eah.auth.EncodeEmergencyInvite
+
+	return c.NoContent(http.StatusOK)
+}
+
+// invited reports whether grantor already has an emergency access with the
+// grantee, either accepted or still pending on the email.
+func (eah *EmergencyAccessHandler) invited(ctx context.Context, grantor string, grantee *model.User) (bool, error) {
+	eas, err := eah.eas.Find(ctx, model.EAFilter{GrantorUuid: &grantor, GranteeUuid: &grantee.Uuid})
+	if err != nil {
+		return false, err
+	}
+
+	if len(eas) > 0 {
+		return true, nil
+	}
+
+	eas, err = eah.eas.Find(ctx, model.EAFilter{GrantorUuid: &grantor, Email: &grantee.Email})
+	if err != nil {
+		return false, err
+	}
+
+	return len(eas) > 0, nil
+}
+
+func (eah *EmergencyAccessHandler) PostReinvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusInvited || ea.Email == nil {
+		return errEmergencyAccessInvalid
+	}
+
+	grantee, err := eah.users.FindByEmail(ctx, *ea.Email)
+	if errors.Is(err, model.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Grantee user not found.")
+	}
+	if err != nil {
+		return err
+	}
+
+	if !eah.mailEnabled {
+		if err := eah.is.Delete(ctx, grantee.Email); err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		ea.GranteeUuid = &grantee.Uuid
+		ea.Email = nil
+		ea.Status = model.EAStatusAccepted
+
+		if err := eah.eas.Save(ctx, ea); err != nil {
+			return err
+		}
+	}
+
+	// TODO: send email with eah.auth.EncodeEmergencyInvite
+
+	return c.NoContent(http.StatusOK)
+}
+
+type EmergencyAccessAcceptData struct {
+	Token string `json:"Token"`
+}
+
+func (eah *EmergencyAccessHandler) PostAccept(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(EmergencyAccessAcceptData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	claims, err := eah.auth.DecodeEmergencyInvite(data.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid claim")
+	}
+
+	id := c.Param("id")
+	if claims.EmerId != id || claims.Subject != user.Email {
+		return echo.NewHTTPError(http.StatusBadRequest, "Claim does not match the emergency access")
+	}
+
+	ea, err := eah.findAccess(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusInvited || ea.Email == nil || *ea.Email != user.Email {
+		return errEmergencyAccessInvalid
+	}
+
+	ea.GranteeUuid = &user.Uuid
+	ea.Email = nil
+	ea.Status = model.EAStatusAccepted
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type EmergencyAccessConfirmData struct {
+	Key string `json:"Key"`
+}
+
+func (eah *EmergencyAccessHandler) PostConfirm(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(EmergencyAccessConfirmData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusAccepted || ea.GranteeUuid == nil {
+		return errEmergencyAccessInvalid
+	}
+
+	ea.KeyEncrypted = &data.Key
+	ea.Email = nil
+	ea.Status = model.EAStatusConfirmed
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccess(ea))
+}
+
+func (eah *EmergencyAccessHandler) PostInitiate(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.findAccess(ctx, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusConfirmed || ea.GranteeUuid == nil || *ea.GranteeUuid != user.Uuid {
+		return errEmergencyAccessInvalid
+	}
+
+	now := time.Now()
+	ea.Status = model.EAStatusRecoveryInitiated
+	ea.RecoveryInitiatedAt.Time, ea.RecoveryInitiatedAt.Valid = now, true
+	ea.LastNotificationAt.Time, ea.LastNotificationAt.Valid = now, true
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	// TODO: notify the grantor by email
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccess(ea))
+}
+
+func (eah *EmergencyAccessHandler) PostApprove(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusRecoveryInitiated || ea.GranteeUuid == nil {
+		return errEmergencyAccessInvalid
+	}
+
+	ea.Status = model.EAStatusRecoveryApproved
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccess(ea))
+}
+
+func (eah *EmergencyAccessHandler) PostReject(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.grantorAccess(ctx, c.Param("id"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if ea.Status != model.EAStatusRecoveryInitiated && ea.Status != model.EAStatusRecoveryApproved {
+		return errEmergencyAccessInvalid
+	}
+
+	ea.Status = model.EAStatusConfirmed
+
+	if err := eah.eas.Save(ctx, ea); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccess(ea))
+}
+
+func (eah *EmergencyAccessHandler) PostView(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.recoveryAccess(ctx, c.Param("id"), user.Uuid, model.EATypeView)
+	if err != nil {
+		return err
+	}
+
+	ciphers, err := eah.ch.ciphers.FindByUser(ctx, *ea.GrantorUuid)
+	if err != nil {
+		return err
+	}
+
+	data := make([]*response.Cipher, 0, len(ciphers))
+	for _, cipher := range ciphers {
+		resp, err := eah.ch.cipherResponse(ctx, cipher, *ea.GrantorUuid)
+		if err != nil {
+			return err
+		}
+
+		data = append(data, resp)
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccessView(ea, data))
+}
+
+func (eah *EmergencyAccessHandler) PostTakeover(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.recoveryAccess(ctx, c.Param("id"), user.Uuid, model.EATypeTakeover)
+	if err != nil {
+		return err
+	}
+
+	grantor, err := eah.users.FindByUuid(ctx, *ea.GrantorUuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewEmergencyAccessTakeover(ea, grantor))
+}
+
+type EmergencyAccessPasswordData struct {
+	NewMasterPasswordHash string `json:"NewMasterPasswordHash"`
+	Key                   string `json:"Key"`
+}
+
+func (eah *EmergencyAccessHandler) PostPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(EmergencyAccessPasswordData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.recoveryAccess(ctx, c.Param("id"), user.Uuid, model.EATypeTakeover)
+	if err != nil {
+		return err
+	}
+
+	grantor, err := eah.users.FindByUuid(ctx, *ea.GrantorUuid)
+	if err != nil {
+		return err
+	}
+
+	pwHash := crypto.GeneratePassword(
+		data.NewMasterPasswordHash,
+		grantor.Salt,
+		grantor.PasswordIterations)
+
+	ss, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	uu := &model.UpdateUser{
+		Uuid:          grantor.Uuid,
+		PasswordHash:  pwHash,
+		Akey:          &data.Key,
+		SecurityStamp: &ss,
+	}
+
+	if err := eah.users.Update(ctx, uu); err != nil {
+		return err
+	}
+
+	if err := eah.devices.DeleteAllByUser(ctx, grantor.Uuid); err != nil {
+		return err
+	}
+
+	// the grantee can't pass the grantor's two step login
+	if err := eah.tfs.DeleteAllByUser(ctx, grantor.Uuid); err != nil {
+		return err
+	}
+
+	// organizations would still expect the old key, only owners keep theirs
+	uos, err := eah.uos.Find(ctx, &model.UOFilter{UserUuid: &grantor.Uuid})
+	if err != nil {
+		return err
+	}
+
+	for _, uo := range uos {
+		if uo.Atype == model.UOTypeOwner {
+			continue
+		}
+
+		if err := eah.uos.Delete(ctx, uo.Uuid); err != nil {
+			return err
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (eah *EmergencyAccessHandler) GetPolicies(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	ea, err := eah.recoveryAccess(ctx, c.Param("id"), user.Uuid, model.EATypeTakeover)
+	if err != nil {
+		return err
+	}
+
+	policies, err := eah.ops.FindConfirmedByUser(ctx, *ea.GrantorUuid)
+	if err != nil {
+		return err
+	}
+
+	data := response.NewPolicies(policies)
+
+	resp := &struct {
+		Data              []*response.Policy `json:"Data"`
+		Object            string             `json:"Object"`
+		ContinuationToken any                `json:"ContinuationToken"`
+	}{
+		Data:              data,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (eah *EmergencyAccessHandler) findAccess(ctx context.Context, id string) (*model.EmergencyAccess, error) {
+	ea, err := eah.eas.FindByUuid(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Emergency access not found.")
+	}
+
+	return ea, err
+}
+
+func (eah *EmergencyAccessHandler) grantorAccess(ctx context.Context, id, grantor string) (*model.EmergencyAccess, error) {
+	ea, err := eah.findAccess(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ea.GrantorUuid == nil || *ea.GrantorUuid != grantor {
+		return nil, errEmergencyAccessInvalid
+	}
+
+	return ea, nil
+}
+
+// recoveryAccess returns the emergency access the grantee may use for
+// atype. A recovery the grantor didn't reject in time is approved here.
+func (eah *EmergencyAccessHandler) recoveryAccess(ctx context.Context, id, grantee string, atype model.EAType) (*model.EmergencyAccess, error) {
+	ea, err := eah.findAccess(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ea.GranteeUuid == nil || *ea.GranteeUuid != grantee || ea.GrantorUuid == nil || ea.Atype != atype {
+		return nil, errEmergencyAccessInvalid
+	}
+
+	if ea.RecoveryDue(time.Now()) {
+		ea.Status = model.EAStatusRecoveryApproved
+		if err := eah.eas.Save(ctx, ea); err != nil {
+			return nil, err
+		}
+	}
+
+	if ea.Status != model.EAStatusRecoveryApproved {
+		return nil, errEmergencyAccessInvalid
+	}
+
+	return ea, nil
+}
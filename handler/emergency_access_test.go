@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestEmergencyAccessInvite(t *testing.T) {
+	tests := []struct {
+		name        string
+		mailEnabled bool
+		want        model.EAStatus
+	}{
+		// nobody could deliver the invite, so it's accepted on the spot
+		{"mail disabled", false, model.EAStatusAccepted},
+		// the grantee has to accept the emailed invite
+		{"mail enabled", true, model.EAStatusInvited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			ts.eah.mailEnabled = tt.mailEnabled
+			ts.routes()
+
+			grantor, grantorToken := ts.addUser("grantor@example.com")
+			grantee, _ := ts.addUser("grantee@example.com")
+
+			rec := ts.do(http.MethodPost, "/api/emergency-access/invite", grantorToken, map[string]any{
+				"Email":        "Grantee@example.com",
+				"Type":         model.EATypeTakeover,
+				"WaitTimeDays": 7,
+			})
+			ts.expect(rec, http.StatusOK)
+
+			eas, _ := memEmergencyAccesses{db: ts.db}.Find(context.Background(), model.EAFilter{GrantorUuid: &grantor.Uuid})
+			if len(eas) != 1 {
+				t.Fatalf("got %d emergency accesses, want 1", len(eas))
+			}
+
+			ea := eas[0]
+			if ea.Status != tt.want {
+				t.Errorf("status = %d, want %d", ea.Status, tt.want)
+			}
+
+			accepted := ea.GranteeUuid != nil && *ea.GranteeUuid == grantee.Uuid
+			if accepted != (tt.want == model.EAStatusAccepted) {
+				t.Errorf("grantee set = %v for status %d", accepted, ea.Status)
+			}
+
+			// an invite can't be confirmed before the grantee accepted it
+			rec = ts.do(http.MethodPost, "/api/emergency-access/"+ea.Uuid+"/confirm", grantorToken, map[string]any{"Key": "key"})
+			if tt.want == model.EAStatusInvited {
+				ts.expect(rec, http.StatusBadRequest)
+			} else {
+				ts.expect(rec, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestEmergencyAccessRecovery(t *testing.T) {
+	ts := newTestServer(t)
+
+	grantor, grantorToken := ts.addUser("grantor@example.com")
+	grantee, granteeToken := ts.addUser("grantee@example.com")
+	_, strangerToken := ts.addUser("stranger@example.com")
+
+	ea := &model.EmergencyAccess{
+		Uuid:         newTestUuid(t),
+		Status:       model.EAStatusConfirmed,
+		Atype:        model.EATypeTakeover,
+		WaitTimeDays: 7,
+		GrantorUuid:  &grantor.Uuid,
+		GranteeUuid:  &grantee.Uuid,
+	}
+	ts.db.eas[ea.Uuid] = ea
+
+	path := "/api/emergency-access/" + ea.Uuid
+
+	status := func() model.EAStatus {
+		return ts.db.eas[ea.Uuid].Status
+	}
+
+	steps := []struct {
+		name  string
+		path  string
+		token string
+		want  int
+		// status of the emergency access after the step
+		status model.EAStatus
+	}{
+		{"grantor can't initiate", "/initiate", grantorToken, http.StatusBadRequest, model.EAStatusConfirmed},
+		{"stranger can't initiate", "/initiate", strangerToken, http.StatusBadRequest, model.EAStatusConfirmed},
+		{"no takeover before the recovery", "/takeover", granteeToken, http.StatusBadRequest, model.EAStatusConfirmed},
+		{"grantee initiates", "/initiate", granteeToken, http.StatusOK, model.EAStatusRecoveryInitiated},
+		{"no takeover during the wait time", "/takeover", granteeToken, http.StatusBadRequest, model.EAStatusRecoveryInitiated},
+		{"grantee can't approve", "/approve", granteeToken, http.StatusBadRequest, model.EAStatusRecoveryInitiated},
+		{"grantor rejects", "/reject", grantorToken, http.StatusOK, model.EAStatusConfirmed},
+		{"grantee initiates again", "/initiate", granteeToken, http.StatusOK, model.EAStatusRecoveryInitiated},
+		{"grantor approves", "/approve", grantorToken, http.StatusOK, model.EAStatusRecoveryApproved},
+		{"grantee takes over", "/takeover", granteeToken, http.StatusOK, model.EAStatusRecoveryApproved},
+		{"stranger can't take over", "/takeover", strangerToken, http.StatusBadRequest, model.EAStatusRecoveryApproved},
+		{"grantor revokes the approval", "/reject", grantorToken, http.StatusOK, model.EAStatusConfirmed},
+		{"no takeover after the rejection", "/takeover", granteeToken, http.StatusBadRequest, model.EAStatusConfirmed},
+	}
+
+	for _, step := range steps {
+		rec := ts.do(http.MethodPost, path+step.path, step.token, nil)
+		if rec.Code != step.want {
+			t.Fatalf("%s: status = %d, want %d, body: %s", step.name, rec.Code, step.want, rec.Body.String())
+		}
+
+		if got := status(); got != step.status {
+			t.Fatalf("%s: emergency access status = %d, want %d", step.name, got, step.status)
+		}
+	}
+}
+
+func TestEmergencyAccessAutoApproval(t *testing.T) {
+	tests := []struct {
+		name      string
+		atype     model.EAType
+		initiated time.Duration
+		want      int
+		status    model.EAStatus
+	}{
+		{"wait time over", model.EATypeTakeover, -8 * 24 * time.Hour, http.StatusOK, model.EAStatusRecoveryApproved},
+		{"wait time running", model.EATypeTakeover, -6 * 24 * time.Hour, http.StatusBadRequest, model.EAStatusRecoveryInitiated},
+		{"view access can't take over", model.EATypeView, -8 * 24 * time.Hour, http.StatusBadRequest, model.EAStatusRecoveryInitiated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+
+			grantor, _ := ts.addUser("grantor@example.com")
+			grantee, granteeToken := ts.addUser("grantee@example.com")
+
+			ea := &model.EmergencyAccess{
+				Uuid:                newTestUuid(t),
+				Status:              model.EAStatusRecoveryInitiated,
+				Atype:               tt.atype,
+				WaitTimeDays:        7,
+				GrantorUuid:         &grantor.Uuid,
+				GranteeUuid:         &grantee.Uuid,
+				RecoveryInitiatedAt: sql.NullTime{Time: time.Now().Add(tt.initiated), Valid: true},
+			}
+			ts.db.eas[ea.Uuid] = ea
+
+			rec := ts.do(http.MethodPost, "/api/emergency-access/"+ea.Uuid+"/takeover", granteeToken, nil)
+			ts.expect(rec, tt.want)
+
+			if got := ts.db.eas[ea.Uuid].Status; got != tt.status {
+				t.Errorf("status = %d, want %d", got, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+// eventsPageSize is how many events a page of the event log holds.
+const eventsPageSize = 100
+
+type EventHandler struct {
+	events  store.Event
+	ciphers store.Cipher
+	uos     store.UserOrganization
+
+	auth *auth.Core
+}
+
+func NewEventHandler(
+	events store.Event,
+	ciphers store.Cipher,
+	uos store.UserOrganization,
+	auth *auth.Core,
+) *EventHandler {
+	return &EventHandler{
+		events:  events,
+		ciphers: ciphers,
+		uos:     uos,
+		auth:    auth,
+	}
+}
+
+func (eh *EventHandler) Routes(e *echo.Echo) {
+	e.GET("/api/ciphers/:uuid/events", eh.GetCipherEvents, eh.auth.RequireAuth)
+
+	org := e.Group("/api/organizations", eh.auth.RequireAdminAuth)
+	org.GET("/:ouuid/events", eh.GetOrgEvents)
+	org.GET("/:ouuid/users/:uouuid/events", eh.GetMemberEvents)
+
+	e.GET("/api/public/events", eh.GetPublicEvents, eh.auth.RequireOrgApiAuth)
+}
+
+type EventsQuery struct {
+	Start             string `query:"start"`
+	End               string `query:"end"`
+	ContinuationToken string `query:"continuationToken"`
+}
+
+// filter turns the query into the date range of the requested page, the
+// continuation token points at the last event of the previous page.
+func (q EventsQuery) filter() (*model.EventFilter, error) {
+	filter := &model.EventFilter{
+		End:   time.Now(),
+		Limit: eventsPageSize,
+	}
+
+	if q.End != "" {
+		end, err := time.Parse(time.RFC3339, q.End)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid end date").SetInternal(err)
+		}
+		filter.End = end
+	}
+
+	if q.ContinuationToken != "" {
+		cursor, err := parseEventsToken(q.ContinuationToken)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid continuation token").SetInternal(err)
+		}
+		filter.End = cursor.Date
+		filter.Cursor = cursor
+	}
+
+	filter.Start = filter.End.AddDate(0, 0, -30)
+	if q.Start != "" {
+		start, err := time.Parse(time.RFC3339, q.Start)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid start date").SetInternal(err)
+		}
+		filter.Start = start
+	}
+
+	return filter, nil
+}
+
+func (eh *EventHandler) GetOrgEvents(c echo.Context) error {
+	oUuid := c.Param("ouuid")
+
+	return eh.listEvents(c, func(filter *model.EventFilter) {
+		filter.OrgUuid = &oUuid
+	})
+}
+
+func (eh *EventHandler) GetMemberEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	uo, err := eh.uos.FindByUuid(ctx, c.Param("uouuid"))
+	if errors.Is(err, model.ErrNotFound) || (err == nil && uo.OrgUuid != oUuid) {
+		return echo.NewHTTPError(http.StatusNotFound, "The specified user isn't a member of the organization")
+	}
+	if err != nil {
+		return err
+	}
+
+	return eh.listEvents(c, func(filter *model.EventFilter) {
+		filter.OrgUuid = &oUuid
+		filter.MemberUuid = &uo.UserUuid
+	})
+}
+
+// GetPublicEvents lists the events of the organization of the public API
+// client.
+func (eh *EventHandler) GetPublicEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	query := new(EventsQuery)
+	if err := c.Bind(query); err != nil {
+		return err
+	}
+
+	filter, err := query.filter()
+	if err != nil {
+		return err
+	}
+
+	oUuid := auth.GetApiOrganization(c)
+	filter.OrgUuid = &oUuid
+
+	events, err := eh.events.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicList(response.NewPublicEvents(events), eventsToken(events)))
+}
+
+func (eh *EventHandler) GetCipherEvents(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	cipher, err := eh.ciphers.FindByUuid(ctx, c.Param("uuid"))
+	if err != nil {
+		return err
+	}
+
+	// only the admins of the cipher's organization see its events
+	admin := false
+	if cipher.OrganizationUuid != nil {
+		uo, err := eh.uos.FindByUserAndOrg(ctx, user.Uuid, *cipher.OrganizationUuid)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		admin = err == nil && uo.Status == model.UOStatusConfirmed && uo.Atype <= model.UOTypeAdmin
+	}
+
+	if !admin {
+		return eh.eventsResponse(c, nil)
+	}
+
+	return eh.listEvents(c, func(filter *model.EventFilter) {
+		filter.CipherUuid = &cipher.Uuid
+	})
+}
+
+func (eh *EventHandler) listEvents(c echo.Context, scope func(filter *model.EventFilter)) error {
+	ctx := c.Request().Context()
+
+	query := new(EventsQuery)
+	if err := c.Bind(query); err != nil {
+		return err
+	}
+
+	filter, err := query.filter()
+	if err != nil {
+		return err
+	}
+
+	scope(filter)
+
+	events, err := eh.events.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return eh.eventsResponse(c, events)
+}
+
+// eventsResponse lists a page of events, a full page carries the token of
+// the next one.
+func (eh *EventHandler) eventsResponse(c echo.Context, events []*model.Event) error {
+	return c.JSON(http.StatusOK, struct {
+		Data              []*response.Event `json:"Data"`
+		Object            string            `json:"Object"`
+		ContinuationToken any               `json:"ContinuationToken"`
+	}{
+		Data:              response.NewEvents(events),
+		Object:            "list",
+		ContinuationToken: eventsToken(events),
+	})
+}
+
+// eventsToken is the continuation token of a full page, the date and uuid of
+// its last event. Dates only keep seconds, the uuid resumes the page in the
+// middle of a second.
+func eventsToken(events []*model.Event) any {
+	if len(events) < eventsPageSize {
+		return nil
+	}
+
+	last := events[len(events)-1]
+	return last.EventDate.UTC().Format(time.RFC3339Nano) + "|" + last.Uuid
+}
+
+func parseEventsToken(token string) (*model.EventCursor, error) {
+	date, uuid, ok := strings.Cut(token, "|")
+	if !ok || uuid == "" {
+		return nil, errors.New("missing event uuid")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.EventCursor{Date: t, Uuid: uuid}, nil
+}
+
+// logEvent records the organization event on behalf of the actor of ctx.
+// Events outside of an organization are not kept.
+func logEvent(ctx context.Context, events store.Event, event *model.Event) error {
+	if event.OrgUuid == nil {
+		return nil
+	}
+
+	eUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	event.Uuid = eUuid
+	event.EventDate = time.Now()
+
+	if actor := auth.GetActor(ctx); actor != nil {
+		event.ActUserUuid = &actor.UserUuid
+		event.DeviceType = &actor.DeviceType
+		event.IpAddress = &actor.IpAddress
+	}
+
+	return events.Create(ctx, event)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestEventsToken(t *testing.T) {
+	date := time.Date(2022, time.August, 15, 10, 20, 30, 0, time.UTC)
+
+	events := make([]*model.Event, eventsPageSize)
+	for i := range events {
+		// the whole page falls in the same second
+		events[i] = &model.Event{Uuid: newTestUuid(t), EventDate: date}
+	}
+
+	if token := eventsToken(events[1:]); token != nil {
+		t.Fatalf("eventsToken() of a partial page = %v, want nil", token)
+	}
+
+	token, ok := eventsToken(events).(string)
+	if !ok {
+		t.Fatalf("eventsToken() of a full page is not a string")
+	}
+
+	query := EventsQuery{ContinuationToken: token}
+	filter, err := query.filter()
+	if err != nil {
+		t.Fatalf("filter() error = %v", err)
+	}
+
+	last := events[len(events)-1]
+	if filter.Cursor == nil || !filter.Cursor.Date.Equal(last.EventDate) || filter.Cursor.Uuid != last.Uuid {
+		t.Fatalf("filter() cursor = %+v, want %s %s", filter.Cursor, last.EventDate, last.Uuid)
+	}
+
+	if !filter.Start.Equal(date.AddDate(0, 0, -30)) {
+		t.Errorf("filter() start = %s, want 30 days before the cursor", filter.Start)
+	}
+
+	for _, bad := range []string{"garbage", date.Format(time.RFC3339Nano), "|" + last.Uuid, "2022-13-01T00:00:00Z|" + last.Uuid} {
+		if _, err := (EventsQuery{ContinuationToken: bad}).filter(); err == nil {
+			t.Errorf("filter() accepted the token %q", bad)
+		}
+	}
+}
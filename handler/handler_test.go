@@ -0,0 +1,1220 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/service"
+	"github.com/togls/gowarden/store"
+	"github.com/togls/gowarden/store/blob"
+)
+
+// The handler tests run the routes of the handlers on in-memory stores. The
+// stores only implement what the tested routes use, the other methods of
+// the embedded interfaces panic.
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+func testConfig(t *testing.T) *config.Core {
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		testKey = key
+	})
+
+	logger := zerolog.Nop()
+
+	cfg := &config.Core{
+		Logger: &logger,
+		PriKey: testKey,
+		PubKey: &testKey.PublicKey,
+	}
+	cfg.Domain = "http://localhost"
+	cfg.InvitationsAllowed = true
+	cfg.EmergencyAccessAllowed = true
+	cfg.SendsAllowed = true
+	cfg.PasswordIterations = 1
+
+	return cfg
+}
+
+type testServer struct {
+	t     *testing.T
+	e     *echo.Echo
+	db    *memDB
+	cfg   *config.Core
+	auth  *auth.Core
+	blobs store.Blob
+
+	ah  *AccountHandler
+	ch  *CipherHandler
+	oh  *OrganizationHandler
+	eah *EmergencyAccessHandler
+	evh *EventHandler
+	ih  *IdentityHandler
+	sh  *SendHandler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := testConfig(t)
+	db := newMemDB()
+
+	var (
+		users   = memUsers{db: db}
+		devices = memDevices{db: db}
+		uos     = memUOs{db: db}
+		orgs    = memOrgs{db: db}
+		ciphers = memCiphers{db: db}
+		as      = memAttachments{db: db}
+		cs      = memCollections{db: db}
+		ucs     = memUserCollections{db: db}
+		groups  = memGroups{db: db}
+		eas     = memEmergencyAccesses{db: db}
+		events  = memEvents{db: db}
+		apiKeys = memOrgApiKeys{db: db}
+		ops     = memOrgPolicies{db: db}
+		is      = memInvitations{db: db}
+		tfs     = memTwoFactors{db: db}
+		tfis    = memTwoFactorIncompletes{}
+		sends   = memSends{db: db}
+		favs    = memFavorites{}
+		folders = memFolders{}
+		blobs   = blob.NewFilesystem(t.TempDir())
+		tx      = memTx{}
+	)
+
+	core := auth.New(cfg, devices, users, uos, ucs, ops, events, apiKeys)
+	purge := service.NewCipherPurge(cfg, as, blobs, ciphers, cs, favs, folders, tx)
+
+	oh := NewOrganizationHandler(users, ciphers, orgs, cs, ops, uos, ucs, is, as, tfs, groups, events, devices, tx, apiKeys, core, cfg)
+
+	ch := NewCipherHandler(cfg.Logger, cfg, nil, core, as, blobs, ciphers, cs, events, favs, folders, ops, orgs, nil, ucs, users, uos, tx)
+
+	ts := &testServer{
+		t:     t,
+		db:    db,
+		cfg:   cfg,
+		auth:  core,
+		blobs: blobs,
+
+		ah:  NewAccountHandler(users, devices, uos, ucs, groups, sends, eas, ciphers, favs, folders, tfs, tfis, is, as, blobs, purge, oh, core, core, cfg),
+		ch:  ch,
+		oh:  oh,
+		eah: NewEmergencyAccessHandler(cfg, eas, users, uos, ops, tfs, devices, is, ch, core),
+		evh: NewEventHandler(events, ciphers, uos, core),
+		ih:  NewIdentityHandler(cfg.Logger, core),
+		sh:  NewSendHandler(cfg, core, blobs, ops, sends, users),
+	}
+	ts.routes()
+
+	return ts
+}
+
+// routes registers the routes of the handlers again, the routes copy the
+// handlers they belong to.
+func (ts *testServer) routes() {
+	ts.e = echo.New()
+	for _, r := range []Router{ts.ah, ts.ch, ts.oh, ts.eah, ts.evh, ts.ih, ts.sh} {
+		r.Routes(ts.e)
+	}
+}
+
+// do sends the request with body encoded as JSON, unless it's a string,
+// and the bearer token when it's not empty.
+func (ts *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	var r io.Reader
+	contentType := echo.MIMEApplicationJSON
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+		if strings.HasPrefix(b, "grant_type=") {
+			contentType = echo.MIMEApplicationForm
+		}
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set(echo.HeaderContentType, contentType)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// upload sends the fields and the file data as a multipart form.
+func (ts *testServer) upload(path, token string, fields map[string]string, data []byte) *httptest.ResponseRecorder {
+	ts.t.Helper()
+
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := w.WriteField(name, value); err != nil {
+			ts.t.Fatal(err)
+		}
+	}
+
+	part, err := w.CreateFormFile("data", "file")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	part.Write(data)
+
+	if err := w.Close(); err != nil {
+		ts.t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+
+	rec := httptest.NewRecorder()
+	ts.e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// expect fails the test when the response doesn't have the status code.
+func (ts *testServer) expect(rec *httptest.ResponseRecorder, code int) {
+	ts.t.Helper()
+
+	if rec.Code != code {
+		ts.t.Fatalf("status = %d, want %d, body: %s", rec.Code, code, rec.Body.String())
+	}
+}
+
+// addUser registers a user with a device and returns it with an access
+// token of that device.
+func (ts *testServer) addUser(email string) (*model.User, string) {
+	ts.t.Helper()
+
+	user := &model.User{
+		Uuid:               newTestUuid(ts.t),
+		Enabled:            true,
+		Email:              email,
+		Name:               email,
+		Salt:               []byte("salt"),
+		PasswordHash:       crypto.GeneratePassword("password", []byte("salt"), 1),
+		PasswordIterations: 1,
+		SecurityStamp:      newTestUuid(ts.t),
+	}
+	ts.db.users[user.Uuid] = user
+
+	device := &model.Device{Uuid: newTestUuid(ts.t), UserUuid: user.Uuid}
+	ts.db.devices[device.Uuid] = device
+
+	now := time.Now()
+	claims := &auth.LoginJwtClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			Issuer:    auth.IssuerLogin,
+			Subject:   user.Uuid,
+		},
+		Device: device.Uuid,
+		Email:  user.Email,
+		Sstamp: user.SecurityStamp,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(ts.cfg.PriKey)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	return user, token
+}
+
+func (ts *testServer) addOrg(limits model.OrgLimits) *model.Organization {
+	org := &model.Organization{Uuid: newTestUuid(ts.t), Name: "org", Limits: limits}
+	ts.db.orgs[org.Uuid] = org
+
+	return org
+}
+
+// addMember adds the user to the organization as a confirmed member.
+func (ts *testServer) addMember(org *model.Organization, user *model.User, atype model.UOType) *model.UserOrganization {
+	key := "key"
+	uo := &model.UserOrganization{
+		Uuid:     newTestUuid(ts.t),
+		UserUuid: user.Uuid,
+		OrgUuid:  org.Uuid,
+		AKey:     &key,
+		Status:   model.UOStatusConfirmed,
+		Atype:    atype,
+	}
+	ts.db.uos[uo.Uuid] = uo
+
+	return uo
+}
+
+func (ts *testServer) addCollection(org *model.Organization, users ...*model.User) *model.Collection {
+	cl := &model.Collection{Uuid: newTestUuid(ts.t), OrgUuid: org.Uuid, Name: "collection"}
+	ts.db.collections[cl.Uuid] = cl
+
+	for _, user := range users {
+		ts.db.ucs = append(ts.db.ucs, &model.UserCollection{CollectionUuid: cl.Uuid, UserUuid: user.Uuid})
+	}
+
+	return cl
+}
+
+func (ts *testServer) addCipher(owner *model.User, org *model.Organization, collections ...*model.Collection) *model.Cipher {
+	cipher := &model.Cipher{Uuid: newTestUuid(ts.t), Atype: model.CTypeLogin, Name: "cipher", Data: []byte("{}")}
+	if org != nil {
+		cipher.OrganizationUuid = &org.Uuid
+	} else {
+		cipher.UserUuid = &owner.Uuid
+	}
+	ts.db.ciphers[cipher.Uuid] = cipher
+
+	for _, cl := range collections {
+		ts.db.cipherCollections[cipher.Uuid] = append(ts.db.cipherCollections[cipher.Uuid], cl.Uuid)
+	}
+
+	return cipher
+}
+
+func newTestUuid(t *testing.T) string {
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.String(), err)
+	}
+}
+
+func clone[T any](v *T) *T {
+	c := *v
+	return &c
+}
+
+// memDB holds the rows of the in-memory stores.
+type memDB struct {
+	users             map[string]*model.User
+	devices           map[string]*model.Device
+	uos               map[string]*model.UserOrganization
+	orgs              map[string]*model.Organization
+	ciphers           map[string]*model.Cipher
+	attachments       map[string]*model.Attachment
+	collections       map[string]*model.Collection
+	cipherCollections map[string][]string
+	ucs               []*model.UserCollection
+	groups            map[string]*model.Group
+	groupMembers      map[string][]string
+	groupCollections  map[string][]*model.CollectionGroup
+	eas               map[string]*model.EmergencyAccess
+	events            []*model.Event
+	apiKeys           []*model.OrgApiKey
+	policies          []*model.OrgPolicy
+	invitations       map[string]bool
+	sends             map[string]*model.Send
+	sendTokens        map[string]time.Time
+	twoFactors        []*model.TwoFactor
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		users:             make(map[string]*model.User),
+		devices:           make(map[string]*model.Device),
+		uos:               make(map[string]*model.UserOrganization),
+		orgs:              make(map[string]*model.Organization),
+		ciphers:           make(map[string]*model.Cipher),
+		attachments:       make(map[string]*model.Attachment),
+		collections:       make(map[string]*model.Collection),
+		cipherCollections: make(map[string][]string),
+		groups:            make(map[string]*model.Group),
+		groupMembers:      make(map[string][]string),
+		groupCollections:  make(map[string][]*model.CollectionGroup),
+		eas:               make(map[string]*model.EmergencyAccess),
+		invitations:       make(map[string]bool),
+		sends:             make(map[string]*model.Send),
+		sendTokens:        make(map[string]time.Time),
+	}
+}
+
+// findEvents returns the recorded events of type atype.
+func (db *memDB) findEvents(atype model.EventType) []*model.Event {
+	var events []*model.Event
+	for _, event := range db.events {
+		if event.Atype == atype {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+type memUsers struct {
+	store.User
+	db *memDB
+}
+
+func (s memUsers) Create(ctx context.Context, user *model.User) error {
+	s.db.users[user.Uuid] = clone(user)
+	return nil
+}
+
+func (s memUsers) Update(ctx context.Context, uu *model.UpdateUser) error {
+	user, ok := s.db.users[uu.Uuid]
+	if !ok {
+		return model.ErrNotFound
+	}
+
+	if uu.PasswordHash != nil {
+		user.PasswordHash = uu.PasswordHash
+	}
+	if uu.Akey != nil {
+		user.Akey = uu.Akey
+	}
+	if uu.SecurityStamp != nil {
+		user.SecurityStamp = *uu.SecurityStamp
+	}
+
+	return nil
+}
+
+func (s memUsers) UpdateRevision(ctx context.Context, uuid string) error {
+	if user, ok := s.db.users[uuid]; ok {
+		user.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+func (s memUsers) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	for _, user := range s.db.users {
+		if user.Email == email {
+			return clone(user), nil
+		}
+	}
+
+	return nil, model.ErrNotFound
+}
+
+func (s memUsers) FindByUuid(ctx context.Context, uuid string) (*model.User, error) {
+	user, ok := s.db.users[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(user), nil
+}
+
+type memDevices struct {
+	store.Device
+	db *memDB
+}
+
+func (s memDevices) Save(ctx context.Context, device *model.Device) error {
+	s.db.devices[device.Uuid] = clone(device)
+	return nil
+}
+
+func (s memDevices) FindByUuid(ctx context.Context, uuid string) (*model.Device, error) {
+	device, ok := s.db.devices[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(device), nil
+}
+
+func (s memDevices) DeleteAllByUser(ctx context.Context, user string) error {
+	for id, device := range s.db.devices {
+		if device.UserUuid == user {
+			delete(s.db.devices, id)
+		}
+	}
+
+	return nil
+}
+
+type memUOs struct {
+	store.UserOrganization
+	db *memDB
+}
+
+func (s memUOs) Find(ctx context.Context, filter *model.UOFilter) ([]*model.UserOrganization, error) {
+	var list []*model.UserOrganization
+	for _, uo := range s.db.uos {
+		switch {
+		case filter.UserUuid != nil && uo.UserUuid != *filter.UserUuid,
+			filter.OrgUuid != nil && uo.OrgUuid != *filter.OrgUuid,
+			filter.Status != nil && uo.Status != *filter.Status,
+			filter.Atype != nil && uo.Atype != *filter.Atype:
+			continue
+		}
+
+		list = append(list, clone(uo))
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Uuid < list[j].Uuid })
+
+	return list, nil
+}
+
+func (s memUOs) FindByUuid(ctx context.Context, uuid string) (*model.UserOrganization, error) {
+	uo, ok := s.db.uos[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(uo), nil
+}
+
+func (s memUOs) FindByUserAndOrg(ctx context.Context, user, org string) (*model.UserOrganization, error) {
+	for _, uo := range s.db.uos {
+		if uo.UserUuid == user && uo.OrgUuid == org {
+			return clone(uo), nil
+		}
+	}
+
+	return nil, model.ErrNotFound
+}
+
+func (s memUOs) Save(ctx context.Context, uo *model.UserOrganization) error {
+	s.db.uos[uo.Uuid] = clone(uo)
+	return nil
+}
+
+func (s memUOs) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.uos, uuid)
+	return nil
+}
+
+func (s memUOs) DeleteAllByUser(ctx context.Context, user string) error {
+	for uuid, uo := range s.db.uos {
+		if uo.UserUuid == user {
+			delete(s.db.uos, uuid)
+		}
+	}
+
+	return nil
+}
+
+type memOrgs struct {
+	store.Organization
+	db *memDB
+}
+
+func (s memOrgs) FindByUuid(ctx context.Context, uuid string) (*model.Organization, error) {
+	org, ok := s.db.orgs[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(org), nil
+}
+
+func (s memOrgs) Save(ctx context.Context, org *model.Organization) error {
+	s.db.orgs[org.Uuid] = clone(org)
+	return nil
+}
+
+type memCiphers struct {
+	store.Cipher
+	db *memDB
+}
+
+func (s memCiphers) Create(ctx context.Context, cipher *model.Cipher) error {
+	if _, ok := s.db.ciphers[cipher.Uuid]; ok {
+		return errors.New("duplicate cipher")
+	}
+
+	s.db.ciphers[cipher.Uuid] = clone(cipher)
+	return nil
+}
+
+func (s memCiphers) Save(ctx context.Context, cipher *model.Cipher) error {
+	s.db.ciphers[cipher.Uuid] = clone(cipher)
+	return nil
+}
+
+func (s memCiphers) FindByUuid(ctx context.Context, uuid string) (*model.Cipher, error) {
+	cipher, ok := s.db.ciphers[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(cipher), nil
+}
+
+func (s memCiphers) FindByUser(ctx context.Context, user string) ([]*model.Cipher, error) {
+	var list []*model.Cipher
+	for _, c := range s.db.ciphers {
+		if c.UserUuid != nil && *c.UserUuid == user {
+			list = append(list, clone(c))
+		}
+	}
+
+	return list, nil
+}
+
+func (s memCiphers) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.ciphers, uuid)
+	return nil
+}
+
+type memAttachments struct {
+	store.Attachment
+	db *memDB
+}
+
+func (s memAttachments) Find(ctx context.Context, cipher string) ([]*model.Attachment, error) {
+	var list []*model.Attachment
+	for _, a := range s.db.attachments {
+		if a.CipherUuid == cipher {
+			list = append(list, clone(a))
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list, nil
+}
+
+func (s memAttachments) FindByUuid(ctx context.Context, uuid string) (*model.Attachment, error) {
+	a, ok := s.db.attachments[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(a), nil
+}
+
+func (s memAttachments) SizeByUser(ctx context.Context, user string) (int64, error) {
+	return s.size(func(c *model.Cipher) bool { return c.UserUuid != nil && *c.UserUuid == user }), nil
+}
+
+func (s memAttachments) SizeByOrg(ctx context.Context, org string) (int64, error) {
+	return s.size(func(c *model.Cipher) bool { return c.OrganizationUuid != nil && *c.OrganizationUuid == org }), nil
+}
+
+func (s memAttachments) size(owned func(c *model.Cipher) bool) int64 {
+	var size int64
+	for _, a := range s.db.attachments {
+		if c, ok := s.db.ciphers[a.CipherUuid]; ok && owned(c) {
+			size += int64(a.FileSize)
+		}
+	}
+
+	return size
+}
+
+func (s memAttachments) Create(ctx context.Context, a *model.Attachment) error {
+	s.db.attachments[a.ID] = clone(a)
+	return nil
+}
+
+func (s memAttachments) Save(ctx context.Context, a *model.Attachment) error {
+	s.db.attachments[a.ID] = clone(a)
+	return nil
+}
+
+func (s memAttachments) Delete(ctx context.Context, id string) error {
+	delete(s.db.attachments, id)
+	return nil
+}
+
+type memCollections struct {
+	store.Collection
+	db *memDB
+}
+
+func (s memCollections) Find(ctx context.Context, filter *model.CollectionFilter) (model.CollectionList, error) {
+	var list model.CollectionList
+	for _, cl := range s.db.collections {
+		if filter.OrgUuid != nil && cl.OrgUuid != *filter.OrgUuid {
+			continue
+		}
+
+		list = append(list, clone(cl))
+	}
+
+	return list, nil
+}
+
+func (s memCollections) FindByUuid(ctx context.Context, uuid string) (*model.Collection, error) {
+	cl, ok := s.db.collections[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(cl), nil
+}
+
+func (s memCollections) FindByCollectionOrg(ctx context.Context, collection, org string) (*model.Collection, error) {
+	cl, ok := s.db.collections[collection]
+	if !ok || cl.OrgUuid != org {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(cl), nil
+}
+
+func (s memCollections) Save(ctx context.Context, cl *model.Collection) error {
+	s.db.collections[cl.Uuid] = clone(cl)
+	return nil
+}
+
+func (s memCollections) SaveCipher(ctx context.Context, collectionIDs []string, cipher string) error {
+	s.db.cipherCollections[cipher] = append(s.db.cipherCollections[cipher], collectionIDs...)
+	return nil
+}
+
+// FindCollectionIds returns every collection of the cipher, whatever the
+// access of the user.
+func (s memCollections) FindCollectionIds(ctx context.Context, cipher, user string) ([]string, error) {
+	return append([]string(nil), s.db.cipherCollections[cipher]...), nil
+}
+
+// CollectionWriteable only knows the grants of users_collections.
+func (s memCollections) CollectionWriteable(ctx context.Context, collection, user string) (bool, error) {
+	for _, uc := range s.db.ucs {
+		if uc.CollectionUuid == collection && uc.UserUuid == user {
+			return !uc.ReadOnly, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s memCollections) DeleteAllByCipher(ctx context.Context, cipher string) error {
+	delete(s.db.cipherCollections, cipher)
+	return nil
+}
+
+type memUserCollections struct {
+	store.UserCollection
+	db *memDB
+}
+
+func (s memUserCollections) Save(ctx context.Context, collection, user string, readOnly, hidePasswords bool) error {
+	s.db.ucs = append(s.db.ucs, &model.UserCollection{
+		CollectionUuid: collection,
+		UserUuid:       user,
+		ReadOnly:       readOnly,
+		HidePasswords:  hidePasswords,
+	})
+
+	return nil
+}
+
+func (s memUserCollections) Find(ctx context.Context, filter *model.UCFilter) (model.UCList, error) {
+	var list model.UCList
+	for _, uc := range s.db.ucs {
+		if filter.UserUuid != nil && uc.UserUuid != *filter.UserUuid {
+			continue
+		}
+
+		if cl, ok := s.db.collections[uc.CollectionUuid]; filter.OrgUuid != nil && (!ok || cl.OrgUuid != *filter.OrgUuid) {
+			continue
+		}
+
+		list = append(list, clone(uc))
+	}
+
+	return list, nil
+}
+
+func (s memUserCollections) FindByUserCipher(ctx context.Context, user, cipher string) (*model.UserCollection, error) {
+	for _, collection := range s.db.cipherCollections[cipher] {
+		for _, uc := range s.db.ucs {
+			if uc.CollectionUuid == collection && uc.UserUuid == user {
+				return clone(uc), nil
+			}
+		}
+	}
+
+	return nil, model.ErrNotFound
+}
+
+func (s memUserCollections) DeleteAllByUserAndOrg(ctx context.Context, user, org string) error {
+	kept := s.db.ucs[:0]
+	for _, uc := range s.db.ucs {
+		if cl, ok := s.db.collections[uc.CollectionUuid]; uc.UserUuid == user && ok && cl.OrgUuid == org {
+			continue
+		}
+		kept = append(kept, uc)
+	}
+	s.db.ucs = kept
+
+	return nil
+}
+
+type memGroups struct {
+	store.Group
+	db *memDB
+}
+
+func (s memGroups) FindByOrg(ctx context.Context, org string) ([]*model.Group, error) {
+	var groups []*model.Group
+	for _, group := range s.db.groups {
+		if group.OrgUuid == org {
+			groups = append(groups, clone(group))
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+
+	return groups, nil
+}
+
+func (s memGroups) FindByUuid(ctx context.Context, uuid string) (*model.Group, error) {
+	group, ok := s.db.groups[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(group), nil
+}
+
+func (s memGroups) Save(ctx context.Context, group *model.Group) error {
+	s.db.groups[group.Uuid] = clone(group)
+	return nil
+}
+
+func (s memGroups) Create(ctx context.Context, group *model.Group) error {
+	s.db.groups[group.Uuid] = clone(group)
+	return nil
+}
+
+func (s memGroups) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.groups, uuid)
+	delete(s.db.groupMembers, uuid)
+	delete(s.db.groupCollections, uuid)
+	return nil
+}
+
+func (s memGroups) FindCollections(ctx context.Context, group string) ([]*model.CollectionGroup, error) {
+	return append([]*model.CollectionGroup(nil), s.db.groupCollections[group]...), nil
+}
+
+func (s memGroups) SaveCollections(ctx context.Context, group string, collections []*model.CollectionGroup) error {
+	s.db.groupCollections[group] = append([]*model.CollectionGroup(nil), collections...)
+	return nil
+}
+
+func (s memGroups) FindMembers(ctx context.Context, group string) ([]string, error) {
+	return append([]string(nil), s.db.groupMembers[group]...), nil
+}
+
+func (s memGroups) SaveMembers(ctx context.Context, group string, members []string) error {
+	s.db.groupMembers[group] = append([]string(nil), members...)
+	return nil
+}
+
+func (s memGroups) FindGroupIds(ctx context.Context, member string) ([]string, error) {
+	var ids []string
+	for group, members := range s.db.groupMembers {
+		for _, m := range members {
+			if m == member {
+				ids = append(ids, group)
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+func (s memGroups) SaveMemberGroups(ctx context.Context, member string, groups []string) error {
+	if err := s.DeleteAllByMember(ctx, member); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		s.db.groupMembers[group] = append(s.db.groupMembers[group], member)
+	}
+
+	return nil
+}
+
+func (s memGroups) DeleteMember(ctx context.Context, group, member string) error {
+	kept := make([]string, 0, len(s.db.groupMembers[group]))
+	for _, m := range s.db.groupMembers[group] {
+		if m != member {
+			kept = append(kept, m)
+		}
+	}
+	s.db.groupMembers[group] = kept
+
+	return nil
+}
+
+func (s memGroups) DeleteAllByMember(ctx context.Context, member string) error {
+	for group, members := range s.db.groupMembers {
+		kept := make([]string, 0, len(members))
+		for _, m := range members {
+			if m != member {
+				kept = append(kept, m)
+			}
+		}
+		s.db.groupMembers[group] = kept
+	}
+
+	return nil
+}
+
+type memEmergencyAccesses struct {
+	store.EmergencyAccess
+	db *memDB
+}
+
+func (s memEmergencyAccesses) Find(ctx context.Context, filter model.EAFilter) ([]*model.EmergencyAccess, error) {
+	var list []*model.EmergencyAccess
+	for _, ea := range s.db.eas {
+		switch {
+		case filter.GrantorUuid != nil && (ea.GrantorUuid == nil || *ea.GrantorUuid != *filter.GrantorUuid),
+			filter.GranteeUuid != nil && (ea.GranteeUuid == nil || *ea.GranteeUuid != *filter.GranteeUuid),
+			filter.Email != nil && (ea.Email == nil || *ea.Email != *filter.Email),
+			filter.Status != nil && ea.Status != *filter.Status:
+			continue
+		}
+
+		list = append(list, clone(ea))
+	}
+
+	return list, nil
+}
+
+func (s memEmergencyAccesses) FindByUuid(ctx context.Context, uuid string) (*model.EmergencyAccess, error) {
+	ea, ok := s.db.eas[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(ea), nil
+}
+
+func (s memEmergencyAccesses) Create(ctx context.Context, ea *model.EmergencyAccess) error {
+	s.db.eas[ea.Uuid] = clone(ea)
+	return nil
+}
+
+func (s memEmergencyAccesses) Save(ctx context.Context, ea *model.EmergencyAccess) error {
+	s.db.eas[ea.Uuid] = clone(ea)
+	return nil
+}
+
+func (s memEmergencyAccesses) DeleteAllByUser(ctx context.Context, user string) error {
+	for uuid, ea := range s.db.eas {
+		if (ea.GrantorUuid != nil && *ea.GrantorUuid == user) || (ea.GranteeUuid != nil && *ea.GranteeUuid == user) {
+			delete(s.db.eas, uuid)
+		}
+	}
+
+	return nil
+}
+
+type memEvents struct {
+	store.Event
+	db *memDB
+}
+
+func (s memEvents) Create(ctx context.Context, event *model.Event) error {
+	s.db.events = append(s.db.events, clone(event))
+	return nil
+}
+
+type memOrgApiKeys struct {
+	store.OrgApiKey
+	db *memDB
+}
+
+func (s memOrgApiKeys) FindByOrgAndType(ctx context.Context, org string, atype model.OrgApiKeyType) (*model.OrgApiKey, error) {
+	for _, key := range s.db.apiKeys {
+		if key.OrgUuid == org && key.Atype == atype {
+			return clone(key), nil
+		}
+	}
+
+	return nil, model.ErrNotFound
+}
+
+func (s memOrgApiKeys) Save(ctx context.Context, key *model.OrgApiKey) error {
+	for i, k := range s.db.apiKeys {
+		if k.Uuid == key.Uuid {
+			s.db.apiKeys[i] = clone(key)
+			return nil
+		}
+	}
+
+	s.db.apiKeys = append(s.db.apiKeys, clone(key))
+	return nil
+}
+
+type memOrgPolicies struct {
+	store.OrgPolicy
+	db *memDB
+}
+
+func (s memOrgPolicies) FindByOrgAndType(ctx context.Context, org string, atype model.OrgPolicyType) (*model.OrgPolicy, error) {
+	for _, policy := range s.db.policies {
+		if policy.OrgUuid == org && policy.Atype == atype {
+			return clone(policy), nil
+		}
+	}
+
+	return nil, model.ErrNotFound
+}
+
+func (s memOrgPolicies) Save(ctx context.Context, policy *model.OrgPolicy) error {
+	for i, p := range s.db.policies {
+		if p.Uuid == policy.Uuid {
+			s.db.policies[i] = clone(policy)
+			return nil
+		}
+	}
+
+	s.db.policies = append(s.db.policies, clone(policy))
+	return nil
+}
+
+func (s memOrgPolicies) FindBindingByUser(ctx context.Context, user string, atype model.OrgPolicyType) ([]*model.OrgPolicy, error) {
+	var policies []*model.OrgPolicy
+	for _, policy := range s.db.policies {
+		if policy.Atype != atype || !policy.Enabled {
+			continue
+		}
+
+		for _, uo := range s.db.uos {
+			if uo.UserUuid != user || uo.OrgUuid != policy.OrgUuid {
+				continue
+			}
+			if uo.Status == model.UOStatusInvited || uo.Atype == model.UOTypeOwner || uo.Atype == model.UOTypeAdmin {
+				continue
+			}
+
+			policies = append(policies, clone(policy))
+		}
+	}
+
+	return policies, nil
+}
+
+type memInvitations struct {
+	store.Invitation
+	db *memDB
+}
+
+func (s memInvitations) FindByEmail(ctx context.Context, email string) (*model.Invitation, error) {
+	if !s.db.invitations[email] {
+		return nil, model.ErrNotFound
+	}
+
+	return &model.Invitation{Email: email}, nil
+}
+
+func (s memInvitations) Save(ctx context.Context, invitation *model.Invitation) error {
+	s.db.invitations[invitation.Email] = true
+	return nil
+}
+
+func (s memInvitations) Delete(ctx context.Context, email string) error {
+	delete(s.db.invitations, email)
+	return nil
+}
+
+type memTwoFactors struct {
+	store.TwoFactor
+	db *memDB
+}
+
+func (s memTwoFactors) FindByUser(ctx context.Context, user string) ([]*model.TwoFactor, error) {
+	var tfs []*model.TwoFactor
+	for _, tf := range s.db.twoFactors {
+		if tf.UserUuid == user {
+			tfs = append(tfs, clone(tf))
+		}
+	}
+
+	return tfs, nil
+}
+
+func (s memTwoFactors) DeleteAllByUser(ctx context.Context, user string) error {
+	var kept []*model.TwoFactor
+	for _, tf := range s.db.twoFactors {
+		if tf.UserUuid != user {
+			kept = append(kept, tf)
+		}
+	}
+	s.db.twoFactors = kept
+
+	return nil
+}
+
+type memTwoFactorIncompletes struct {
+	store.TwoFactorIncomplete
+}
+
+func (memTwoFactorIncompletes) DeleteAllByUser(ctx context.Context, user string) error {
+	return nil
+}
+
+type memSends struct {
+	store.Send
+	db *memDB
+}
+
+func (s memSends) Find(ctx context.Context, filter *model.SendFilter) ([]*model.Send, error) {
+	var sends []*model.Send
+	for _, send := range s.db.sends {
+		if filter.UserUuid != nil && (send.UserUuid == nil || *send.UserUuid != *filter.UserUuid) {
+			continue
+		}
+		if filter.DeletedBefore != nil && !send.DeletionDate.Before(*filter.DeletedBefore) {
+			continue
+		}
+		sends = append(sends, clone(send))
+	}
+
+	sort.Slice(sends, func(i, j int) bool { return sends[i].Uuid < sends[j].Uuid })
+
+	return sends, nil
+}
+
+func (s memSends) FindByUuid(ctx context.Context, uuid string) (*model.Send, error) {
+	send, ok := s.db.sends[uuid]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+
+	return clone(send), nil
+}
+
+func (s memSends) Create(ctx context.Context, send *model.Send) error {
+	s.db.sends[send.Uuid] = clone(send)
+	return nil
+}
+
+func (s memSends) Save(ctx context.Context, send *model.Send) error {
+	s.db.sends[send.Uuid] = clone(send)
+	return nil
+}
+
+func (s memSends) Access(ctx context.Context, uuid string) error {
+	send, ok := s.db.sends[uuid]
+	if !ok {
+		return model.ErrNotFound
+	}
+
+	if send.MaxAccessCount != nil && send.AccessCount >= *send.MaxAccessCount {
+		return model.ErrSendMaxAccess
+	}
+
+	send.AccessCount++
+
+	return nil
+}
+
+func (s memSends) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.sends, uuid)
+	return nil
+}
+
+func (s memSends) DeleteAllByUser(ctx context.Context, user string) error {
+	for uuid, send := range s.db.sends {
+		if send.UserUuid != nil && *send.UserUuid == user {
+			delete(s.db.sends, uuid)
+		}
+	}
+
+	return nil
+}
+
+func (s memSends) SpendToken(ctx context.Context, id string, expires time.Time) (bool, error) {
+	if _, ok := s.db.sendTokens[id]; ok {
+		return false, nil
+	}
+
+	s.db.sendTokens[id] = expires
+
+	return true, nil
+}
+
+type memFavorites struct {
+	store.Favorite
+}
+
+func (memFavorites) IsFavorite(ctx context.Context, cipher, user string) (bool, error) {
+	return false, nil
+}
+
+func (memFavorites) DeleteAllByUser(ctx context.Context, user string) error {
+	return nil
+}
+
+func (memFavorites) DeleteAllByCipher(ctx context.Context, cipher string) error {
+	return nil
+}
+
+type memFolders struct {
+	store.Folder
+}
+
+func (memFolders) FindByUserCipher(ctx context.Context, user, cipher string) (*model.Folder, error) {
+	return nil, model.ErrNotFound
+}
+
+func (memFolders) RemoveCipher(ctx context.Context, cipher string) error {
+	return nil
+}
+
+func (memFolders) DeleteAllByUser(ctx context.Context, user string) error {
+	return nil
+}
+
+type memTx struct{}
+
+func (memTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
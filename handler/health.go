@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/store"
+)
+
+type HealthHandler struct {
+	logger *zerolog.Logger
+
+	health store.Health
+
+	timeout time.Duration
+}
+
+func NewHealthHandler(
+	cfg *config.Core,
+	health store.Health,
+) *HealthHandler {
+	return &HealthHandler{
+		logger: cfg.Logger,
+		health: health,
+
+		timeout: time.Duration(cfg.DatabaseTimeout) * time.Second,
+	}
+}
+
+func (hh *HealthHandler) Routes(e *echo.Echo) {
+	e.GET("/alive", hh.Alive)
+	e.GET("/api/alive", hh.Alive)
+	e.GET("/ready", hh.Ready)
+}
+
+// Alive only reports that the process is serving requests.
+func (hh *HealthHandler) Alive(c echo.Context) error {
+	return c.JSON(http.StatusOK, time.Now().UTC())
+}
+
+type RespReady struct {
+	Status   string `json:"Status"`
+	Database string `json:"Database"`
+}
+
+// Ready reports whether the server can handle traffic, which requires a
+// reachable database.
+func (hh *HealthHandler) Ready(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), hh.timeout)
+	defer cancel()
+
+	if err := hh.health.Ping(ctx); err != nil {
+		hh.logger.Warn().Err(err).Msg("Ready: database ping failed")
+
+		// the driver error names the database host, it stays in the log
+		return c.JSON(http.StatusServiceUnavailable, &RespReady{
+			Status:   "unavailable",
+			Database: "unavailable",
+		})
+	}
+
+	return c.JSON(http.StatusOK, &RespReady{
+		Status:   "ok",
+		Database: "ok",
+	})
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+type OrgApiKeyData struct {
+	Type               model.OrgApiKeyType `json:"Type"`
+	MasterPasswordHash string              `json:"MasterPasswordHash"`
+}
+
+func (oh *OrganizationHandler) PostApiKey(c echo.Context) error {
+	return oh.apiKey(c, false)
+}
+
+func (oh *OrganizationHandler) PostRotateApiKey(c echo.Context) error {
+	return oh.apiKey(c, true)
+}
+
+// apiKey returns the organization API key of the requested type, creating
+// it on first use or when rotated.
+func (oh *OrganizationHandler) apiKey(c echo.Context, rotate bool) error {
+	ctx := c.Request().Context()
+
+	data := new(OrgApiKeyData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if data.Type != model.OrgApiKeyTypeDefault && data.Type != model.OrgApiKeyTypeScim {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid API key type")
+	}
+
+	user := auth.GetUser(c)
+
+	ok := crypto.VerifyPassword(
+		data.MasterPasswordHash,
+		user.Salt,
+		user.PasswordHash,
+		user.PasswordIterations,
+	)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid password")
+	}
+
+	oUuid := c.Param("uuid")
+
+	key, err := oh.apiKeys.FindByOrgAndType(ctx, oUuid, data.Type)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	if key == nil || rotate {
+		if key == nil {
+			kUuid, err := crypto.GenerateUuid()
+			if err != nil {
+				return err
+			}
+
+			key = &model.OrgApiKey{
+				Uuid:    kUuid,
+				OrgUuid: oUuid,
+				Atype:   data.Type,
+			}
+		}
+
+		ak, err := crypto.GenerateApiKey()
+		if err != nil {
+			return err
+		}
+
+		key.ApiKey = ak
+		// tokens issued before the rotation are compared in seconds
+		key.RevisionDate = time.Now().Truncate(time.Second)
+
+		if err := oh.apiKeys.Save(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, struct {
+		ApiKey       string    `json:"ApiKey"`
+		RevisionDate time.Time `json:"RevisionDate"`
+		Object       string    `json:"Object"`
+	}{
+		ApiKey:       key.ApiKey,
+		RevisionDate: key.RevisionDate,
+		Object:       "apiKey",
+	})
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+)
+
+// apiKeyFixture is an organization with an owner, a member and API keys
+// rotated an hour ago, next to another organization with its own key.
+type apiKeyFixture struct {
+	*testServer
+
+	org        *model.Organization
+	owner      *model.User
+	member     *model.User
+	ownerToken string
+	rotated    time.Time
+}
+
+func newApiKeyFixture(t *testing.T) *apiKeyFixture {
+	ts := newTestServer(t)
+
+	f := &apiKeyFixture{
+		testServer: ts,
+		org:        ts.addOrg(model.OrgLimits{}),
+		rotated:    time.Now().Add(-time.Hour).Truncate(time.Second),
+	}
+
+	f.owner, f.ownerToken = ts.addUser("owner@example.com")
+	f.member, _ = ts.addUser("member@example.com")
+	ts.addMember(f.org, f.owner, model.UOTypeOwner)
+	ts.addMember(f.org, f.member, model.UOTypeUser)
+
+	stranger, _ := ts.addUser("stranger@example.com")
+	other := ts.addOrg(model.OrgLimits{})
+	ts.addMember(other, stranger, model.UOTypeUser)
+
+	ts.db.apiKeys = append(ts.db.apiKeys,
+		&model.OrgApiKey{Uuid: newTestUuid(t), OrgUuid: f.org.Uuid, Atype: model.OrgApiKeyTypeDefault, ApiKey: "org-key", RevisionDate: f.rotated},
+		&model.OrgApiKey{Uuid: newTestUuid(t), OrgUuid: f.org.Uuid, Atype: model.OrgApiKeyTypeScim, ApiKey: "scim-key", RevisionDate: f.rotated},
+		&model.OrgApiKey{Uuid: newTestUuid(t), OrgUuid: other.Uuid, Atype: model.OrgApiKeyTypeDefault, ApiKey: "other-key", RevisionDate: f.rotated},
+	)
+
+	return f
+}
+
+func (f *apiKeyFixture) login(clientId, secret, scope string) *httptest.ResponseRecorder {
+	form := url.Values{
+		"client_id":     {clientId},
+		"client_secret": {secret},
+		"scope":         {scope},
+	}
+
+	return f.do(http.MethodPost, "/identity/connect/token", "", "grant_type=client_credentials&"+form.Encode())
+}
+
+func TestOrgApiKeyLogin(t *testing.T) {
+	f := newApiKeyFixture(t)
+	client := "organization." + f.org.Uuid
+
+	tests := []struct {
+		name     string
+		clientId string
+		secret   string
+		scope    string
+		want     int
+	}{
+		{"api key", client, "org-key", "api.organization", http.StatusOK},
+		{"wrong secret", client, "wrong", "api.organization", http.StatusUnauthorized},
+		{"key of another organization", client, "other-key", "api.organization", http.StatusUnauthorized},
+		{"scim key", client, "scim-key", "api.organization", http.StatusUnauthorized},
+		{"unknown organization", "organization." + newTestUuid(t), "org-key", "api.organization", http.StatusUnauthorized},
+		{"user scope", client, "org-key", "api", http.StatusBadRequest},
+		{"user client", "user." + f.owner.Uuid, "org-key", "api.organization", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := f.login(tt.clientId, tt.secret, tt.scope)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestOrgApiKeyScope(t *testing.T) {
+	f := newApiKeyFixture(t)
+
+	rec := f.login("organization."+f.org.Uuid, "org-key", "api.organization")
+	f.expect(rec, http.StatusOK)
+
+	var data struct {
+		AccessToken string `json:"access_token"`
+	}
+	decodeJSON(t, rec, &data)
+
+	// the token only lists the members of its organization
+	rec = f.do(http.MethodGet, "/api/public/members", data.AccessToken, nil)
+	f.expect(rec, http.StatusOK)
+
+	var list struct {
+		Data []struct {
+			UserId string `json:"userId"`
+		} `json:"data"`
+	}
+	decodeJSON(t, rec, &list)
+
+	if len(list.Data) != 2 {
+		t.Fatalf("listed %d members, want 2", len(list.Data))
+	}
+	for _, m := range list.Data {
+		if m.UserId != f.owner.Uuid && m.UserId != f.member.Uuid {
+			t.Errorf("listed user %s of another organization", m.UserId)
+		}
+	}
+
+	// it doesn't open the routes of users
+	rec = f.do(http.MethodGet, "/api/organizations/"+f.org.Uuid+"/policies", data.AccessToken, nil)
+	f.expect(rec, http.StatusUnauthorized)
+
+	// and the tokens of users don't open the public API
+	rec = f.do(http.MethodGet, "/api/public/members", f.ownerToken, nil)
+	f.expect(rec, http.StatusUnauthorized)
+}
+
+func TestOrgApiKeyRotation(t *testing.T) {
+	f := newApiKeyFixture(t)
+
+	// a token issued before the last rotation of the key
+	claims := &auth.OrgApiClaims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(f.rotated.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Issuer:    auth.IssuerOrgApi,
+			Subject:   f.org.Uuid,
+		},
+		ClientId: "organization." + f.org.Uuid,
+		Scope:    []string{"api.organization"},
+	}
+	stale, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(f.cfg.PriKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := f.do(http.MethodGet, "/api/public/members", stale, nil)
+	f.expect(rec, http.StatusUnauthorized)
+
+	rec = f.do(http.MethodPost, "/api/organizations/"+f.org.Uuid+"/rotate-api-key", f.ownerToken, map[string]any{
+		"Type":               model.OrgApiKeyTypeDefault,
+		"MasterPasswordHash": "password",
+	})
+	f.expect(rec, http.StatusOK)
+
+	var key struct {
+		ApiKey string `json:"ApiKey"`
+	}
+	decodeJSON(t, rec, &key)
+
+	f.expect(f.login("organization."+f.org.Uuid, "org-key", "api.organization"), http.StatusUnauthorized)
+	f.expect(f.login("organization."+f.org.Uuid, key.ApiKey, "api.organization"), http.StatusOK)
+}
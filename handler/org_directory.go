@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+type DirectoryImportData struct {
+	Groups            []DirectoryGroupData  `json:"Groups"`
+	Members           []DirectoryMemberData `json:"Members"`
+	OverwriteExisting bool                  `json:"OverwriteExisting"`
+}
+
+type DirectoryGroupData struct {
+	Name              string   `json:"Name"`
+	ExternalId        string   `json:"ExternalId"`
+	MemberExternalIds []string `json:"MemberExternalIds"`
+}
+
+type DirectoryMemberData struct {
+	Email      string `json:"Email"`
+	ExternalId string `json:"ExternalId"`
+	Deleted    bool   `json:"Deleted"`
+}
+
+// PostDirectoryImport syncs the members and groups of a directory into the
+// organization. Members are matched by email, groups by external id. Only
+// plain users are ever removed by a sync, owners, admins and managers are
+// left to the organization admins.
+func (oh *OrganizationHandler) PostDirectoryImport(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(DirectoryImportData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+
+	err := oh.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := oh.importMembers(ctx, oUuid, data); err != nil {
+			return err
+		}
+
+		return oh.importGroups(ctx, oUuid, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) importMembers(ctx context.Context, oUuid string, data *DirectoryImportData) error {
+	synced := make(map[string]bool)
+
+	for _, md := range data.Members {
+		if md.Email == "" {
+			continue
+		}
+
+		uo, err := oh.findMemberByEmail(ctx, oUuid, md.Email)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		if md.Deleted {
+			if uo != nil && uo.Atype == model.UOTypeUser {
+				if err := oh.removeMember(ctx, uo); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if md.ExternalId != "" {
+			synced[md.ExternalId] = true
+		}
+
+		var externalId *string
+		if md.ExternalId != "" {
+			id := md.ExternalId
+			externalId = &id
+		}
+
+		if uo != nil {
+			uo.ExternalId = externalId
+			if err := oh.uos.Save(ctx, uo); err != nil {
+				return err
+			}
+			continue
+		}
+
+		uo = &model.UserOrganization{
+			OrgUuid:    oUuid,
+			Atype:      model.UOTypeUser,
+			ExternalId: externalId,
+		}
+
+		if err := oh.inviteMember(ctx, md.Email, uo); err != nil {
+			return err
+		}
+
+		// TODO: send email
+	}
+
+	if !data.OverwriteExisting {
+		return nil
+	}
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	for _, uo := range uos {
+		if uo.ExternalId == nil || synced[*uo.ExternalId] || uo.Atype != model.UOTypeUser {
+			continue
+		}
+
+		if err := oh.removeMember(ctx, uo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (oh *OrganizationHandler) importGroups(ctx context.Context, oUuid string, data *DirectoryImportData) error {
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]string)
+	for _, uo := range uos {
+		if uo.ExternalId != nil {
+			members[*uo.ExternalId] = uo.Uuid
+		}
+	}
+
+	groups, err := oh.groups.FindByOrg(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]*model.Group)
+	for _, group := range groups {
+		if group.ExternalId != nil {
+			existing[*group.ExternalId] = group
+		}
+	}
+
+	synced := make(map[string]bool)
+	for _, gd := range data.Groups {
+		if gd.ExternalId == "" {
+			continue
+		}
+		synced[gd.ExternalId] = true
+
+		group, ok := existing[gd.ExternalId]
+		if !ok {
+			gUuid, err := crypto.GenerateUuid()
+			if err != nil {
+				return err
+			}
+
+			externalId := gd.ExternalId
+			group = &model.Group{
+				Uuid:       gUuid,
+				OrgUuid:    oUuid,
+				ExternalId: &externalId,
+			}
+		}
+
+		group.Name = gd.Name
+
+		save, atype := oh.groups.Save, model.EventGroupUpdated
+		if !ok {
+			save, atype = oh.groups.Create, model.EventGroupCreated
+		}
+
+		if err := save(ctx, group); err != nil {
+			return err
+		}
+
+		uoUuids := make([]string, 0, len(gd.MemberExternalIds))
+		for _, id := range gd.MemberExternalIds {
+			if uoUuid, ok := members[id]; ok {
+				uoUuids = append(uoUuids, uoUuid)
+			}
+		}
+
+		if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+			return err
+		}
+
+		if err := oh.groups.SaveMembers(ctx, group.Uuid, uoUuids); err != nil {
+			return err
+		}
+
+		if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+			return err
+		}
+
+		if err := oh.logGroupEvent(ctx, atype, group); err != nil {
+			return err
+		}
+	}
+
+	if !data.OverwriteExisting {
+		return nil
+	}
+
+	for id, group := range existing {
+		if synced[id] {
+			continue
+		}
+
+		if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+			return err
+		}
+
+		if err := oh.groups.Delete(ctx, group.Uuid); err != nil {
+			return err
+		}
+
+		if err := oh.logGroupEvent(ctx, model.EventGroupDeleted, group); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findMemberByEmail returns the membership of the user with the email in
+// the organization oUuid.
+func (oh *OrganizationHandler) findMemberByEmail(ctx context.Context, oUuid, email string) (*model.UserOrganization, error) {
+	user, err := oh.users.FindByEmail(ctx, strings.ToLower(email))
+	if err != nil {
+		return nil, err
+	}
+
+	return oh.uos.FindByUserAndOrg(ctx, user.Uuid, oUuid)
+}
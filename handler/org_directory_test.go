@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestDirectoryImport(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	known, _ := ts.addUser("known@example.com")
+	manual, _ := ts.addUser("manual@example.com")
+	gone, _ := ts.addUser("gone@example.com")
+	deleted, _ := ts.addUser("deleted@example.com")
+	manager, _ := ts.addUser("manager@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	knownUo := ts.addMember(org, known, model.UOTypeUser)
+	manualUo := ts.addMember(org, manual, model.UOTypeUser)
+	goneUo := ts.addMember(org, gone, model.UOTypeUser)
+	deletedUo := ts.addMember(org, deleted, model.UOTypeUser)
+	managerUo := ts.addMember(org, manager, model.UOTypeManager)
+
+	for uo, id := range map[*model.UserOrganization]string{knownUo: "old", goneUo: "gone", managerUo: "manager"} {
+		id := id
+		uo.ExternalId = &id
+	}
+
+	oldId := "old group"
+	oldGroup := &model.Group{Uuid: newTestUuid(t), OrgUuid: org.Uuid, Name: "old", ExternalId: &oldId}
+	manualGroup := &model.Group{Uuid: newTestUuid(t), OrgUuid: org.Uuid, Name: "manual"}
+	ts.db.groups[oldGroup.Uuid] = oldGroup
+	ts.db.groups[manualGroup.Uuid] = manualGroup
+
+	ts.expect(ts.do(http.MethodPost, "/api/organizations/"+org.Uuid+"/import", token, map[string]any{
+		"Members": []map[string]any{
+			{"Email": "Known@example.com", "ExternalId": "known"},
+			{"Email": "new@example.com", "ExternalId": "new"},
+			{"Email": deleted.Email, "ExternalId": "deleted", "Deleted": true},
+		},
+		"Groups": []map[string]any{
+			{"Name": "group", "ExternalId": "group", "MemberExternalIds": []string{"known", "new", "unknown"}},
+		},
+		"OverwriteExisting": true,
+	}), http.StatusOK)
+
+	if uo := ts.db.uos[knownUo.Uuid]; uo.ExternalId == nil || *uo.ExternalId != "known" {
+		t.Errorf("external id = %v, want known", uo.ExternalId)
+	}
+
+	// members the directory doesn't know anymore are removed, except the
+	// ones added by hand and those with more rights
+	for _, uo := range []*model.UserOrganization{goneUo, deletedUo} {
+		if _, ok := ts.db.uos[uo.Uuid]; ok {
+			t.Errorf("member %s kept", uo.UserUuid)
+		}
+	}
+	for _, uo := range []*model.UserOrganization{manualUo, managerUo} {
+		if _, ok := ts.db.uos[uo.Uuid]; !ok {
+			t.Errorf("member %s removed", uo.UserUuid)
+		}
+	}
+
+	user, err := memUsers{db: ts.db}.FindByEmail(context.Background(), "new@example.com")
+	if err != nil {
+		t.Fatalf("invited user not created: %v", err)
+	}
+
+	newUo, err := memUOs{db: ts.db}.FindByUserAndOrg(context.Background(), user.Uuid, org.Uuid)
+	if err != nil {
+		t.Fatalf("invited user not a member: %v", err)
+	}
+	if newUo.ExternalId == nil || *newUo.ExternalId != "new" || newUo.Atype != model.UOTypeUser {
+		t.Errorf("invited member = %+v", newUo)
+	}
+
+	if _, ok := ts.db.groups[oldGroup.Uuid]; ok {
+		t.Error("group missing from the directory kept")
+	}
+	if _, ok := ts.db.groups[manualGroup.Uuid]; !ok {
+		t.Error("group added by hand removed")
+	}
+
+	var group *model.Group
+	for _, g := range ts.db.groups {
+		if g.ExternalId != nil && *g.ExternalId == "group" {
+			group = g
+		}
+	}
+	if group == nil || group.Name != "group" {
+		t.Fatalf("groups = %v, want the imported group", ts.db.groups)
+	}
+
+	members := ts.db.groupMembers[group.Uuid]
+	if len(members) != 2 || members[0] != knownUo.Uuid || members[1] != newUo.Uuid {
+		t.Errorf("group members = %q, want [%s %s]", members, knownUo.Uuid, newUo.Uuid)
+	}
+
+	// without overwriting, the members and groups missing are kept
+	ts.expect(ts.do(http.MethodPost, "/api/organizations/"+org.Uuid+"/import", token, map[string]any{
+		"Members": []map[string]any{{"Email": known.Email, "ExternalId": "known"}},
+		"Groups":  []map[string]any{},
+	}), http.StatusOK)
+
+	if _, ok := ts.db.uos[newUo.Uuid]; !ok {
+		t.Error("member removed without overwriting")
+	}
+	if _, ok := ts.db.groups[group.Uuid]; !ok {
+		t.Error("group removed without overwriting")
+	}
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+type GroupData struct {
+	Name        string           `json:"Name"`
+	AccessAll   bool             `json:"AccessAll"`
+	ExternalId  *string          `json:"ExternalId"`
+	Collections []CollectionData `json:"Collections"`
+	Users       []string         `json:"Users"`
+}
+
+func (oh *OrganizationHandler) GetGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	groups, err := oh.groups.FindByOrg(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	data := make([]*response.GroupDetails, 0, len(groups))
+	for _, group := range groups {
+		cgs, err := oh.groups.FindCollections(ctx, group.Uuid)
+		if err != nil {
+			return err
+		}
+
+		data = append(data, response.NewGroupDetails(group, cgs))
+	}
+
+	return c.JSON(http.StatusOK, struct {
+		Data              []*response.GroupDetails `json:"Data"`
+		Object            string                   `json:"Object"`
+		ContinuationToken any                      `json:"ContinuationToken"`
+	}{
+		Data:              data,
+		Object:            "list",
+		ContinuationToken: nil,
+	})
+}
+
+func (oh *OrganizationHandler) PostGroups(c echo.Context) error {
+	oUuid := c.Param("ouuid")
+
+	data := new(GroupData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	gUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	group := &model.Group{
+		Uuid:    gUuid,
+		OrgUuid: oUuid,
+	}
+
+	return oh.saveGroup(c, group, data, true)
+}
+
+func (oh *OrganizationHandler) GetGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewGroup(group))
+}
+
+func (oh *OrganizationHandler) GetGroupDetails(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	cgs, err := oh.groups.FindCollections(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewGroupDetails(group, cgs))
+}
+
+func (oh *OrganizationHandler) PutGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	data := new(GroupData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	return oh.saveGroup(c, group, data, false)
+}
+
+func (oh *OrganizationHandler) saveGroup(c echo.Context, group *model.Group, data *GroupData, create bool) error {
+	cgs, err := oh.applyGroup(c.Request().Context(), group, data, create)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewGroupDetails(group, cgs))
+}
+
+// applyGroup applies data to the group, its collections and, for new groups
+// or when given, its members. It returns the collections of the group.
+func (oh *OrganizationHandler) applyGroup(ctx context.Context, group *model.Group, data *GroupData, create bool) ([]*model.CollectionGroup, error) {
+	if data.Name == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Group name is required")
+	}
+
+	group.Name = data.Name
+	group.AccessAll = data.AccessAll
+	group.ExternalId = data.ExternalId
+
+	cgs := make([]*model.CollectionGroup, 0, len(data.Collections))
+	for _, cl := range data.Collections {
+		if _, err := oh.cs.FindByCollectionOrg(ctx, cl.ID, group.OrgUuid); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Collection not found in Organization").SetInternal(err)
+		}
+
+		cgs = append(cgs, &model.CollectionGroup{
+			CollectionUuid: cl.ID,
+			GroupUuid:      group.Uuid,
+			ReadOnly:       cl.ReadOnly,
+			HidePasswords:  cl.HidePasswords,
+		})
+	}
+
+	// members losing access have to resync as well
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return nil, err
+	}
+
+	save := oh.groups.Save
+	if create {
+		save = oh.groups.Create
+	}
+
+	if err := save(ctx, group); err != nil {
+		return nil, err
+	}
+
+	if err := oh.groups.SaveCollections(ctx, group.Uuid, cgs); err != nil {
+		return nil, err
+	}
+
+	if create || data.Users != nil {
+		if err := oh.saveGroupMembers(ctx, group, data.Users); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return nil, err
+	}
+
+	atype := model.EventGroupUpdated
+	if create {
+		atype = model.EventGroupCreated
+	}
+
+	if err := oh.logGroupEvent(ctx, atype, group); err != nil {
+		return nil, err
+	}
+
+	return cgs, nil
+}
+
+func (oh *OrganizationHandler) DeleteGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.groups.Delete(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupDeleted, group); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetGroupUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	members, err := oh.groups.FindMembers(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+func (oh *OrganizationHandler) PutGroupUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, c.Param("ouuid"), c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	var data []string
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.saveGroupMembers(ctx, group, data); err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) DeleteGroupUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	group, err := oh.findGroup(ctx, oUuid, c.Param("guuid"))
+	if err != nil {
+		return err
+	}
+
+	uo, err := oh.findMember(ctx, oUuid, c.Param("uouuid"))
+	if err != nil {
+		return err
+	}
+
+	if err := oh.groups.DeleteMember(ctx, group.Uuid, uo.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetUserGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.findMember(ctx, c.Param("ouuid"), c.Param("uouuid"))
+	if err != nil {
+		return err
+	}
+
+	ids, err := oh.groups.FindGroupIds(ctx, uo.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ids)
+}
+
+type UserGroupsData struct {
+	GroupIds []string `json:"GroupIds"`
+}
+
+func (oh *OrganizationHandler) PutUserGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	uo, err := oh.findMember(ctx, oUuid, c.Param("uouuid"))
+	if err != nil {
+		return err
+	}
+
+	data := new(UserGroupsData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	for _, gUuid := range data.GroupIds {
+		if _, err := oh.findGroup(ctx, oUuid, gUuid); err != nil {
+			return err
+		}
+	}
+
+	if err := oh.groups.SaveMemberGroups(ctx, uo.Uuid, data.GroupIds); err != nil {
+		return err
+	}
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserUpdatedGroups, uo); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// findGroup returns the group gUuid of the organization oUuid.
+func (oh *OrganizationHandler) findGroup(ctx context.Context, oUuid, gUuid string) (*model.Group, error) {
+	group, err := oh.groups.FindByUuid(ctx, gUuid)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && group.OrgUuid != oUuid) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Group not found")
+	}
+
+	return group, err
+}
+
+// logGroupEvent records atype about the group.
+func (oh *OrganizationHandler) logGroupEvent(ctx context.Context, atype model.EventType, group *model.Group) error {
+	return logEvent(ctx, oh.events, &model.Event{
+		Atype:     atype,
+		OrgUuid:   &group.OrgUuid,
+		GroupUuid: &group.Uuid,
+	})
+}
+
+// saveGroupMembers replaces the members of the group, every member has to
+// belong to the group's organization.
+func (oh *OrganizationHandler) saveGroupMembers(ctx context.Context, group *model.Group, uoUuids []string) error {
+	for _, uoUuid := range uoUuids {
+		if _, err := oh.findMember(ctx, group.OrgUuid, uoUuid); err != nil {
+			return err
+		}
+	}
+
+	return oh.groups.SaveMembers(ctx, group.Uuid, uoUuids)
+}
+
+// touchGroupMembers bumps the revision of the group members so their
+// clients pick up the changed access on the next sync.
+func (oh *OrganizationHandler) touchGroupMembers(ctx context.Context, gUuid string) error {
+	members, err := oh.groups.FindMembers(ctx, gUuid)
+	if err != nil {
+		return err
+	}
+
+	for _, uoUuid := range members {
+		uo, err := oh.uos.FindByUuid(ctx, uoUuid)
+		if errors.Is(err, model.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
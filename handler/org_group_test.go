@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+)
+
+func TestGroups(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	member, _ := ts.addUser("member@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	uo := ts.addMember(org, member, model.UOTypeUser)
+	cl := ts.addCollection(org)
+
+	path := "/api/organizations/" + org.Uuid + "/groups"
+
+	member.UpdatedAt = time.Time{}
+
+	rec := ts.do(http.MethodPost, path, token, map[string]any{
+		"Name":        "group",
+		"Collections": []map[string]any{{"Id": cl.Uuid, "ReadOnly": true}},
+		"Users":       []string{uo.Uuid},
+	})
+	ts.expect(rec, http.StatusOK)
+
+	var group response.GroupDetails
+	decodeJSON(t, rec, &group)
+
+	if group.Name != "group" || len(group.Collections) != 1 || group.Collections[0].Id != cl.Uuid || !group.Collections[0].ReadOnly {
+		t.Fatalf("group = %+v", group)
+	}
+	if members := ts.db.groupMembers[group.Id]; len(members) != 1 || members[0] != uo.Uuid {
+		t.Errorf("members = %q, want [%s]", members, uo.Uuid)
+	}
+	if member.UpdatedAt.IsZero() {
+		t.Error("member revision not updated")
+	}
+	if events := ts.db.findEvents(model.EventGroupCreated); len(events) != 1 || *events[0].GroupUuid != group.Id {
+		t.Errorf("created events = %v", events)
+	}
+
+	var list struct{ Data []*response.GroupDetails }
+	rec = ts.do(http.MethodGet, path, token, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &list)
+	if len(list.Data) != 1 || list.Data[0].Id != group.Id || len(list.Data[0].Collections) != 1 {
+		t.Fatalf("groups = %+v, want the created group", list.Data)
+	}
+
+	// the members are kept when the update doesn't list them
+	ts.expect(ts.do(http.MethodPut, path+"/"+group.Id, token, map[string]any{
+		"Name":      "renamed",
+		"AccessAll": true,
+	}), http.StatusOK)
+
+	if g := ts.db.groups[group.Id]; g.Name != "renamed" || !g.AccessAll {
+		t.Errorf("group = %+v, want renamed with access to all", g)
+	}
+	if len(ts.db.groupCollections[group.Id]) != 0 {
+		t.Errorf("collections = %v, want none", ts.db.groupCollections[group.Id])
+	}
+	if len(ts.db.groupMembers[group.Id]) != 1 {
+		t.Errorf("members = %q, want the member kept", ts.db.groupMembers[group.Id])
+	}
+
+	var users []string
+	rec = ts.do(http.MethodGet, path+"/"+group.Id+"/users", token, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &users)
+	if len(users) != 1 || users[0] != uo.Uuid {
+		t.Errorf("group users = %q, want [%s]", users, uo.Uuid)
+	}
+
+	ts.expect(ts.do(http.MethodDelete, path+"/"+group.Id+"/users/"+uo.Uuid, token, nil), http.StatusOK)
+	if len(ts.db.groupMembers[group.Id]) != 0 {
+		t.Errorf("members = %q, want none", ts.db.groupMembers[group.Id])
+	}
+
+	userGroups := "/api/organizations/" + org.Uuid + "/users/" + uo.Uuid + "/groups"
+
+	ts.expect(ts.do(http.MethodPut, userGroups, token, map[string]any{"GroupIds": []string{group.Id}}), http.StatusOK)
+
+	var ids []string
+	rec = ts.do(http.MethodGet, userGroups, token, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &ids)
+	if len(ids) != 1 || ids[0] != group.Id {
+		t.Errorf("member groups = %q, want [%s]", ids, group.Id)
+	}
+
+	ts.expect(ts.do(http.MethodDelete, path+"/"+group.Id, token, nil), http.StatusOK)
+
+	if _, ok := ts.db.groups[group.Id]; ok {
+		t.Error("group not deleted")
+	}
+	ts.expect(ts.do(http.MethodGet, path+"/"+group.Id, token, nil), http.StatusNotFound)
+}
+
+func TestPostGroupsInvalid(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	stranger, _ := ts.addUser("stranger@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+
+	otherOrg := ts.addOrg(model.OrgLimits{})
+	foreign := ts.addCollection(otherOrg)
+	foreignMember := ts.addMember(otherOrg, stranger, model.UOTypeUser)
+
+	tests := []struct {
+		name string
+		data map[string]any
+		want int
+	}{
+		{"no name", map[string]any{"Name": ""}, http.StatusBadRequest},
+		{"collection of another org", map[string]any{"Name": "group", "Collections": []map[string]any{{"Id": foreign.Uuid}}}, http.StatusBadRequest},
+		{"member of another org", map[string]any{"Name": "group", "Users": []string{foreignMember.Uuid}}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.expect(ts.do(http.MethodPost, "/api/organizations/"+org.Uuid+"/groups", token, tt.data), tt.want)
+		})
+	}
+
+	// groups of other organizations can't be reached
+	group := &model.Group{Uuid: newTestUuid(t), OrgUuid: otherOrg.Uuid, Name: "group"}
+	ts.db.groups[group.Uuid] = group
+
+	ts.expect(ts.do(http.MethodGet, "/api/organizations/"+org.Uuid+"/groups/"+group.Uuid, token, nil), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodDelete, "/api/organizations/"+org.Uuid+"/groups/"+group.Uuid, token, nil), http.StatusNotFound)
+}
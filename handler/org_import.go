@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+type OrgKeysResponse struct {
+	PublicKey  *string `json:"PublicKey"`
+	PrivateKey *string `json:"PrivateKey"`
+	Object     string  `json:"Object"`
+}
+
+func newOrgKeysResponse(org *model.Organization) *OrgKeysResponse {
+	return &OrgKeysResponse{
+		PublicKey:  org.PublicKey,
+		PrivateKey: org.PrivateKey,
+		Object:     "organizationKeys",
+	}
+}
+
+func (oh *OrganizationHandler) GetOrgKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	org, err := oh.orgs.FindByUuid(ctx, c.Param("ouuid"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newOrgKeysResponse(org))
+}
+
+// PostOrgKeys sets the key pair of organizations created without one, the
+// keys can't be replaced once set.
+func (oh *OrganizationHandler) PostOrgKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(OrgKeyData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if data.EncryptedPrivateKey == "" || data.PublicKey == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Organization keys are required")
+	}
+
+	org, err := oh.orgs.FindByUuid(ctx, c.Param("ouuid"))
+	if err != nil {
+		return err
+	}
+
+	if org.PrivateKey != nil || org.PublicKey != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Organization Keys already exist")
+	}
+
+	org.PrivateKey = &data.EncryptedPrivateKey
+	org.PublicKey = &data.PublicKey
+
+	if err := oh.orgs.Save(ctx, org); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, newOrgKeysResponse(org))
+}
+
+type OrgImportData struct {
+	Ciphers                 []CipherData           `json:"Ciphers"`
+	Collections             []ImportCollectionData `json:"Collections"`
+	CollectionRelationships []RelationshipData     `json:"CollectionRelationships"`
+}
+
+type ImportCollectionData struct {
+	Id   *string `json:"Id"`
+	Name string  `json:"Name"`
+}
+
+// PostOrgImport imports the ciphers into the organization given by the
+// organizationId query. Collections with the id of an existing collection
+// are reused, the others are created.
+func (oh *OrganizationHandler) PostOrgImport(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(OrgImportData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	userOrg := auth.GetUserOrganization(c)
+	oUuid := userOrg.OrgUuid
+
+	// cipher index -> collection indexes
+	rs := make(map[int][]int)
+	for _, r := range data.CollectionRelationships {
+		if r.Key < 0 || r.Key >= len(data.Ciphers) || r.Value < 0 || r.Value >= len(data.Collections) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid collection relationship")
+		}
+
+		rs[r.Key] = append(rs[r.Key], r.Value)
+	}
+
+	err := oh.tx.WithTx(ctx, func(ctx context.Context) error {
+		cUuids := make([]string, 0, len(data.Collections))
+		for _, cd := range data.Collections {
+			cUuid, err := oh.importCollection(ctx, userOrg, cd)
+			if err != nil {
+				return err
+			}
+
+			cUuids = append(cUuids, cUuid)
+		}
+
+		for index, cd := range data.Ciphers {
+			cipher, err := cd.toCipher()
+			if err != nil {
+				return err
+			}
+
+			// ids of the exporting vault must not clash with existing ciphers
+			cipher.Uuid, err = crypto.GenerateUuid()
+			if err != nil {
+				return err
+			}
+
+			cipher.OrganizationUuid = &oUuid
+			cipher.UserUuid = nil
+
+			if err := oh.ciphers.Create(ctx, cipher); err != nil {
+				return err
+			}
+
+			if len(rs[index]) == 0 {
+				continue
+			}
+
+			ids := make([]string, 0, len(rs[index]))
+			for _, ci := range rs[index] {
+				ids = append(ids, cUuids[ci])
+			}
+
+			if err := oh.cs.SaveCipher(ctx, ids, cipher.Uuid); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := oh.touchOrgMembers(ctx, oUuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// importCollection returns the collection uuid of cd, creating the
+// collection when it isn't one of the organization yet.
+func (oh *OrganizationHandler) importCollection(ctx context.Context, userOrg *model.UserOrganization, cd ImportCollectionData) (string, error) {
+	if cd.Id != nil {
+		_, err := oh.cs.FindByCollectionOrg(ctx, *cd.Id, userOrg.OrgUuid)
+		if err == nil {
+			return *cd.Id, nil
+		}
+		if !errors.Is(err, model.ErrNotFound) {
+			return "", err
+		}
+	}
+
+	if err := oh.checkCollections(ctx, userOrg.OrgUuid); err != nil {
+		return "", err
+	}
+
+	cUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return "", err
+	}
+
+	cl := &model.Collection{
+		Uuid:    cUuid,
+		OrgUuid: userOrg.OrgUuid,
+		Name:    cd.Name,
+	}
+
+	if err := oh.cs.Save(ctx, cl); err != nil {
+		return "", err
+	}
+
+	if !userOrg.AccessAll {
+		if err := oh.ucs.Save(ctx, cUuid, userOrg.UserUuid, false, false); err != nil {
+			return "", err
+		}
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionCreated, cl); err != nil {
+		return "", err
+	}
+
+	return cUuid, nil
+}
+
+// touchOrgMembers bumps the revision of the confirmed members of the
+// organization.
+func (oh *OrganizationHandler) touchOrgMembers(ctx context.Context, oUuid string) error {
+	confirmed := model.UOStatusConfirmed
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid, Status: &confirmed})
+	if err != nil {
+		return err
+	}
+
+	for _, uo := range uos {
+		if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
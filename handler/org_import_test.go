@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestOrgKeys(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	member, memberToken := ts.addUser("member@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	ts.addMember(org, member, model.UOTypeUser)
+
+	path := "/api/organizations/" + org.Uuid + "/keys"
+
+	var keys OrgKeysResponse
+	rec := ts.do(http.MethodGet, path, memberToken, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &keys)
+	if keys.PublicKey != nil || keys.PrivateKey != nil {
+		t.Errorf("keys = %+v, want none", keys)
+	}
+
+	ts.expect(ts.do(http.MethodPost, path, ownerToken, map[string]any{"PublicKey": "public"}), http.StatusBadRequest)
+
+	ts.expect(ts.do(http.MethodPost, path, ownerToken, map[string]any{
+		"PublicKey":           "public",
+		"EncryptedPrivateKey": "private",
+	}), http.StatusOK)
+
+	rec = ts.do(http.MethodGet, path, memberToken, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &keys)
+	if keys.PublicKey == nil || *keys.PublicKey != "public" || keys.PrivateKey == nil || *keys.PrivateKey != "private" {
+		t.Errorf("keys = %+v, want the posted keys", keys)
+	}
+
+	// the keys can't be replaced
+	ts.expect(ts.do(http.MethodPost, path, ownerToken, map[string]any{
+		"PublicKey":           "other",
+		"EncryptedPrivateKey": "other",
+	}), http.StatusBadRequest)
+
+	if *ts.db.orgs[org.Uuid].PublicKey != "public" {
+		t.Errorf("public key = %s, want public", *ts.db.orgs[org.Uuid].PublicKey)
+	}
+}
+
+func TestOrgImport(t *testing.T) {
+	ts := newTestServer(t)
+
+	admin, adminToken := ts.addUser("admin@example.com")
+	member, memberToken := ts.addUser("member@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, admin, model.UOTypeAdmin)
+	ts.addMember(org, member, model.UOTypeUser)
+	existing := ts.addCollection(org, admin)
+
+	path := "/api/ciphers/import-organization?organizationId=" + org.Uuid
+
+	cipher := map[string]any{"Type": 1, "Name": "name", "Login": map[string]any{}}
+	data := map[string]any{
+		"Ciphers": []any{cipher, cipher, cipher},
+		"Collections": []map[string]any{
+			{"Id": existing.Uuid, "Name": "existing"},
+			{"Id": newTestUuid(t), "Name": "new"},
+		},
+		"CollectionRelationships": []map[string]int{
+			{"Key": 0, "Value": 0},
+			{"Key": 1, "Value": 0},
+			{"Key": 1, "Value": 1},
+		},
+	}
+
+	ts.expect(ts.do(http.MethodPost, path, memberToken, data), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodPost, path, adminToken, data), http.StatusOK)
+
+	var created *model.Collection
+	for _, cl := range ts.db.collections {
+		if cl.Uuid != existing.Uuid {
+			created = cl
+		}
+	}
+	if len(ts.db.collections) != 2 || created.Name != "new" || created.OrgUuid != org.Uuid {
+		t.Fatalf("collections = %v, want the existing one and new", ts.db.collections)
+	}
+
+	// the importing admin gets access to the created collection
+	var access bool
+	for _, uc := range ts.db.ucs {
+		access = access || (uc.CollectionUuid == created.Uuid && uc.UserUuid == admin.Uuid)
+	}
+	if !access {
+		t.Error("admin has no access to the created collection")
+	}
+
+	if len(ts.db.ciphers) != 3 {
+		t.Fatalf("imported %d ciphers, want 3", len(ts.db.ciphers))
+	}
+
+	var counts []int
+	for uuid, cipher := range ts.db.ciphers {
+		if cipher.UserUuid != nil || cipher.OrganizationUuid == nil || *cipher.OrganizationUuid != org.Uuid {
+			t.Errorf("cipher %s isn't owned by the organization", uuid)
+		}
+		counts = append(counts, len(ts.db.cipherCollections[uuid]))
+	}
+
+	sort.Ints(counts)
+	if counts[0] != 0 || counts[1] != 1 || counts[2] != 2 {
+		t.Errorf("collections per cipher = %v, want [0 1 2]", counts)
+	}
+}
+
+func TestOrgImportInvalid(t *testing.T) {
+	ts := newTestServer(t)
+
+	admin, token := ts.addUser("admin@example.com")
+
+	max := 1
+	org := ts.addOrg(model.OrgLimits{MaxCollections: &max})
+	ts.addMember(org, admin, model.UOTypeAdmin)
+	ts.addCollection(org, admin)
+
+	path := "/api/ciphers/import-organization?organizationId=" + org.Uuid
+
+	cipher := map[string]any{"Type": 1, "Name": "name", "Login": map[string]any{}}
+
+	tests := []struct {
+		name string
+		data map[string]any
+	}{
+		{"relationship out of range", map[string]any{
+			"Ciphers":                 []any{cipher},
+			"Collections":             []map[string]any{{"Name": "new"}},
+			"CollectionRelationships": []map[string]int{{"Key": 0, "Value": 1}},
+		}},
+		{"too many collections", map[string]any{
+			"Ciphers":     []any{},
+			"Collections": []map[string]any{{"Name": "new"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.expect(ts.do(http.MethodPost, path, token, tt.data), http.StatusBadRequest)
+		})
+	}
+
+	if len(ts.db.ciphers) != 0 || len(ts.db.collections) != 1 {
+		t.Errorf("stored %d ciphers and %d collections, want none added", len(ts.db.ciphers), len(ts.db.collections))
+	}
+}
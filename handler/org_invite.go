@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+)
+
+func (oh *OrganizationHandler) ReinviteUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	token, err := oh.reinviteUser(ctx, c.Param("ouuid"), c.Param("uouuid"), user.Email)
+	if err != nil {
+		return err
+	}
+
+	if oh.mailEnabled {
+		return c.NoContent(http.StatusOK)
+	}
+
+	// there is no mail to carry the token, the inviter hands it over
+	return c.JSON(http.StatusOK, response.NewInviteToken(token))
+}
+
+func (oh *OrganizationHandler) BulkReinviteUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	user := auth.GetUser(c)
+
+	var data OrgIDsData
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+
+	return oh.bulkResponse(c, data.IDs, func(id string) error {
+		_, err := oh.reinviteUser(ctx, oUuid, id, user.Email)
+		return err
+	})
+}
+
+// reinviteUser issues a new invite token for the invited membership. The
+// token goes out by mail, without mail it's returned to be handed over and
+// the pending invitation lets the invitee register without it.
+func (oh *OrganizationHandler) reinviteUser(ctx context.Context, oUuid, uoUuid, invitedBy string) (string, error) {
+	if !oh.cfgs.IsInvitationsAllowed() && !oh.mailEnabled {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "Invitations are not allowed.")
+	}
+
+	uo, err := oh.findMember(ctx, oUuid, uoUuid)
+	if err != nil {
+		return "", err
+	}
+
+	if uo.Status != model.UOStatusInvited {
+		return "", echo.NewHTTPError(http.StatusBadRequest, "The user has already accepted or been confirmed to the organization")
+	}
+
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := oh.auth.EncodeInvite(uo, user.Email, invitedBy)
+	if err != nil {
+		return "", err
+	}
+
+	if oh.mailEnabled {
+		// TODO: send the invite email with the token
+		return token, nil
+	}
+
+	if err := oh.is.Save(ctx, &model.Invitation{Email: user.Email}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+type AcceptData struct {
+	Token string `json:"Token"`
+}
+
+func (oh *OrganizationHandler) AcceptInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(AcceptData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+	uoUuid := c.Param("uouuid")
+
+	user := auth.GetUser(c)
+
+	claims, err := oh.auth.DecodeInvite(data.Token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid claim").SetInternal(err)
+	}
+
+	if claims.Subject != user.Email || claims.OrgId != oUuid || claims.UserOrgId != uoUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Claim does not match the invitation")
+	}
+
+	if err := oh.acceptInvite(ctx, oUuid, uoUuid, user); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// acceptInvite moves the user's invited membership to accepted, the org
+// admins still have to confirm it with the org key.
+func (oh *OrganizationHandler) acceptInvite(ctx context.Context, oUuid, uoUuid string, user *model.User) error {
+	uo, err := oh.findMember(ctx, oUuid, uoUuid)
+	if err != nil {
+		return err
+	}
+
+	if uo.UserUuid != user.Uuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "The invitation is for another user")
+	}
+
+	if uo.Status != model.UOStatusInvited {
+		return echo.NewHTTPError(http.StatusBadRequest, "User already accepted the invitation")
+	}
+
+	if err := oh.checkJoin(ctx, user.Uuid, oUuid, uo.Atype); err != nil {
+		return err
+	}
+
+	uo.Status = model.UOStatusAccepted
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	if err := oh.is.Delete(ctx, user.Email); err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	return oh.users.UpdateRevision(ctx, user.Uuid)
+}
+
+type ConfirmData struct {
+	Key string `json:"Key"`
+}
+
+func (oh *OrganizationHandler) ConfirmInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ConfirmData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	userOrg := auth.GetUserOrganization(c)
+
+	if err := oh.confirmInvite(ctx, c.Param("ouuid"), c.Param("uouuid"), data.Key, userOrg.Atype); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type BulkConfirmData struct {
+	Keys []struct {
+		Id  string `json:"Id"`
+		Key string `json:"Key"`
+	} `json:"Keys"`
+}
+
+func (oh *OrganizationHandler) BulkConfirmInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	userOrg := auth.GetUserOrganization(c)
+
+	data := new(BulkConfirmData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	keys := make(map[string]string, len(data.Keys))
+	ids := make([]string, 0, len(data.Keys))
+	for _, k := range data.Keys {
+		keys[k.Id] = k.Key
+		ids = append(ids, k.Id)
+	}
+
+	return oh.bulkResponse(c, ids, func(id string) error {
+		return oh.confirmInvite(ctx, oUuid, id, keys[id], userOrg.Atype)
+	})
+}
+
+// confirmInvite stores the org key encrypted for the member, completing
+// the invite.
+func (oh *OrganizationHandler) confirmInvite(ctx context.Context, oUuid, uoUuid, key string, confirmerType model.UOType) error {
+	if key == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid key provided")
+	}
+
+	uo, err := oh.findMember(ctx, oUuid, uoUuid)
+	if err != nil {
+		return err
+	}
+
+	if uo.Atype != model.UOTypeUser && confirmerType != model.UOTypeOwner {
+		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can confirm Managers, Admins or Owners")
+	}
+
+	if uo.Status != model.UOStatusAccepted {
+		return echo.NewHTTPError(http.StatusBadRequest, "User in invalid state")
+	}
+
+	uo.Status = model.UOStatusConfirmed
+	uo.AKey = &key
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserConfirmed, uo); err != nil {
+		return err
+	}
+
+	// TODO: send the confirmation email
+
+	return oh.users.UpdateRevision(ctx, uo.UserUuid)
+}
+
+func (oh *OrganizationHandler) BulkPublicKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	var data OrgIDsData
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+
+	keys := make([]*response.OrgUserPublicKey, 0, len(data.IDs))
+	for _, id := range data.IDs {
+		uo, err := oh.findMember(ctx, oUuid, id)
+		if err != nil {
+			continue
+		}
+
+		user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, response.NewOrgUserPublicKey(uo, user))
+	}
+
+	return c.JSON(http.StatusOK, struct {
+		Data              []*response.OrgUserPublicKey `json:"Data"`
+		Object            string                       `json:"Object"`
+		ContinuationToken any                          `json:"ContinuationToken"`
+	}{
+		Data:              keys,
+		Object:            "list",
+		ContinuationToken: nil,
+	})
+}
+
+// findMember returns the membership uoUuid of the organization oUuid.
+func (oh *OrganizationHandler) findMember(ctx context.Context, oUuid, uoUuid string) (*model.UserOrganization, error) {
+	uo, err := oh.uos.FindByUuid(ctx, uoUuid)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && uo.OrgUuid != oUuid) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "The specified user isn't a member of the organization")
+	}
+
+	return uo, err
+}
+
+// bulkResponse runs fn for every id and reports the rejected ones the way
+// the bulk endpoints of Bitwarden do.
+func (oh *OrganizationHandler) bulkResponse(c echo.Context, ids []string, fn func(id string) error) error {
+	type RespItem struct {
+		Object string `json:"Object"`
+		Id     string `json:"Id"`
+		Error  string `json:"Error"`
+	}
+
+	resp := make([]RespItem, 0, len(ids))
+	for _, id := range ids {
+		msg := ""
+		herr := echo.NewHTTPError(http.StatusBadRequest)
+		if err := fn(id); errors.As(err, &herr) {
+			msg = fmt.Sprintf("%s", herr.Message)
+		} else if err != nil {
+			return err
+		}
+
+		resp = append(resp, RespItem{
+			Object: "OrganizationBulkConfirmResponseModel",
+			Id:     id,
+			Error:  msg,
+		})
+	}
+
+	return c.JSON(http.StatusOK, struct {
+		Data              []RespItem `json:"Data"`
+		Object            string     `json:"Object"`
+		ContinuationToken any        `json:"ContinuationToken"`
+	}{
+		Data:              resp,
+		Object:            "list",
+		ContinuationToken: nil,
+	})
+}
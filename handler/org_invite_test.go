@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+// addInvite adds the user to the organization as an invited member.
+func (ts *testServer) addInvite(org *model.Organization, user *model.User, atype model.UOType) *model.UserOrganization {
+	uo := ts.addMember(org, user, atype)
+	uo.AKey = nil
+	uo.Status = model.UOStatusInvited
+
+	return uo
+}
+
+func TestReinviteAcceptConfirm(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	invitee, inviteeToken := ts.addUser("invitee@example.com")
+	_, otherToken := ts.addUser("other@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	uo := ts.addInvite(org, invitee, model.UOTypeUser)
+
+	users := "/api/organizations/" + org.Uuid + "/users/" + uo.Uuid
+
+	rec := ts.do(http.MethodPost, users+"/reinvite", ownerToken, nil)
+	ts.expect(rec, http.StatusOK)
+
+	var invite struct{ Token string }
+	decodeJSON(t, rec, &invite)
+
+	claims, err := ts.auth.DecodeInvite(invite.Token)
+	if err != nil {
+		t.Fatalf("DecodeInvite() error = %v", err)
+	}
+	if claims.Subject != invitee.Email || claims.OrgId != org.Uuid || claims.UserOrgId != uo.Uuid || claims.InvitedByEmail != owner.Email {
+		t.Errorf("invite claims = %+v", claims)
+	}
+
+	if !ts.db.invitations[invitee.Email] {
+		t.Errorf("no pending invitation without mail")
+	}
+
+	rec = ts.do(http.MethodPost, users+"/confirm", ownerToken, map[string]string{"Key": "org key"})
+	ts.expect(rec, http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, users+"/accept", otherToken, map[string]string{"Token": invite.Token})
+	ts.expect(rec, http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, users+"/accept", inviteeToken, map[string]string{"Token": "garbage"})
+	ts.expect(rec, http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, users+"/accept", inviteeToken, map[string]string{"Token": invite.Token})
+	ts.expect(rec, http.StatusOK)
+
+	if got := ts.db.uos[uo.Uuid].Status; got != model.UOStatusAccepted {
+		t.Fatalf("status after accept = %d, want accepted", got)
+	}
+	if ts.db.invitations[invitee.Email] {
+		t.Errorf("the invitation is left after accepting")
+	}
+
+	rec = ts.do(http.MethodPost, users+"/accept", inviteeToken, map[string]string{"Token": invite.Token})
+	ts.expect(rec, http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, users+"/reinvite", ownerToken, nil)
+	ts.expect(rec, http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, users+"/confirm", ownerToken, map[string]string{"Key": "org key"})
+	ts.expect(rec, http.StatusOK)
+
+	confirmed := ts.db.uos[uo.Uuid]
+	if confirmed.Status != model.UOStatusConfirmed || confirmed.AKey == nil || *confirmed.AKey != "org key" {
+		t.Errorf("membership after confirm = %d %v, want confirmed with the key", confirmed.Status, confirmed.AKey)
+	}
+}
+
+func TestRegisterInvited(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, _ := ts.addUser("owner@example.com")
+
+	open := ts.addOrg(model.OrgLimits{})
+	ts.addMember(open, owner, model.UOTypeOwner)
+
+	strict := ts.addOrg(model.OrgLimits{})
+	ts.addMember(strict, owner, model.UOTypeOwner)
+	ts.db.policies = append(ts.db.policies, &model.OrgPolicy{
+		Uuid:    newTestUuid(t),
+		OrgUuid: strict.Uuid,
+		Atype:   model.OPTypeTwoFactor,
+		Enabled: true,
+	})
+
+	// the placeholder user of an invited email has no password
+	invitee, _ := ts.addUser("invitee@example.com")
+	invitee.PasswordHash = nil
+	ts.db.invitations[invitee.Email] = true
+
+	joined := ts.addInvite(open, invitee, model.UOTypeUser)
+	refused := ts.addInvite(strict, invitee, model.UOTypeUser)
+
+	rec := ts.do(http.MethodPost, "/api/accounts/register", "", map[string]any{
+		"email":              invitee.Email,
+		"masterPasswordHash": "password",
+		"name":               "invitee",
+		"key":                "key",
+	})
+	ts.expect(rec, http.StatusOK)
+
+	if got := ts.db.uos[joined.Uuid].Status; got != model.UOStatusAccepted {
+		t.Errorf("status in the open org = %d, want accepted", got)
+	}
+
+	// the two step login policy keeps the invite pending
+	if got := ts.db.uos[refused.Uuid].Status; got != model.UOStatusInvited {
+		t.Errorf("status in the org requiring two step login = %d, want invited", got)
+	}
+
+	if len(ts.db.users[invitee.Uuid].PasswordHash) == 0 {
+		t.Errorf("the password wasn't set")
+	}
+}
+
+func TestRegisterInvitedWithToken(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, _ := ts.addUser("owner@example.com")
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+
+	// no pending invitation, only the token lets the invitee in
+	invitee, _ := ts.addUser("invitee@example.com")
+	invitee.PasswordHash = nil
+	uo := ts.addInvite(org, invitee, model.UOTypeUser)
+
+	token, err := ts.auth.EncodeInvite(uo, invitee.Email, owner.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register := func(token string) int {
+		rec := ts.do(http.MethodPost, "/api/accounts/register", "", map[string]any{
+			"email":              invitee.Email,
+			"masterPasswordHash": "password",
+			"name":               "invitee",
+			"key":                "key",
+			"token":              token,
+			"organizationUserId": uo.Uuid,
+		})
+
+		return rec.Code
+	}
+
+	if code := register(""); code != http.StatusBadRequest {
+		t.Errorf("status without a token = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := register(token + "x"); code != http.StatusBadRequest {
+		t.Errorf("status with a broken token = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := register(token); code != http.StatusOK {
+		t.Fatalf("status with the token = %d, want %d", code, http.StatusOK)
+	}
+
+	if got := ts.db.uos[uo.Uuid].Status; got != model.UOStatusAccepted {
+		t.Errorf("status = %d, want accepted", got)
+	}
+}
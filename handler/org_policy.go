@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+func (oh *OrganizationHandler) GetPolicies(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	policies, err := oh.ops.FindByOrg(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	resp := &struct {
+		Data              []*response.Policy `json:"Data"`
+		Object            string             `json:"Object"`
+		ContinuationToken any                `json:"ContinuationToken"`
+	}{
+		Data:              response.NewPolicies(policies),
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (oh *OrganizationHandler) GetPolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	ptype, err := policyType(c.Param("ptype"))
+	if err != nil {
+		return err
+	}
+
+	policy, err := oh.findPolicy(ctx, oUuid, ptype)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewPolicy(policy))
+}
+
+type PolicyData struct {
+	Enabled bool            `json:"enabled"`
+	Type    int             `json:"type"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func (oh *OrganizationHandler) PutPolicy(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(PolicyData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+
+	ptype, err := policyType(c.Param("ptype"))
+	if err != nil {
+		return err
+	}
+
+	pdata, err := ptype.ParseData(data.Data)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := oh.checkPolicyDependencies(ctx, oUuid, ptype, data.Enabled); err != nil {
+		return err
+	}
+
+	policy, err := oh.findPolicy(ctx, oUuid, ptype)
+	if err != nil {
+		return err
+	}
+
+	policy.Enabled = data.Enabled
+	policy.Data = pdata
+
+	if err := oh.ops.Save(ctx, policy); err != nil {
+		return err
+	}
+
+	if err := logEvent(ctx, oh.events, &model.Event{
+		Atype:      model.EventPolicyUpdated,
+		OrgUuid:    &policy.OrgUuid,
+		PolicyUuid: &policy.Uuid,
+	}); err != nil {
+		return err
+	}
+
+	if policy.Enabled {
+		if err := oh.enforcePolicy(ctx, policy); err != nil {
+			return err
+		}
+	}
+
+	return c.JSON(http.StatusOK, response.NewPolicy(policy))
+}
+
+// enforcePolicy removes the members a just enabled policy excludes: users
+// without two step login for TwoFactor, members of other organizations for
+// SingleOrg. Owners, admins and pending invites are left alone.
+func (oh *OrganizationHandler) enforcePolicy(ctx context.Context, policy *model.OrgPolicy) error {
+	if policy.Atype != model.OPTypeTwoFactor && policy.Atype != model.OPTypeSingleOrg {
+		return nil
+	}
+
+	members, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &policy.OrgUuid})
+	if err != nil {
+		return err
+	}
+
+	for _, uo := range members {
+		if uo.Atype == model.UOTypeOwner || uo.Atype == model.UOTypeAdmin || uo.Status == model.UOStatusInvited {
+			continue
+		}
+
+		var excluded bool
+		switch policy.Atype {
+		case model.OPTypeTwoFactor:
+			excluded, err = oh.missingTwoFactor(ctx, uo.UserUuid)
+		case model.OPTypeSingleOrg:
+			excluded, err = oh.inOtherOrgs(ctx, uo.UserUuid, uo.OrgUuid)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !excluded {
+			continue
+		}
+
+		if err := oh.removeMember(ctx, uo); err != nil {
+			return err
+		}
+
+		// TODO: tell the user by email why they were removed
+	}
+
+	return nil
+}
+
+// checkJoin enforces the SingleOrg and TwoFactor policies on a user joining
+// the organization as atype.
+func (oh *OrganizationHandler) checkJoin(ctx context.Context, uUuid, oUuid string, atype model.UOType) error {
+	single, err := oh.ops.FindBindingByUser(ctx, uUuid, model.OPTypeSingleOrg)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range single {
+		if p.OrgUuid != oUuid {
+			return echo.NewHTTPError(http.StatusBadRequest, "You cannot join this organization because you are a member of an organization which forbids it")
+		}
+	}
+
+	if atype == model.UOTypeOwner || atype == model.UOTypeAdmin {
+		return nil
+	}
+
+	policy, err := oh.findPolicy(ctx, oUuid, model.OPTypeSingleOrg)
+	if err != nil {
+		return err
+	}
+
+	if policy.Enabled {
+		other, err := oh.inOtherOrgs(ctx, uUuid, oUuid)
+		if err != nil {
+			return err
+		}
+
+		if other {
+			return echo.NewHTTPError(http.StatusBadRequest, "You cannot join this organization until you leave or remove all other organizations")
+		}
+	}
+
+	policy, err = oh.findPolicy(ctx, oUuid, model.OPTypeTwoFactor)
+	if err != nil {
+		return err
+	}
+
+	if policy.Enabled {
+		missing, err := oh.missingTwoFactor(ctx, uUuid)
+		if err != nil {
+			return err
+		}
+
+		if missing {
+			return echo.NewHTTPError(http.StatusBadRequest, "You cannot join this organization until you enable two-step login on your user account")
+		}
+	}
+
+	return nil
+}
+
+func (oh *OrganizationHandler) missingTwoFactor(ctx context.Context, uUuid string) (bool, error) {
+	tfs, err := oh.tfs.FindByUser(ctx, uUuid)
+	if err != nil {
+		return false, err
+	}
+
+	for _, tf := range tfs {
+		if tf.Enabled {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (oh *OrganizationHandler) inOtherOrgs(ctx context.Context, uUuid, oUuid string) (bool, error) {
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{UserUuid: &uUuid})
+	if err != nil {
+		return false, err
+	}
+
+	for _, uo := range uos {
+		if uo.OrgUuid != oUuid && uo.Status != model.UOStatusInvited {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// checkPolicyDependencies keeps SingleOrg enabled while a policy relying on
+// it is, as the Bitwarden server does.
+func (oh *OrganizationHandler) checkPolicyDependencies(ctx context.Context, oUuid string, ptype model.OrgPolicyType, enabled bool) error {
+	switch {
+	case enabled && (ptype == model.OPTypeRequireSso || ptype == model.OPTypeResetPassword):
+		single, err := oh.findPolicy(ctx, oUuid, model.OPTypeSingleOrg)
+		if err != nil {
+			return err
+		}
+
+		if !single.Enabled {
+			return echo.NewHTTPError(http.StatusBadRequest, "Single Organization policy not enabled.")
+		}
+	case !enabled && ptype == model.OPTypeSingleOrg:
+		for _, t := range []model.OrgPolicyType{model.OPTypeRequireSso, model.OPTypeResetPassword} {
+			p, err := oh.findPolicy(ctx, oUuid, t)
+			if err != nil {
+				return err
+			}
+
+			if p.Enabled {
+				return echo.NewHTTPError(http.StatusBadRequest, "Single Organization policy is required by another enabled policy.")
+			}
+		}
+	}
+
+	return nil
+}
+
+// findPolicy returns the policy of the organization, or a new disabled one
+// when it was never set.
+func (oh *OrganizationHandler) findPolicy(ctx context.Context, oUuid string, ptype model.OrgPolicyType) (*model.OrgPolicy, error) {
+	policy, err := oh.ops.FindByOrgAndType(ctx, oUuid, ptype)
+	if err == nil {
+		return policy, nil
+	}
+
+	if !errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OrgPolicy{
+		Uuid:    id,
+		OrgUuid: oUuid,
+		Atype:   ptype,
+		Data:    []byte("null"),
+	}, nil
+}
+
+func policyType(param string) (model.OrgPolicyType, error) {
+	n, err := strconv.Atoi(param)
+	if err != nil || !model.OrgPolicyType(n).Valid() {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid or unsupported policy type")
+	}
+
+	return model.OrgPolicyType(n), nil
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+)
+
+func TestPutPolicyData(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+
+	tests := []struct {
+		name  string
+		ptype model.OrgPolicyType
+		data  string
+		want  int
+		// the data stored, when it's saved
+		stored string
+	}{
+		{"known fields", model.OPTypeMasterPassword, `{"minLength": 12, "requireUpper": true}`, http.StatusOK, `{"minLength":12,"requireUpper":true}`},
+		{"unknown fields", model.OPTypeMasterPassword, `{"minLength": 12, "enforceOnLogin": true, "newField": [1, 2]}`, http.StatusOK, `{"minLength":12,"enforceOnLogin":true,"newField":[1,2]}`},
+		{"out of range", model.OPTypeMasterPassword, `{"minComplexity": 9}`, http.StatusBadRequest, ""},
+		{"wrong type", model.OPTypePasswordGenerator, `{"minLength": "long"}`, http.StatusBadRequest, ""},
+		{"without a schema", model.OPTypeSingleOrg, `{"anything": "goes"}`, http.StatusOK, `{"anything":"goes"}`},
+		{"no data", model.OPTypeSendOptions, `null`, http.StatusOK, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/organizations/" + org.Uuid + "/policies/" + strconv.Itoa(int(tt.ptype))
+
+			rec := ts.do(http.MethodPut, path, token, map[string]any{
+				"enabled": false,
+				"type":    tt.ptype,
+				"data":    json.RawMessage(tt.data),
+			})
+			ts.expect(rec, tt.want)
+
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			policy, err := memOrgPolicies{db: ts.db}.FindByOrgAndType(context.Background(), org.Uuid, tt.ptype)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(policy.Data) != tt.stored {
+				t.Errorf("stored data = %s, want %s", policy.Data, tt.stored)
+			}
+
+			var resp struct{ Data json.RawMessage }
+			decodeJSON(t, rec, &resp)
+
+			if string(resp.Data) != tt.stored {
+				t.Errorf("response data = %s, want %s", resp.Data, tt.stored)
+			}
+		})
+	}
+}
+
+// addPolicy enables the policy of atype in the organization.
+func (ts *testServer) addPolicy(org *model.Organization, atype model.OrgPolicyType, data string) *model.OrgPolicy {
+	policy := &model.OrgPolicy{Uuid: newTestUuid(ts.t), OrgUuid: org.Uuid, Atype: atype, Enabled: true}
+	if data != "" {
+		policy.Data = []byte(data)
+	}
+	ts.db.policies = append(ts.db.policies, policy)
+
+	return policy
+}
+
+func TestPersonalOwnershipPolicy(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	member, memberToken := ts.addUser("member@example.com")
+	_, outsiderToken := ts.addUser("outsider@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	ts.addMember(org, member, model.UOTypeUser)
+	ts.addPolicy(org, model.OPTypePersonalOwnership, "")
+
+	cipher := map[string]any{"Type": 1, "Name": "name", "Login": map[string]any{}}
+	imported := map[string]any{"Ciphers": []any{cipher}, "Folders": []any{}, "FolderRelationships": []any{}}
+
+	ts.expect(ts.do(http.MethodPost, "/api/ciphers", memberToken, cipher), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodPost, "/api/ciphers/import", memberToken, imported), http.StatusBadRequest)
+
+	// owners are exempt and the policy only binds the members
+	ts.expect(ts.do(http.MethodPost, "/api/ciphers", ownerToken, cipher), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/api/ciphers", outsiderToken, cipher), http.StatusOK)
+}
+
+func TestSingleOrgPolicy(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	member, _ := ts.addUser("member@example.com")
+	other, _ := ts.addUser("other@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	ts.addMember(org, member, model.UOTypeUser)
+	ts.addMember(org, other, model.UOTypeUser)
+
+	otherOrg := ts.addOrg(model.OrgLimits{})
+	ts.addMember(otherOrg, owner, model.UOTypeOwner)
+	otherMember := ts.addMember(otherOrg, other, model.UOTypeUser)
+
+	// enabling the policy removes the members of other organizations
+	ts.expect(ts.do(http.MethodPut, "/api/organizations/"+otherOrg.Uuid+"/policies/"+strconv.Itoa(int(model.OPTypeSingleOrg)), ownerToken, map[string]any{
+		"enabled": true,
+		"type":    model.OPTypeSingleOrg,
+		"data":    nil,
+	}), http.StatusOK)
+
+	if _, ok := ts.db.uos[otherMember.Uuid]; ok {
+		t.Error("member of another organization kept")
+	}
+
+	ts.addPolicy(org, model.OPTypeSingleOrg, "")
+
+	ts.expect(ts.do(http.MethodPost, "/api/organizations/"+otherOrg.Uuid+"/users/invite", ownerToken, map[string]any{
+		"Emails":    []string{member.Email},
+		"Type":      model.UOTypeUser,
+		"AccessAll": true,
+	}), http.StatusBadRequest)
+}
+
+func TestTwoFactorPolicy(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	without, _ := ts.addUser("without@example.com")
+	with, _ := ts.addUser("with@example.com")
+	invited, _ := ts.addUser("invited@example.com")
+	outsider, _ := ts.addUser("outsider@example.com")
+
+	ts.db.twoFactors = append(ts.db.twoFactors, &model.TwoFactor{Uuid: newTestUuid(t), UserUuid: with.Uuid, Enabled: true})
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	removed := ts.addMember(org, without, model.UOTypeUser)
+	kept := ts.addMember(org, with, model.UOTypeUser)
+	pending := ts.addMember(org, invited, model.UOTypeUser)
+	pending.Status = model.UOStatusInvited
+
+	ts.expect(ts.do(http.MethodPut, "/api/organizations/"+org.Uuid+"/policies/"+strconv.Itoa(int(model.OPTypeTwoFactor)), ownerToken, map[string]any{
+		"enabled": true,
+		"type":    model.OPTypeTwoFactor,
+		"data":    nil,
+	}), http.StatusOK)
+
+	if _, ok := ts.db.uos[removed.Uuid]; ok {
+		t.Error("member without two step login kept")
+	}
+	for _, uo := range []*model.UserOrganization{kept, pending} {
+		if _, ok := ts.db.uos[uo.Uuid]; !ok {
+			t.Errorf("member %s removed", uo.UserUuid)
+		}
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/api/organizations/"+org.Uuid+"/users/invite", ownerToken, map[string]any{
+		"Emails":    []string{outsider.Email},
+		"Type":      model.UOTypeUser,
+		"AccessAll": true,
+	}), http.StatusBadRequest)
+}
+
+func TestLoginMasterPasswordPolicy(t *testing.T) {
+	ts := newTestServer(t)
+
+	user, _ := ts.addUser("user@example.com")
+
+	for _, data := range []string{
+		`{"minLength": 12, "requireUpper": true}`,
+		`{"minLength": 8, "minComplexity": 3, "enforceOnLogin": true}`,
+	} {
+		org := ts.addOrg(model.OrgLimits{})
+		ts.addMember(org, user, model.UOTypeUser)
+		ts.addPolicy(org, model.OPTypeMasterPassword, data)
+	}
+
+	form := url.Values{
+		"username":         {user.Email},
+		"password":         {"password"},
+		"scope":            {"api offline_access"},
+		"client_id":        {"web"},
+		"deviceIdentifier": {newTestUuid(t)},
+		"deviceName":       {"test"},
+		"deviceType":       {"9"},
+	}
+
+	rec := ts.do(http.MethodPost, "/identity/connect/token", "", "grant_type=password&"+form.Encode())
+	ts.expect(rec, http.StatusOK)
+
+	var resp struct{ MasterPasswordPolicy *auth.MasterPasswordPolicy }
+	decodeJSON(t, rec, &resp)
+
+	p := resp.MasterPasswordPolicy
+	if p == nil {
+		t.Fatal("login response has no master password policy")
+	}
+
+	if p.MinLength == nil || *p.MinLength != 12 || p.MinComplexity == nil || *p.MinComplexity != 3 ||
+		!p.RequireUpper || p.RequireLower || !p.EnforceOnLogin {
+		t.Errorf("policy = %+v, want the policies merged", p)
+	}
+}
@@ -0,0 +1,568 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+// The public API is used by the automation of an organization with its API
+// key. The client acts with the rights of an admin: it can't grant the
+// owner type nor change or remove owners.
+
+type PublicAssociationData struct {
+	Id       string `json:"id"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+type PublicMemberData struct {
+	Email       string                  `json:"email"`
+	Type        model.UOType            `json:"type"`
+	AccessAll   bool                    `json:"accessAll"`
+	ExternalId  *string                 `json:"externalId"`
+	Collections []PublicAssociationData `json:"collections"`
+}
+
+type PublicGroupData struct {
+	Name        string                  `json:"name"`
+	AccessAll   bool                    `json:"accessAll"`
+	ExternalId  *string                 `json:"externalId"`
+	Collections []PublicAssociationData `json:"collections"`
+}
+
+type PublicIdsData struct {
+	Ids []string `json:"ids"`
+}
+
+func (oh *OrganizationHandler) GetPublicMembers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := auth.GetApiOrganization(c)
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	members := make([]*response.PublicMember, 0, len(uos))
+	for _, uo := range uos {
+		member, err := oh.publicMember(ctx, uo)
+		if errors.Is(err, model.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		members = append(members, member)
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicList(members, nil))
+}
+
+func (oh *OrganizationHandler) GetPublicMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.findMember(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	member, err := oh.publicMember(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, member)
+}
+
+func (oh *OrganizationHandler) PostPublicMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(PublicMemberData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if data.Email == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Email is required")
+	}
+
+	if err := checkPublicMemberType(data.Type); err != nil {
+		return err
+	}
+
+	uo := &model.UserOrganization{
+		OrgUuid:    auth.GetApiOrganization(c),
+		Atype:      data.Type,
+		AccessAll:  data.AccessAll,
+		ExternalId: data.ExternalId,
+	}
+
+	if err := oh.inviteMember(ctx, data.Email, uo); err != nil {
+		return err
+	}
+
+	// TODO: send email
+
+	if err := oh.savePublicMemberCollections(ctx, uo, data.Collections); err != nil {
+		return err
+	}
+
+	member, err := oh.publicMember(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, member)
+}
+
+func (oh *OrganizationHandler) PutPublicMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.publicEditableMember(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	data := new(PublicMemberData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if err := checkPublicMemberType(data.Type); err != nil {
+		return err
+	}
+
+	uo.Atype = data.Type
+	uo.AccessAll = data.AccessAll
+	uo.ExternalId = data.ExternalId
+
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	if err := oh.savePublicMemberCollections(ctx, uo, data.Collections); err != nil {
+		return err
+	}
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserUpdated, uo); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	member, err := oh.publicMember(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, member)
+}
+
+func (oh *OrganizationHandler) DeletePublicMember(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.publicEditableMember(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	if err := oh.removeMember(ctx, uo); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetPublicMemberGroupIds(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.findMember(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	ids, err := oh.groups.FindGroupIds(ctx, uo.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ids)
+}
+
+func (oh *OrganizationHandler) PutPublicMemberGroupIds(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := auth.GetApiOrganization(c)
+
+	uo, err := oh.findMember(ctx, oUuid, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	data := new(PublicIdsData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	for _, gUuid := range data.Ids {
+		if _, err := oh.findGroup(ctx, oUuid, gUuid); err != nil {
+			return err
+		}
+	}
+
+	if err := oh.groups.SaveMemberGroups(ctx, uo.Uuid, data.Ids); err != nil {
+		return err
+	}
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserUpdatedGroups, uo); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetPublicCollections(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := auth.GetApiOrganization(c)
+
+	collections, err := oh.cs.Find(ctx, &model.CollectionFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	grants, err := oh.collectionGroups(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	list := make([]*response.PublicCollection, 0, len(collections))
+	for _, cl := range collections {
+		list = append(list, response.NewPublicCollection(cl, grants[cl.Uuid]))
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicList(list, nil))
+}
+
+func (oh *OrganizationHandler) GetPublicCollection(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := auth.GetApiOrganization(c)
+
+	cl, err := oh.publicCollection(ctx, oUuid, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	grants, err := oh.collectionGroups(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicCollection(cl, grants[cl.Uuid]))
+}
+
+func (oh *OrganizationHandler) DeletePublicCollection(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	cl, err := oh.publicCollection(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	if err := oh.groups.DeleteAllByCollection(ctx, cl.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.cs.Delete(ctx, cl.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionDeleted, cl); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetPublicGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	groups, err := oh.groups.FindByOrg(ctx, auth.GetApiOrganization(c))
+	if err != nil {
+		return err
+	}
+
+	list := make([]*response.PublicGroup, 0, len(groups))
+	for _, group := range groups {
+		cgs, err := oh.groups.FindCollections(ctx, group.Uuid)
+		if err != nil {
+			return err
+		}
+
+		list = append(list, response.NewPublicGroup(group, cgs))
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicList(list, nil))
+}
+
+func (oh *OrganizationHandler) GetPublicGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	cgs, err := oh.groups.FindCollections(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicGroup(group, cgs))
+}
+
+func (oh *OrganizationHandler) PostPublicGroup(c echo.Context) error {
+	gUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	group := &model.Group{
+		Uuid:    gUuid,
+		OrgUuid: auth.GetApiOrganization(c),
+	}
+
+	return oh.savePublicGroup(c, group, true)
+}
+
+func (oh *OrganizationHandler) PutPublicGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return oh.savePublicGroup(c, group, false)
+}
+
+func (oh *OrganizationHandler) DeletePublicGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.groups.Delete(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupDeleted, group); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetPublicGroupMemberIds(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	members, err := oh.groups.FindMembers(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+func (oh *OrganizationHandler) PutPublicGroupMemberIds(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.findGroup(ctx, auth.GetApiOrganization(c), c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	data := new(PublicIdsData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.saveGroupMembers(ctx, group, data.Ids); err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// savePublicGroup applies the request body to the group, the members are
+// left untouched.
+func (oh *OrganizationHandler) savePublicGroup(c echo.Context, group *model.Group, create bool) error {
+	ctx := c.Request().Context()
+
+	data := new(PublicGroupData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	gd := &GroupData{
+		Name:        data.Name,
+		AccessAll:   data.AccessAll,
+		ExternalId:  data.ExternalId,
+		Collections: make([]CollectionData, 0, len(data.Collections)),
+	}
+
+	for _, cl := range data.Collections {
+		gd.Collections = append(gd.Collections, CollectionData{
+			ID:       cl.Id,
+			ReadOnly: cl.ReadOnly,
+		})
+	}
+
+	cgs, err := oh.applyGroup(ctx, group, gd, create)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewPublicGroup(group, cgs))
+}
+
+func (oh *OrganizationHandler) publicMember(ctx context.Context, uo *model.UserOrganization) (*response.PublicMember, error) {
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := oh.missingTwoFactor(ctx, uo.UserUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	var ucs model.UCList
+	if !uo.AccessAll {
+		ucs, err = oh.ucs.Find(ctx, &model.UCFilter{UserUuid: &uo.UserUuid, OrgUuid: &uo.OrgUuid})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response.NewPublicMember(uo, user, !missing, ucs), nil
+}
+
+// publicEditableMember returns the membership id of the organization oUuid
+// unless it belongs to an owner.
+func (oh *OrganizationHandler) publicEditableMember(ctx context.Context, oUuid, id string) (*model.UserOrganization, error) {
+	uo, err := oh.findMember(ctx, oUuid, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uo.Atype == model.UOTypeOwner {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Only Owners can edit Owner users")
+	}
+
+	return uo, nil
+}
+
+// savePublicMemberCollections replaces the direct collection grants of the
+// member, members with access to all collections have none.
+func (oh *OrganizationHandler) savePublicMemberCollections(ctx context.Context, uo *model.UserOrganization, collections []PublicAssociationData) error {
+	if err := oh.ucs.DeleteAllByUserAndOrg(ctx, uo.UserUuid, uo.OrgUuid); err != nil {
+		return err
+	}
+
+	if uo.AccessAll {
+		return nil
+	}
+
+	for _, cl := range collections {
+		collection, err := oh.cs.FindByCollectionOrg(ctx, cl.Id, uo.OrgUuid)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Collection not found in Organization").SetInternal(err)
+		}
+
+		if err := oh.ucs.Save(ctx, collection.Uuid, uo.UserUuid, cl.ReadOnly, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publicCollection returns the collection id of the organization oUuid.
+func (oh *OrganizationHandler) publicCollection(ctx context.Context, oUuid, id string) (*model.Collection, error) {
+	cl, err := oh.cs.FindByUuid(ctx, id)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && cl.OrgUuid != oUuid) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Collection not found")
+	}
+
+	return cl, err
+}
+
+// collectionGroups returns the group grants of the organization by
+// collection.
+func (oh *OrganizationHandler) collectionGroups(ctx context.Context, oUuid string) (map[string][]*model.CollectionGroup, error) {
+	groups, err := oh.groups.FindByOrg(ctx, oUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make(map[string][]*model.CollectionGroup)
+	for _, group := range groups {
+		cgs, err := oh.groups.FindCollections(ctx, group.Uuid)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cg := range cgs {
+			grants[cg.CollectionUuid] = append(grants[cg.CollectionUuid], cg)
+		}
+	}
+
+	return grants, nil
+}
+
+func checkPublicMemberType(atype model.UOType) error {
+	switch atype {
+	case model.UOTypeAdmin, model.UOTypeUser, model.UOTypeManager:
+		return nil
+	case model.UOTypeOwner:
+		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can grant Owner privileges")
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, "Invalid member type")
+}
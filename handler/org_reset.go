@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+type ResetPasswordEnrollmentData struct {
+	ResetPasswordKey   *string `json:"ResetPasswordKey"`
+	MasterPasswordHash string  `json:"MasterPasswordHash"`
+}
+
+// PutResetPasswordEnrollment lets members hand their user key, encrypted
+// with the org public key, to the organization. Without a key it withdraws
+// the enrollment.
+func (oh *OrganizationHandler) PutResetPasswordEnrollment(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ResetPasswordEnrollmentData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+
+	user := auth.GetUser(c)
+
+	uo, err := oh.uos.FindByUserAndOrg(ctx, user.Uuid, oUuid)
+	if errors.Is(err, model.ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "User to enroll isn't member of required organization")
+	}
+	if err != nil {
+		return err
+	}
+
+	if id := c.Param("uouuid"); id != user.Uuid && id != uo.Uuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Members can only enroll themselves")
+	}
+
+	policy, err := oh.resetPasswordPolicy(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	if data.ResetPasswordKey == nil {
+		rp, err := policy.ResetPassword()
+		if err != nil {
+			return err
+		}
+
+		if rp.AutoEnrollEnabled {
+			return echo.NewHTTPError(http.StatusBadRequest, "Reset password can't be withdrawn due to an enterprise policy")
+		}
+	} else {
+		ok := crypto.VerifyPassword(
+			data.MasterPasswordHash,
+			user.Salt,
+			user.PasswordHash,
+			user.PasswordIterations,
+		)
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid or wrong password")
+		}
+	}
+
+	uo.ResetPasswordKey = data.ResetPasswordKey
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	atype := model.EventOrgUserResetPasswordEnroll
+	if data.ResetPasswordKey == nil {
+		atype = model.EventOrgUserResetPasswordWithdraw
+	}
+
+	if err := oh.logMemberEvent(ctx, atype, uo); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetResetPasswordDetails(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	userOrg := auth.GetUserOrganization(c)
+
+	uo, err := oh.resetPasswordTarget(ctx, oUuid, c.Param("uouuid"), userOrg.Atype)
+	if err != nil {
+		return err
+	}
+
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return err
+	}
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, struct {
+		Kdf                 int     `json:"Kdf"`
+		KdfIterations       int     `json:"KdfIterations"`
+		ResetPasswordKey    *string `json:"ResetPasswordKey"`
+		EncryptedPrivateKey *string `json:"EncryptedPrivateKey"`
+		Object              string  `json:"Object"`
+	}{
+		Kdf:                 user.ClientKdfType,
+		KdfIterations:       user.ClientKdfIter,
+		ResetPasswordKey:    uo.ResetPasswordKey,
+		EncryptedPrivateKey: org.PrivateKey,
+		Object:              "organizationUserResetPasswordDetails",
+	})
+}
+
+type ResetPasswordData struct {
+	NewMasterPasswordHash string `json:"NewMasterPasswordHash"`
+	Key                   string `json:"Key"`
+}
+
+// PutResetPassword sets the master password of an enrolled member. The
+// client re-encrypted the member's user key with it, using the
+// ResetPasswordKey and the org private key.
+func (oh *OrganizationHandler) PutResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ResetPasswordData)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	if data.NewMasterPasswordHash == "" || data.Key == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "New master password and key are required")
+	}
+
+	userOrg := auth.GetUserOrganization(c)
+
+	uo, err := oh.resetPasswordTarget(ctx, c.Param("ouuid"), c.Param("uouuid"), userOrg.Atype)
+	if err != nil {
+		return err
+	}
+
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return err
+	}
+
+	pwHash := crypto.GeneratePassword(
+		data.NewMasterPasswordHash,
+		user.Salt,
+		user.PasswordIterations)
+
+	ss, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	uu := &model.UpdateUser{
+		Uuid:          user.Uuid,
+		PasswordHash:  pwHash,
+		Akey:          &data.Key,
+		SecurityStamp: &ss,
+	}
+
+	if err := oh.users.Update(ctx, uu); err != nil {
+		return err
+	}
+
+	// sessions with the old password end here
+	if err := oh.devices.DeleteAllByUser(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	// TODO: send the password reset email
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserAdminResetPassword, uo); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// resetPasswordTarget returns the member whose password the caller of type
+// callerType may reset: the policy is on, the member enrolled and only
+// owners reset the password of owners.
+func (oh *OrganizationHandler) resetPasswordTarget(ctx context.Context, oUuid, uoUuid string, callerType model.UOType) (*model.UserOrganization, error) {
+	if _, err := oh.resetPasswordPolicy(ctx, oUuid); err != nil {
+		return nil, err
+	}
+
+	uo, err := oh.findMember(ctx, oUuid, uoUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if uo.Status != model.UOStatusConfirmed || uo.ResetPasswordKey == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "User is not enrolled in account recovery")
+	}
+
+	if uo.Atype == model.UOTypeOwner && callerType != model.UOTypeOwner {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "Only owners can reset the password of other owners")
+	}
+
+	return uo, nil
+}
+
+// resetPasswordPolicy returns the enabled ResetPassword policy of the
+// organization.
+func (oh *OrganizationHandler) resetPasswordPolicy(ctx context.Context, oUuid string) (*model.OrgPolicy, error) {
+	policy, err := oh.findPolicy(ctx, oUuid, model.OPTypeResetPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if !policy.Enabled {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Reset password policy is not enabled")
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestPutResetPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		caller   model.UOType
+		target   model.UOType
+		enrolled bool
+		want     int
+	}{
+		{"admin resets an owner", model.UOTypeAdmin, model.UOTypeOwner, true, http.StatusForbidden},
+		{"owner resets an owner", model.UOTypeOwner, model.UOTypeOwner, true, http.StatusOK},
+		{"owner resets an admin", model.UOTypeOwner, model.UOTypeAdmin, true, http.StatusOK},
+		{"admin resets a user", model.UOTypeAdmin, model.UOTypeUser, true, http.StatusOK},
+		{"admin resets a member who isn't enrolled", model.UOTypeAdmin, model.UOTypeUser, false, http.StatusBadRequest},
+		{"manager resets a user", model.UOTypeManager, model.UOTypeUser, true, http.StatusUnauthorized},
+		{"user resets a user", model.UOTypeUser, model.UOTypeUser, true, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+
+			org := ts.addOrg(model.OrgLimits{})
+			ts.db.policies = append(ts.db.policies, &model.OrgPolicy{
+				Uuid:    newTestUuid(t),
+				OrgUuid: org.Uuid,
+				Atype:   model.OPTypeResetPassword,
+				Enabled: true,
+				Data:    []byte("{}"),
+			})
+
+			caller, token := ts.addUser("caller@example.com")
+			ts.addMember(org, caller, tt.caller)
+
+			user, userToken := ts.addUser("target@example.com")
+			hash := string(user.PasswordHash)
+			target := ts.addMember(org, user, tt.target)
+			if tt.enrolled {
+				key := "reset-key"
+				target.ResetPasswordKey = &key
+			}
+
+			path := "/api/organizations/" + org.Uuid + "/users/" + target.Uuid + "/reset-password"
+
+			rec := ts.do(http.MethodGet, path+"-details", token, nil)
+			ts.expect(rec, tt.want)
+
+			rec = ts.do(http.MethodPut, path, token, map[string]any{
+				"NewMasterPasswordHash": "new password",
+				"Key":                   "new key",
+			})
+			ts.expect(rec, tt.want)
+
+			reset := string(ts.db.users[user.Uuid].PasswordHash) != hash
+			if reset != (tt.want == http.StatusOK) {
+				t.Errorf("password changed = %v", reset)
+			}
+
+			if logged := len(ts.db.findEvents(model.EventOrgUserAdminResetPassword)); reset != (logged == 1) {
+				t.Errorf("logged %d reset events", logged)
+			}
+
+			// the sessions of the member end with the reset
+			if reset {
+				rec = ts.do(http.MethodGet, "/api/organizations/"+org.Uuid+"/policies", userToken, nil)
+				ts.expect(rec, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,794 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+)
+
+const scimContentType = "application/scim+json"
+
+var (
+	// scimFilter matches the only filters supported: attribute eq "value"
+	scimFilter = regexp.MustCompile(`^\s*(\w+)\s+(?i:eq)\s+"(.*)"\s*$`)
+
+	// scimMemberPath matches the member path of a group patch removing one
+	// member: members[value eq "id"]
+	scimMemberPath = regexp.MustCompile(`^members\[value (?i:eq) "(.*)"\]$`)
+)
+
+// requireScimKey authenticates the SCIM client of the organization by the
+// bearer token, which is the SCIM API key of the organization.
+func (oh *OrganizationHandler) requireScimKey(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := c.Request().Context()
+
+		c.Response().Header().Set(echo.HeaderContentType, scimContentType)
+
+		token := strings.TrimPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+
+		key, err := oh.apiKeys.FindByOrgAndType(ctx, c.Param("ouuid"), model.OrgApiKeyTypeScim)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		if key == nil || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(key.ApiKey)) != 1 {
+			return scimError(c, http.StatusUnauthorized, "Invalid API key")
+		}
+
+		return next(c)
+	}
+}
+
+type ScimQuery struct {
+	Filter     string `query:"filter"`
+	StartIndex int    `query:"startIndex"`
+	Count      int    `query:"count"`
+}
+
+// filter returns the lower cased attribute and the value of the query
+// filter, both empty without filter.
+func (q ScimQuery) filter() (string, string, bool) {
+	if q.Filter == "" {
+		return "", "", true
+	}
+
+	m := scimFilter.FindStringSubmatch(q.Filter)
+	if m == nil {
+		return "", "", false
+	}
+
+	return strings.ToLower(m[1]), m[2], true
+}
+
+// page returns the bounds of the requested page of total resources,
+// startIndex is 1-based.
+func (q ScimQuery) page(total int) (int, int) {
+	from := q.StartIndex - 1
+	if from < 0 {
+		from = 0
+	}
+	if from > total {
+		from = total
+	}
+
+	to := total
+	if q.Count > 0 && from+q.Count < total {
+		to = from + q.Count
+	}
+
+	return from, to
+}
+
+type ScimUserData struct {
+	UserName   string               `json:"userName"`
+	ExternalId *string              `json:"externalId"`
+	Active     *bool                `json:"active"`
+	Emails     []response.ScimEmail `json:"emails"`
+}
+
+// email returns the primary email of the user, falling back to its user
+// name.
+func (d ScimUserData) email() string {
+	for _, e := range d.Emails {
+		if e.Primary && e.Value != "" {
+			return e.Value
+		}
+	}
+
+	if len(d.Emails) > 0 && d.Emails[0].Value != "" {
+		return d.Emails[0].Value
+	}
+
+	return d.UserName
+}
+
+type ScimGroupData struct {
+	DisplayName string                `json:"displayName"`
+	ExternalId  *string               `json:"externalId"`
+	Members     []response.ScimMember `json:"members"`
+}
+
+type ScimPatchData struct {
+	Operations []ScimPatchOp `json:"Operations"`
+}
+
+type ScimPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (oh *OrganizationHandler) GetScimUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	query := new(ScimQuery)
+	if err := c.Bind(query); err != nil {
+		return err
+	}
+
+	attr, value, ok := query.filter()
+	if !ok || (attr != "" && attr != "username" && attr != "externalid") {
+		return scimError(c, http.StatusBadRequest, "Unsupported filter")
+	}
+
+	oUuid := c.Param("ouuid")
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	users := make([]*response.ScimUser, 0, len(uos))
+	for _, uo := range uos {
+		user, err := oh.scimUser(ctx, uo)
+		if errors.Is(err, model.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case attr == "username" && !strings.EqualFold(user.UserName, value):
+			continue
+		case attr == "externalid" && (user.ExternalId == nil || *user.ExternalId != value):
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	from, to := query.page(len(users))
+
+	return c.JSON(http.StatusOK, response.NewScimList(users[from:to], len(users), from+1, to-from))
+}
+
+func (oh *OrganizationHandler) GetScimUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.scimMember(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := oh.scimUser(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// PostScimUser invites the user to the organization.
+func (oh *OrganizationHandler) PostScimUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ScimUserData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	email := data.email()
+	if email == "" {
+		return scimError(c, http.StatusBadRequest, "Email is required")
+	}
+
+	oUuid := c.Param("ouuid")
+
+	_, err := oh.findMemberByEmail(ctx, oUuid, email)
+	if err == nil {
+		return scimError(c, http.StatusConflict, "User already exists")
+	}
+	if !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	uo := &model.UserOrganization{
+		OrgUuid:    oUuid,
+		Atype:      model.UOTypeUser,
+		ExternalId: data.ExternalId,
+	}
+
+	if err := oh.inviteMember(ctx, email, uo); err != nil {
+		return err
+	}
+
+	// TODO: send email
+
+	if data.Active != nil && !*data.Active {
+		if err := oh.revokeMember(ctx, uo); err != nil {
+			return err
+		}
+	}
+
+	user, err := oh.scimUser(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, user)
+}
+
+func (oh *OrganizationHandler) PutScimUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.scimMember(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	data := new(ScimUserData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if data.ExternalId != nil {
+		uo.ExternalId = data.ExternalId
+		if err := oh.uos.Save(ctx, uo); err != nil {
+			return err
+		}
+	}
+
+	if data.Active != nil {
+		if err := oh.setMemberActive(ctx, uo, *data.Active); err != nil {
+			return err
+		}
+	}
+
+	user, err := oh.scimUser(ctx, uo)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, user)
+}
+
+// PatchScimUser applies the replace operations on the active attribute,
+// the others are ignored.
+func (oh *OrganizationHandler) PatchScimUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	uo, err := oh.scimMember(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	data := new(ScimPatchData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	for _, op := range data.Operations {
+		if !strings.EqualFold(op.Op, "replace") {
+			continue
+		}
+
+		var raw json.RawMessage
+		switch strings.ToLower(op.Path) {
+		case "active":
+			raw = op.Value
+		case "":
+			var value struct {
+				Active json.RawMessage `json:"active"`
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return scimError(c, http.StatusBadRequest, "Invalid patch value")
+			}
+			raw = value.Active
+		}
+
+		if len(raw) == 0 {
+			continue
+		}
+
+		active, err := scimBool(raw)
+		if err != nil {
+			return scimError(c, http.StatusBadRequest, "Invalid active value")
+		}
+
+		if err := oh.setMemberActive(ctx, uo, active); err != nil {
+			return err
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oh *OrganizationHandler) DeleteScimUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	uo, err := oh.scimMember(ctx, oUuid, c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "User not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	// the directory acts with the rights of an owner
+	owner := model.UOTypeOwner
+	if err := oh.deleteUserOrg(ctx, uo.Uuid, oUuid, &owner); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oh *OrganizationHandler) GetScimGroups(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	query := new(ScimQuery)
+	if err := c.Bind(query); err != nil {
+		return err
+	}
+
+	attr, value, ok := query.filter()
+	if !ok || (attr != "" && attr != "displayname" && attr != "externalid") {
+		return scimError(c, http.StatusBadRequest, "Unsupported filter")
+	}
+
+	groups, err := oh.groups.FindByOrg(ctx, c.Param("ouuid"))
+	if err != nil {
+		return err
+	}
+
+	list := make([]*response.ScimGroup, 0, len(groups))
+	for _, group := range groups {
+		switch {
+		case attr == "displayname" && group.Name != value:
+			continue
+		case attr == "externalid" && (group.ExternalId == nil || *group.ExternalId != value):
+			continue
+		}
+
+		members, err := oh.groups.FindMembers(ctx, group.Uuid)
+		if err != nil {
+			return err
+		}
+
+		list = append(list, response.NewScimGroup(group, members))
+	}
+
+	from, to := query.page(len(list))
+
+	return c.JSON(http.StatusOK, response.NewScimList(list[from:to], len(list), from+1, to-from))
+}
+
+func (oh *OrganizationHandler) GetScimGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.scimGroup(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	members, err := oh.groups.FindMembers(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewScimGroup(group, members))
+}
+
+func (oh *OrganizationHandler) PostScimGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(ScimGroupData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if data.DisplayName == "" {
+		return scimError(c, http.StatusBadRequest, "Display name is required")
+	}
+
+	gUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	group := &model.Group{
+		Uuid:       gUuid,
+		OrgUuid:    c.Param("ouuid"),
+		Name:       data.DisplayName,
+		ExternalId: data.ExternalId,
+	}
+
+	if err := oh.groups.Create(ctx, group); err != nil {
+		return err
+	}
+
+	members := scimMemberIds(data.Members)
+	if err := oh.saveScimGroupMembers(ctx, group, members); err != nil {
+		return err
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupCreated, group); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, response.NewScimGroup(group, members))
+}
+
+func (oh *OrganizationHandler) PutScimGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.scimGroup(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	data := new(ScimGroupData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	if data.DisplayName != "" {
+		group.Name = data.DisplayName
+	}
+	if data.ExternalId != nil {
+		group.ExternalId = data.ExternalId
+	}
+
+	if err := oh.groups.Save(ctx, group); err != nil {
+		return err
+	}
+
+	if data.Members != nil {
+		if err := oh.saveScimGroupMembers(ctx, group, scimMemberIds(data.Members)); err != nil {
+			return err
+		}
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupUpdated, group); err != nil {
+		return err
+	}
+
+	members, err := oh.groups.FindMembers(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewScimGroup(group, members))
+}
+
+// PatchScimGroup applies the operations on the display name and the
+// members of the group.
+func (oh *OrganizationHandler) PatchScimGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.scimGroup(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	data := new(ScimPatchData)
+	if err := bindScim(c, data); err != nil {
+		return scimError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	current, err := oh.groups.FindMembers(ctx, group.Uuid)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]bool)
+	for _, m := range current {
+		members[m] = true
+	}
+
+	for _, op := range data.Operations {
+		opName := strings.ToLower(op.Op)
+		path := strings.ToLower(op.Path)
+
+		if m := scimMemberPath.FindStringSubmatch(op.Path); m != nil && opName == "remove" {
+			delete(members, m[1])
+			continue
+		}
+
+		switch {
+		case opName == "replace" && path == "displayname":
+			if err := json.Unmarshal(op.Value, &group.Name); err != nil {
+				return scimError(c, http.StatusBadRequest, "Invalid display name")
+			}
+		case opName == "replace" && path == "":
+			var value struct {
+				DisplayName *string `json:"displayName"`
+				ExternalId  *string `json:"externalId"`
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return scimError(c, http.StatusBadRequest, "Invalid patch value")
+			}
+			if value.DisplayName != nil {
+				group.Name = *value.DisplayName
+			}
+			if value.ExternalId != nil {
+				group.ExternalId = value.ExternalId
+			}
+		case path == "members":
+			var value []response.ScimMember
+			if len(op.Value) > 0 {
+				if err := json.Unmarshal(op.Value, &value); err != nil {
+					return scimError(c, http.StatusBadRequest, "Invalid members")
+				}
+			}
+
+			if opName == "replace" {
+				members = make(map[string]bool)
+			}
+
+			for _, m := range value {
+				members[m.Value] = opName != "remove"
+			}
+
+			// removing members without a value removes them all
+			if opName == "remove" && len(value) == 0 {
+				members = make(map[string]bool)
+			}
+		}
+	}
+
+	if err := oh.groups.Save(ctx, group); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(members))
+	for id, ok := range members {
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if err := oh.saveScimGroupMembers(ctx, group, ids); err != nil {
+		return err
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupUpdated, group); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (oh *OrganizationHandler) DeleteScimGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := oh.scimGroup(ctx, c.Param("ouuid"), c.Param("id"))
+	if errors.Is(err, model.ErrNotFound) {
+		return scimError(c, http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.groups.Delete(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.logGroupEvent(ctx, model.EventGroupDeleted, group); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// scimMember returns the membership id of the organization oUuid.
+func (oh *OrganizationHandler) scimMember(ctx context.Context, oUuid, id string) (*model.UserOrganization, error) {
+	uo, err := oh.uos.FindByUuid(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if uo.OrgUuid != oUuid {
+		return nil, model.ErrNotFound
+	}
+
+	return uo, nil
+}
+
+// scimGroup returns the group id of the organization oUuid.
+func (oh *OrganizationHandler) scimGroup(ctx context.Context, oUuid, id string) (*model.Group, error) {
+	group, err := oh.groups.FindByUuid(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.OrgUuid != oUuid {
+		return nil, model.ErrNotFound
+	}
+
+	return group, nil
+}
+
+func (oh *OrganizationHandler) scimUser(ctx context.Context, uo *model.UserOrganization) (*response.ScimUser, error) {
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.NewScimUser(uo, user), nil
+}
+
+// saveScimGroupMembers replaces the members of the group and bumps the
+// revision of the members before and after.
+func (oh *OrganizationHandler) saveScimGroupMembers(ctx context.Context, group *model.Group, uoUuids []string) error {
+	if err := oh.touchGroupMembers(ctx, group.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.saveGroupMembers(ctx, group, uoUuids); err != nil {
+		return err
+	}
+
+	return oh.touchGroupMembers(ctx, group.Uuid)
+}
+
+// setMemberActive revokes or restores the membership.
+func (oh *OrganizationHandler) setMemberActive(ctx context.Context, uo *model.UserOrganization, active bool) error {
+	revoked := uo.Status == model.UOStatusRevoked
+
+	switch {
+	case active && revoked:
+		return oh.restoreMember(ctx, uo)
+	case !active && !revoked:
+		return oh.revokeMember(ctx, uo)
+	}
+
+	return nil
+}
+
+// revokeMember takes the access of the member to the organization away
+// while keeping the membership, so it can be restored later.
+func (oh *OrganizationHandler) revokeMember(ctx context.Context, uo *model.UserOrganization) error {
+	if uo.Atype == model.UOTypeOwner {
+		owner, confirmed := model.UOTypeOwner, model.UOStatusConfirmed
+		owners, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &uo.OrgUuid, Atype: &owner, Status: &confirmed})
+		if err != nil {
+			return err
+		}
+
+		if len(owners) <= 1 && uo.Status == model.UOStatusConfirmed {
+			return echo.NewHTTPError(http.StatusBadRequest, "Can't revoke the last owner")
+		}
+	}
+
+	uo.Status = model.UOStatusRevoked
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return oh.logMemberEvent(ctx, model.EventOrgUserRevoked, uo)
+}
+
+// restoreMember gives a revoked member the status it would have had: a
+// member holding the org key is confirmed, one with an account accepted.
+func (oh *OrganizationHandler) restoreMember(ctx context.Context, uo *model.UserOrganization) error {
+	user, err := oh.users.FindByUuid(ctx, uo.UserUuid)
+	if err != nil {
+		return err
+	}
+
+	status := model.UOStatusAccepted
+	switch {
+	case uo.AKey != nil:
+		status = model.UOStatusConfirmed
+	case len(user.PasswordHash) == 0:
+		status = model.UOStatusInvited
+	}
+
+	if status != model.UOStatusInvited {
+		if err := oh.checkJoin(ctx, uo.UserUuid, uo.OrgUuid, uo.Atype); err != nil {
+			return err
+		}
+	}
+
+	if err := oh.checkSeats(ctx, uo.OrgUuid); err != nil {
+		return err
+	}
+
+	uo.Status = status
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return oh.logMemberEvent(ctx, model.EventOrgUserRestored, uo)
+}
+
+func scimMemberIds(members []response.ScimMember) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+
+	return ids
+}
+
+// scimBool decodes a SCIM boolean, Azure AD sends them as the strings
+// "True" and "False".
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return false, err
+	}
+
+	return strconv.ParseBool(s)
+}
+
+// bindScim decodes the SCIM JSON body, which the default binder rejects
+// for its content type.
+func bindScim(c echo.Context, v any) error {
+	return json.NewDecoder(c.Request().Body).Decode(v)
+}
+
+func scimError(c echo.Context, status int, detail string) error {
+	return c.JSON(status, response.NewScimError(status, detail))
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+// The requests below replay what Okta and Azure AD send to a SCIM server.
+
+// newScimServer returns a test server with an organization holding a SCIM
+// key, and that key.
+func newScimServer(t *testing.T) (*testServer, *model.Organization, string) {
+	ts := newTestServer(t)
+
+	org := ts.addOrg(model.OrgLimits{})
+	key := &model.OrgApiKey{Uuid: newTestUuid(t), OrgUuid: org.Uuid, Atype: model.OrgApiKeyTypeScim, ApiKey: "scim-key"}
+	ts.db.apiKeys = append(ts.db.apiKeys, key)
+
+	return ts, org, key.ApiKey
+}
+
+func TestScimAuth(t *testing.T) {
+	ts, org, key := newScimServer(t)
+	other := ts.addOrg(model.OrgLimits{})
+
+	tests := []struct {
+		name  string
+		org   string
+		token string
+		want  int
+	}{
+		{"key", org.Uuid, key, http.StatusOK},
+		{"missing key", org.Uuid, "", http.StatusUnauthorized},
+		{"wrong key", org.Uuid, "wrong", http.StatusUnauthorized},
+		{"key of another organization", other.Uuid, key, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(http.MethodGet, "/scim/v2/"+tt.org+"/Users", tt.token, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetScimUsersFilter(t *testing.T) {
+	ts, org, key := newScimServer(t)
+
+	jane, _ := ts.addUser("jane@example.com")
+	uo := ts.addMember(org, jane, model.UOTypeUser)
+	externalId := "00u1abcd2EFGH3ijk4l5"
+	uo.ExternalId = &externalId
+
+	john, _ := ts.addUser("john@example.com")
+	ts.addMember(org, john, model.UOTypeUser)
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+		// number of users found
+		total int
+	}{
+		{"okta user name", `filter=userName%20eq%20%22jane%40example.com%22&startIndex=1&count=100`, http.StatusOK, 1},
+		{"azure user name", `filter=userName+eq+%22Jane%40Example.com%22`, http.StatusOK, 1},
+		{"azure external id", `filter=externalId+eq+%2200u1abcd2EFGH3ijk4l5%22`, http.StatusOK, 1},
+		{"upper case operator", `filter=userName%20EQ%20%22jane%40example.com%22`, http.StatusOK, 1},
+		{"unknown user", `filter=userName%20eq%20%22nobody%40example.com%22`, http.StatusOK, 0},
+		{"no filter", `startIndex=1&count=100`, http.StatusOK, 2},
+		{"page", `startIndex=2&count=1`, http.StatusOK, 2},
+		{"azure email filter", `filter=emails%5Btype+eq+%22work%22%5D.value+eq+%22jane%40example.com%22`, http.StatusBadRequest, 0},
+		{"unsupported attribute", `filter=displayName%20eq%20%22jane%22`, http.StatusBadRequest, 0},
+		{"unsupported operator", `filter=userName%20sw%20%22jane%22`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := ts.do(http.MethodGet, "/scim/v2/"+org.Uuid+"/Users?"+tt.query, key, nil)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var list struct {
+				TotalResults int
+				Resources    []struct {
+					Id       string `json:"id"`
+					UserName string `json:"userName"`
+				}
+			}
+			decodeJSON(t, rec, &list)
+
+			if list.TotalResults != tt.total {
+				t.Errorf("total = %d, want %d", list.TotalResults, tt.total)
+			}
+			if tt.total == 1 && (len(list.Resources) != 1 || list.Resources[0].Id != uo.Uuid) {
+				t.Errorf("resources = %+v, want %s", list.Resources, uo.Uuid)
+			}
+		})
+	}
+}
+
+func TestPatchScimUserActive(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+		// status of the member after the patch
+		status model.UOStatus
+	}{
+		{
+			"okta deactivation",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","value":{"active":false}}]}`,
+			http.StatusNoContent, model.UOStatusRevoked,
+		},
+		{
+			"azure deactivation",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Replace","path":"active","value":"False"}]}`,
+			http.StatusNoContent, model.UOStatusRevoked,
+		},
+		{
+			"azure deactivation with a boolean",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`,
+			http.StatusNoContent, model.UOStatusRevoked,
+		},
+		{
+			"azure attribute update",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Replace","path":"displayName","value":"Jane Doe"},{"op":"Add","path":"externalId","value":"jane"}]}`,
+			http.StatusNoContent, model.UOStatusConfirmed,
+		},
+		{
+			"okta activation of an active member",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","value":{"active":true}}]}`,
+			http.StatusNoContent, model.UOStatusConfirmed,
+		},
+		{
+			"invalid active value",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":"maybe"}]}`,
+			http.StatusBadRequest, model.UOStatusConfirmed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, org, key := newScimServer(t)
+
+			owner, _ := ts.addUser("owner@example.com")
+			ts.addMember(org, owner, model.UOTypeOwner)
+
+			jane, _ := ts.addUser("jane@example.com")
+			uo := ts.addMember(org, jane, model.UOTypeUser)
+
+			rec := ts.do(http.MethodPatch, "/scim/v2/"+org.Uuid+"/Users/"+uo.Uuid, key, tt.body)
+			ts.expect(rec, tt.want)
+
+			if got := ts.db.uos[uo.Uuid].Status; got != tt.status {
+				t.Errorf("member status = %d, want %d", got, tt.status)
+			}
+
+			revoked := len(ts.db.findEvents(model.EventOrgUserRevoked))
+			if (tt.status == model.UOStatusRevoked) != (revoked == 1) {
+				t.Errorf("logged %d revoked events", revoked)
+			}
+		})
+	}
+}
+
+func TestPatchScimGroupMembers(t *testing.T) {
+	tests := []struct {
+		name string
+		// body is a format with the uuids of the members jane and john
+		body string
+		want []string
+	}{
+		{
+			"okta member removal",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"remove","path":"members[value eq \"%[1]s\"]"}]}`,
+			[]string{"john"},
+		},
+		{
+			"azure member removal",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Remove","path":"members","value":[{"value":"%[2]s"}]}]}`,
+			[]string{"jane"},
+		},
+		{
+			"okta member replacement",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"members","value":[{"value":"%[3]s","display":"joe@example.com"}]}]}`,
+			[]string{"joe"},
+		},
+		{
+			"azure member addition",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Add","path":"members","value":[{"value":"%[3]s"}]}]}`,
+			[]string{"jane", "joe", "john"},
+		},
+		{
+			"removal of an unknown member",
+			`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"remove","path":"members[value eq \"unknown\"]"}]}`,
+			[]string{"jane", "john"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, org, key := newScimServer(t)
+
+			members := make(map[string]string)
+			names := make(map[string]string)
+			for _, name := range []string{"jane", "john", "joe"} {
+				user, _ := ts.addUser(name + "@example.com")
+				uo := ts.addMember(org, user, model.UOTypeUser)
+				members[name] = uo.Uuid
+				names[uo.Uuid] = name
+			}
+
+			group := &model.Group{Uuid: newTestUuid(t), OrgUuid: org.Uuid, Name: "group"}
+			ts.db.groups[group.Uuid] = group
+			ts.db.groupMembers[group.Uuid] = []string{members["jane"], members["john"]}
+
+			body := fmt.Sprintf(tt.body, members["jane"], members["john"], members["joe"])
+
+			rec := ts.do(http.MethodPatch, "/scim/v2/"+org.Uuid+"/Groups/"+group.Uuid, key, body)
+			ts.expect(rec, http.StatusNoContent)
+
+			var got []string
+			for _, id := range ts.db.groupMembers[group.Uuid] {
+				got = append(got, names[id])
+			}
+			sort.Strings(got)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("members = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("members = %v, want %v", got, tt.want)
+				}
+			}
+
+			if updated := len(ts.db.findEvents(model.EventGroupUpdated)); updated != 1 {
+				t.Errorf("logged %d group updated events, want 1", updated)
+			}
+		})
+	}
+}
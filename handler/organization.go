@@ -1,1125 +1,1379 @@
-package handler
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"net/http"
-	"strings"
-	"time"
-
-	"github.com/labstack/echo/v4"
-
-	"github.com/togls/gowarden/auth"
-	"github.com/togls/gowarden/config"
-	"github.com/togls/gowarden/handler/response"
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/pkg/crypto"
-	"github.com/togls/gowarden/store"
-)
-
-type OrganizationHandler struct {
-	users   store.User
-	ciphers store.Cipher
-	orgs    store.Organization
-	cs      store.Collection
-	ops     store.OrgPolicy
-	uos     store.UserOrganization
-	ucs     store.UserCollection
-	is      store.Invitation
-
-	auth *auth.Core
-	cfgs *config.Core
-}
-
-func NewOrganizationHandler(
-	users store.User,
-	ciphers store.Cipher,
-	orgs store.Organization,
-	cs store.Collection,
-	ops store.OrgPolicy,
-	uos store.UserOrganization,
-	ucs store.UserCollection,
-	is store.Invitation,
-	auth *auth.Core,
-	cfgs *config.Core,
-) *OrganizationHandler {
-	return &OrganizationHandler{
-		users:   users,
-		ciphers: ciphers,
-		orgs:    orgs,
-		cs:      cs,
-		ops:     ops,
-		uos:     uos,
-		ucs:     ucs,
-		is:      is,
-		auth:    auth,
-		cfgs:    cfgs,
-	}
-}
-
-func (oh *OrganizationHandler) Routes(e *echo.Echo) {
-	e.GET("/api/ciphers/organization-details", oh.GetOrgDetails, oh.auth.RequireAuth)
-
-	{
-		cl := e.Group("/api/collections")
-		cl.GET("", oh.GetUserCollections, oh.auth.RequireAuth)
-	}
-
-	{ // base auth
-		org := e.Group("/api/organizations", oh.auth.RequireAuth)
-		org.POST("", oh.CreateOrganization)
-		org.POST("/:uuid/leave", oh.LeaveOrganization)
-	}
-
-	{ // owner
-		org := e.Group("/api/organizations", oh.auth.RequireOwnerAuth)
-		org.GET("/:uuid", oh.GetOrganization)
-		org.DELETE(":uuid", oh.DeleteOrganization)
-		org.POST("/:uuid/delete", oh.PostDeleteOrganization)
-		org.PUT("/:uuid", oh.PutOrganization)
-		org.POST("/:uuid", oh.PostOrganization)
-	}
-
-	{ // ManagerLoose
-		org := e.Group("/api/organizations", oh.auth.RequireManagerLooseAuth)
-		org.GET("/:uuid/collections", oh.GetOrgCollections)
-		org.POST("/:uuid/collections", oh.PostOrganizationCollections)
-		org.GET("/:ouuid/users", oh.GetOrgUsers)
-	}
-
-	{ // Manager
-		org := e.Group("/api/organizations", oh.auth.RequireManagerAuth)
-		org.GET("/:ouuid/collections/:cuuid/details", oh.GetOrgCollectionDetail)
-		org.GET("/:ouuid/collections/:cuuid/users", oh.GetCollectionUsers)
-		org.PUT("/:ouuid/collections/:cuuid/users", oh.PutCollectionUsers)
-		org.POST("/:ouuid/collections/:cuuid", oh.PostOrganizationCollectionUpdate)
-		org.PUT("/:ouuid/collections/:cuuid", oh.PutOrganizationCollectionUpdate)
-		org.DELETE("/:ouuid/collections/:cuuid", oh.DeleteOrganizationCollection)
-		org.POST("/:ouuid/collections/:cuuid/delete", oh.PostOrganizationCollectionDelete)
-	}
-
-	{ // require admin
-		org := e.Group("/api/organizations", oh.auth.RequireAdminAuth)
-		org.DELETE("/:ouuid/collections/:cuuid/users/:uouuid", oh.DeleteOrganizationCollectionUser)
-		org.POST("/:ouuid/collections/:cuuid/delete-users/:uouuid", oh.PostOrganizationCollectionDeleteUser)
-		org.POST("/:ouuid/users/invite", oh.SendInvite)
-		org.GET("/:ouuid/users/:uouuid", oh.GetUser)
-		org.POST("/:ouuid/users/:uouuid", oh.EditUser)
-		org.PUT("/:ouuid/users/:uouuid", oh.PutUser)
-		org.DELETE("/:ouuid/users/:uouuid", oh.DeleteUser)
-		org.DELETE("/:ouuid/users", oh.BulkDeleteUser)
-		org.POST("/:ouuid/users/:uouuid/delete", oh.PostDeleteUser)
-	}
-
-	// TODO:
-	// reinvite_user
-	// bulk_reinvite_user
-	// confirm_invite
-	// bulk_confirm_invite
-	// accept_invite
-	// post_org_import,
-	// list_policies,
-	// list_policies_token,
-	// get_policy,
-	// put_policy,
-	// get_organization_tax,
-	// get_plans,
-	// get_plans_tax_rates,
-	// import,
-	// post_org_keys,
-	// bulk_public_keys,
-}
-
-func (oh *OrganizationHandler) GetOrganization(c echo.Context) error {
-	oUuid := c.Param("uuid")
-
-	organization, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Can't find organization details").SetInternal(err)
-	}
-
-	return c.JSON(http.StatusOK, organization)
-}
-
-type OrgData struct {
-	BillingEmail   string      `json:"BillingEmail"`
-	CollectionName string      `json:"CollectionName"`
-	Key            *string     `json:"Key"`
-	Name           string      `json:"Name"`
-	Keys           *OrgKeyData `json:"Keys"`
-}
-
-type OrgKeyData struct {
-	EncryptedPrivateKey string `json:"EncryptedPrivateKey"`
-	PublicKey           string `json:"PublicKey"`
-}
-
-func (oh *OrganizationHandler) CreateOrganization(c echo.Context) error {
-	data := new(OrgData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	user := auth.GetUser(c)
-
-	user, err := oh.users.FindByUuid(user.Uuid)
-	if err != nil {
-		return err
-	}
-
-	if !oh.cfgs.IsOrgCreationAllowed(user.Email) {
-		return echo.NewHTTPError(http.StatusBadRequest, "User not allowed to create organizations")
-	}
-
-	ps, err := oh.ops.FindConfirmedByUser(user.Uuid)
-	if err != nil {
-		return err
-	}
-
-	applicable := func() bool {
-		for _, p := range ps {
-			if p.Enabled && p.Atype == model.OPTypeSingleOrg {
-				oUuid := p.OrgUuid
-				uo, err := oh.uos.FindByUserAndOrg(user.Uuid, oUuid)
-				if err != nil {
-					return false
-				}
-
-				if uo.Atype == model.UOTypeAdmin {
-					return true
-				}
-			}
-		}
-
-		return false
-	}
-
-	if applicable() {
-		return echo.NewHTTPError(http.StatusBadRequest, "You may not create an organization. You belong to an organization which has a policy that prohibits you from being a member of any other organization.")
-	}
-
-	oUuid, err := crypto.GenerateUuid()
-	if err != nil {
-		return err
-	}
-
-	org := &model.Organization{
-		Uuid:         oUuid,
-		Name:         data.Name,
-		BillingEmail: data.BillingEmail,
-	}
-
-	if data.Keys != nil {
-		org.PublicKey = &data.Keys.PublicKey
-		org.PrivateKey = &data.Keys.EncryptedPrivateKey
-	}
-
-	if err := oh.orgs.Create(org); err != nil {
-		return err
-	}
-
-	uoUuid, err := crypto.GenerateUuid()
-	if err != nil {
-		return err
-	}
-
-	uo := &model.UserOrganization{
-		Uuid:      uoUuid,
-		UserUuid:  user.Uuid,
-		OrgUuid:   oUuid,
-		AccessAll: true,
-		Status:    model.UOStatusConfirmed,
-		Atype:     model.UOTypeOwner,
-		AKey:      data.Key,
-	}
-	if err := oh.uos.Create(uo); err != nil {
-		return err
-	}
-
-	cUuid, err := crypto.GenerateUuid()
-	if err != nil {
-		return err
-	}
-	cl := &model.Collection{
-		Uuid:    cUuid,
-		Name:    data.CollectionName,
-		OrgUuid: oUuid,
-	}
-	if err := oh.cs.Save(cl); err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, org)
-}
-
-func (oh *OrganizationHandler) DeleteOrganization(c echo.Context) error {
-	data := new(PasswordData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	oUuid := c.Param("uuid")
-
-	user := auth.GetUser(c)
-
-	user, err := oh.users.FindByUuid(user.Uuid)
-	if err != nil {
-		return err
-	}
-
-	ok := crypto.VerifyPassword(
-		data.MasterPasswordHash,
-		user.Salt,
-		user.PasswordHash,
-		user.PasswordIterations,
-	)
-	if !ok {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid password")
-	}
-
-	org, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Organization not found").SetInternal(err)
-	}
-
-	if err := oh.orgs.Delete(org.Uuid); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) PostDeleteOrganization(c echo.Context) error {
-	return oh.DeleteOrganization(c)
-}
-
-func (oh *OrganizationHandler) LeaveOrganization(c echo.Context) error {
-	oUuid := c.Param("uuid")
-
-	user := auth.GetUser(c)
-
-	uo, err := oh.uos.FindByUserAndOrg(user.Uuid, oUuid)
-	if err != nil {
-		return err
-	}
-
-	// delete users_collections
-	if !uo.AccessAll {
-		cs, err := oh.cs.Find(&model.CollectionFilter{OrgUuid: &oUuid})
-		if err != nil {
-			return err
-		}
-
-		IDs := make([]string, len(cs))
-		for _, c := range cs {
-			IDs = append(IDs, c.Uuid)
-		}
-
-		if err := oh.cs.DeleteUser(IDs, user.Uuid); err != nil {
-			return err
-		}
-	}
-
-	now := time.Now()
-	uu := &model.UpdateUser{
-		Uuid: user.Uuid,
-
-		UpdatedAt: &now,
-	}
-	if err := oh.users.Update(uu); err != nil {
-		return err
-	}
-
-	if err := oh.uos.Delete(uo.Uuid); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) GetUserCollections(c echo.Context) error {
-	user := auth.GetUser(c)
-
-	collections, err := oh.cs.Find(&model.CollectionFilter{UserUuid: &user.Uuid})
-	if err != nil {
-		return err
-	}
-
-	resp := &struct {
-		Data              []*model.Collection `json:"Data"`
-		Object            string              `json:"Object"`
-		ContinuationToken any
-	}{
-		Data:              collections,
-		Object:            "list",
-		ContinuationToken: nil,
-	}
-
-	return c.JSON(http.StatusOK, resp)
-}
-
-func (oh *OrganizationHandler) GetOrgCollections(c echo.Context) error {
-	oUuid := c.Param("uuid")
-
-	collections, err := oh.cs.Find(&model.CollectionFilter{OrgUuid: &oUuid})
-	if err != nil {
-		return err
-	}
-
-	resp := &struct {
-		Data              []*model.Collection `json:"Data"`
-		Object            string              `json:"Object"`
-		ContinuationToken any
-	}{
-		Data:              collections,
-		Object:            "list",
-		ContinuationToken: nil,
-	}
-
-	return c.JSON(http.StatusOK, resp)
-}
-
-func (oh *OrganizationHandler) GetOrgCollectionDetail(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-
-	user := auth.GetUser(c)
-
-	collection, err := oh.cs.FindByCollectionUser(cUuid, user.Uuid)
-	if err != nil {
-		return err
-	}
-
-	if collection.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
-	}
-
-	return c.JSON(http.StatusOK, collection)
-}
-
-func (oh *OrganizationHandler) GetCollectionUsers(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-
-	collection, err := oh.cs.FindByCollectionOrg(cUuid, oUuid)
-	if err != nil {
-		return err
-	}
-
-	ucs, err := oh.ucs.Find(&model.UCFilter{CollectionUuid: &collection.Uuid})
-	if err != nil {
-		return err
-	}
-
-	type Resp struct {
-		ID            string `json:"Id"`
-		ReadOnly      bool   `json:"ReadOnly"`
-		HidePasswords bool   `json:"HidePasswords"`
-	}
-
-	resp := make([]*Resp, 0)
-	for _, cu := range ucs {
-		uo, err := oh.uos.FindByUserAndOrg(cu.UserUuid, oUuid)
-		if err != nil {
-			return err
-		}
-
-		resp = append(resp, &Resp{
-			ID:            uo.Uuid,
-			ReadOnly:      cu.ReadOnly,
-			HidePasswords: cu.HidePasswords,
-		})
-	}
-
-	return c.JSON(http.StatusOK, resp)
-}
-
-type CollectionData struct {
-	ID            string `json:"Id"`
-	ReadOnly      bool   `json:"ReadOnly"`
-	HidePasswords bool   `json:"HidePasswords"`
-}
-
-func (oh *OrganizationHandler) PutCollectionUsers(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-
-	data := new([]*CollectionData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	collection, err := oh.cs.FindByCollectionOrg(cUuid, oUuid)
-	if err != nil || collection == nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Collection not found in Organization")
-	}
-
-	// delete all
-	if err := oh.ucs.DeleteAllByCollection(cUuid); err != nil {
-		return err
-	}
-
-	// add new
-	for _, v := range *data {
-		uo, err := oh.uos.FindByUuid(v.ID)
-		if err != nil || uo == nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "User is not part of organization")
-		}
-
-		if uo.AccessAll {
-			continue
-		}
-
-		if err := oh.ucs.Save(cUuid, uo.UserUuid, v.ReadOnly, v.HidePasswords); err != nil {
-			return err
-		}
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-type EditUserData struct {
-	Type        int               `json:"Type,string"`
-	Collections []*CollectionData `json:"Collections"`
-	AccessAll   bool              `json:"AccessAll"`
-}
-
-func (oh *OrganizationHandler) EditUser(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	uoUuid := c.Param("uouuid")
-	data := new(EditUserData)
-
-	userOrg := auth.GetUserOrganization(c)
-
-	uoType := userOrg.Atype
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	editUO, err := oh.uos.FindByUuid(uoUuid)
-	if err != nil || editUO.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "The specified user isn't member of the organization")
-	}
-
-	newType := model.UOType(data.Type)
-	if newType != model.UOTypeUser &&
-		(editUO.Atype >= model.UOTypeAdmin || newType >= model.UOTypeAdmin) &&
-		(uoType != model.UOTypeOwner) {
-		return echo.NewHTTPError(http.StatusBadRequest, "Only Owners can grant and remove Admin or Owner privileges")
-	}
-
-	if editUO.Atype == model.UOTypeOwner && uoType != model.UOTypeOwner {
-		return echo.NewHTTPError(http.StatusBadRequest, "Only Owners can edit Owner users")
-	}
-
-	if editUO.Atype == model.UOTypeOwner && newType != model.UOTypeOwner {
-		owner := model.UOTypeOwner
-		uos, err := oh.uos.Find(&model.UOFilter{OrgUuid: &oUuid, Atype: &owner})
-		if err != nil {
-			return err
-		}
-
-		if len(uos) <= 1 {
-			return echo.NewHTTPError(http.StatusBadRequest, "Can't delete the last owner")
-		}
-	}
-
-	editUO.AccessAll = data.AccessAll
-	editUO.Atype = newType
-
-	if err := oh.ucs.DeleteAllByUserAndOrg(editUO.UserUuid, oUuid); err != nil {
-		return err
-	}
-
-	if !data.AccessAll {
-		for _, cl := range data.Collections {
-			collection, err := oh.cs.FindByCollectionOrg(cl.ID, oUuid)
-			if err != nil {
-				return err
-			}
-
-			if err := oh.ucs.Save(collection.Uuid, editUO.UserUuid, cl.ReadOnly, cl.HidePasswords); err != nil {
-				return err
-			}
-		}
-	}
-
-	if err := oh.uos.Save(editUO); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) PutOrganization(c echo.Context) error {
-	return oh.PostOrganization(c)
-}
-
-type OrganizationUpdateData struct {
-	BillingEmail string `json:"BillingEmail"`
-	Name         string `json:"Name"`
-}
-
-func (oh *OrganizationHandler) PostOrganization(c echo.Context) error {
-	data := new(OrganizationUpdateData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	oUuid := c.Param("uuid")
-
-	org, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return err
-	}
-
-	org.Name = data.Name
-	org.BillingEmail = data.BillingEmail
-
-	if err := oh.orgs.Save(org); err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, org)
-}
-
-type NewCollectionData struct {
-	Name string `json:"Name"`
-}
-
-func (oh *OrganizationHandler) PostOrganizationCollections(c echo.Context) error {
-	data := new(NewCollectionData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	oUuid := c.Param("uuid")
-
-	user := auth.GetUser(c)
-
-	org, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return err
-	}
-
-	uo, err := oh.uos.FindByUserAndOrg(user.Uuid, org.Uuid)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "User is not part of organization")
-	}
-
-	cUuid, err := crypto.GenerateUuid()
-	if err != nil {
-		return err
-	}
-
-	cl := &model.Collection{
-		Uuid:    cUuid,
-		OrgUuid: org.Uuid,
-		Name:    data.Name,
-	}
-
-	if err := oh.cs.Save(cl); err != nil {
-		return err
-	}
-
-	if !uo.AccessAll {
-		oh.ucs.Save(cUuid, uo.UserUuid, false, false)
-	}
-
-	return c.JSON(http.StatusOK, cl)
-}
-
-func (oh *OrganizationHandler) DeleteOrganizationCollectionUser(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-	uoUuid := c.Param("uouuid")
-
-	cl, err := oh.cs.FindByUuid(cUuid)
-	if err != nil {
-		return err
-	}
-
-	if cl.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "Collection and Organization id do not match")
-	}
-
-	uo, err := oh.uos.FindByUuid(uoUuid)
-	if err != nil {
-		return err
-	}
-
-	if uo.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "User not found in organization")
-	}
-
-	cu, err := oh.ucs.FindByCollectionUser(cUuid, uo.UserUuid)
-	if err != nil {
-		return err
-	}
-
-	if err := oh.ucs.DeleteByUserCollection(cu.CollectionUuid, cu.UserUuid); err != nil {
-		return err
-	}
-
-	if err := oh.users.UpdateRevision(uo.UserUuid); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) PostOrganizationCollectionDeleteUser(c echo.Context) error {
-	return oh.DeleteOrganizationCollectionUser(c)
-}
-
-func (oh *OrganizationHandler) PostOrganizationCollectionUpdate(c echo.Context) error {
-	data := new(NewCollectionData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-
-	org, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return err
-	}
-
-	cl, err := oh.cs.FindByUuid(cUuid)
-	if err != nil {
-		return err
-	}
-
-	if cl.OrgUuid != org.Uuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
-	}
-
-	cl.Name = data.Name
-
-	if err := oh.cs.Save(cl); err != nil {
-		return err
-	}
-
-	return c.JSON(http.StatusOK, cl)
-}
-
-func (oh *OrganizationHandler) PutOrganizationCollectionUpdate(c echo.Context) error {
-	return oh.PostOrganizationCollectionUpdate(c)
-}
-
-func (oh *OrganizationHandler) DeleteOrganizationCollection(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	cUuid := c.Param("cuuid")
-
-	org, err := oh.orgs.FindByUuid(oUuid)
-	if err != nil {
-		return err
-	}
-
-	cl, err := oh.cs.FindByUuid(cUuid)
-	if err != nil {
-		return err
-	}
-
-	if cl.OrgUuid != org.Uuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
-	}
-
-	if err := oh.cs.Delete(cUuid); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) PostOrganizationCollectionDelete(c echo.Context) error {
-	return oh.DeleteOrganizationCollection(c)
-}
-
-type OrgIdData struct {
-	OrganizationId string `query:"organizationId" form:"organizationId"`
-}
-
-func (oh *OrganizationHandler) GetOrgDetails(c echo.Context) error {
-	data := new(OrgIdData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	ciphers, err := oh.ciphers.FindByOrg(data.OrganizationId)
-	if err != nil {
-		return err
-	}
-
-	var cs []*response.Cipher
-	for i := range ciphers {
-		rc, err := response.NewCipher(ciphers[i])
-		if err != nil {
-			return err
-		}
-
-		cs = append(cs, rc)
-	}
-
-	resp := &RespListData{
-		Data:              cs,
-		Object:            "list",
-		ContinuationToken: nil,
-	}
-
-	return c.JSON(http.StatusOK, resp)
-}
-
-type RespListData struct {
-	Data              any    `json:"Data"`
-	Object            string `json:"Object"`
-	ContinuationToken any    `json:"ContinuationToken"`
-}
-
-func (oh *OrganizationHandler) GetOrgUsers(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-
-	uos, err := oh.uos.Find(&model.UOFilter{OrgUuid: &oUuid})
-	if err != nil {
-		return err
-	}
-
-	resp := &RespListData{
-		Data:              uos,
-		Object:            "list",
-		ContinuationToken: nil,
-	}
-
-	return c.JSON(http.StatusOK, resp)
-}
-
-type InviteData struct {
-	Emails      []string         `json:"Emails"`
-	Type        *model.UOType    `json:"Type"`
-	Collections []CollectionData `json:"Collections"`
-	AccessAll   *bool            `json:"AccessAll"`
-}
-
-func (id *InviteData) UnmarshalJSON(data []byte) error {
-	type Alias InviteData
-	alias := &struct {
-		Type int `json:"Type"`
-		*Alias
-	}{
-		Alias: (*Alias)(id),
-	}
-
-	if err := json.Unmarshal(data, &alias); err != nil {
-		return err
-	}
-
-	t := model.UOType(alias.Type)
-
-	id.Type = &t
-
-	return nil
-}
-
-func (oh *OrganizationHandler) SendInvite(c echo.Context) error {
-	data := new(InviteData)
-
-	if err := c.Bind(data); err != nil {
-		return err
-	}
-
-	oUuid := c.Param("ouuid")
-
-	userOrg := auth.GetUserOrganization(c)
-	userOrgType := userOrg.Atype
-
-	newType := *data.Type
-
-	if newType != model.UOTypeUser && userOrgType != model.UOTypeOwner {
-		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can invite Managers, Admins or Owners")
-	}
-
-	mailEnabled := false
-	uoStatus := model.UOStatusAccepted
-	if mailEnabled {
-		uoStatus = model.UOStatusInvited
-	}
-
-	for _, email := range data.Emails {
-		email := strings.ToLower(email)
-		user, err := oh.users.FindByEmail(email)
-		if err != nil && !errors.Is(err, model.ErrNotFound) {
-			return err
-		}
-
-		if errors.Is(err, model.ErrNotFound) {
-			if !oh.cfgs.IsInvitationsAllowed() {
-				return echo.NewHTTPError(http.StatusForbidden, "Invitations are disabled")
-			}
-
-			if !oh.cfgs.IsEmailDomainAllowed(email) {
-				return echo.NewHTTPError(http.StatusForbidden, "Email domain not eligible for invitations")
-			}
-
-			if !mailEnabled {
-				if err := oh.is.Save(&model.Invitation{Email: email}); err != nil {
-					return err
-				}
-			}
-
-			user, err = oh.newUser(email)
-			if err != nil {
-				return err
-			}
-			if err := oh.users.Create(user); err != nil {
-				return err
-			}
-
-			uoStatus = model.UOStatusInvited
-		} else {
-			uo, err := oh.uos.FindByUserAndOrg(user.Uuid, oUuid)
-			if err != nil && !errors.Is(err, model.ErrNotFound) {
-				return err
-			}
-
-			if uo != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "User already in organization: "+email)
-			}
-		}
-
-		id, err := crypto.GenerateUuid()
-		if err != nil {
-			return err
-		}
-		uo := &model.UserOrganization{
-			Uuid:      id,
-			UserUuid:  user.Uuid,
-			OrgUuid:   oUuid,
-			AccessAll: *data.AccessAll,
-			Atype:     newType,
-			Status:    uoStatus,
-		}
-		if err := oh.uos.Save(uo); err != nil {
-			return err
-		}
-
-		if !*data.AccessAll {
-			for _, c := range data.Collections {
-				cl, err := oh.cs.FindByCollectionOrg(c.ID, oUuid)
-				if err != nil {
-					return err
-				}
-
-				if err := oh.ucs.Save(cl.Uuid, user.Uuid, c.ReadOnly, c.HidePasswords); err != nil {
-					return err
-				}
-			}
-		}
-
-		// TODO: send email
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh *OrganizationHandler) newUser(email string) (*model.User, error) {
-	id, err := crypto.GenerateUuid()
-	if err != nil {
-		return nil, err
-	}
-
-	ss, err := crypto.GenerateUuid()
-	if err != nil {
-		return nil, err
-	}
-
-	salt, err := crypto.GenerateBytes(64)
-	if err != nil {
-		return nil, err
-	}
-
-	now := time.Now()
-	user := &model.User{
-		Uuid:               id,
-		Enabled:            true,
-		CreatedAt:          now,
-		UpdatedAt:          now,
-		Email:              email,
-		Name:               email,
-		Salt:               salt,
-		PasswordIterations: oh.cfgs.PasswordIterations,
-		SecurityStamp:      ss,
-		ClientKdfType:      model.ClientKdfTypeDefault,
-		ClientKdfIter:      model.ClientKdfIterDefault,
-	}
-
-	return user, nil
-}
-
-func (oh *OrganizationHandler) GetUser(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	uoUuid := c.Param("uouuid")
-
-	uo, err := oh.uos.FindByUuid(uoUuid)
-	if err != nil {
-		return err
-	}
-
-	if uo.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "The specified user isn't a member of the organization")
-	}
-
-	var cls []*model.UOCollection
-
-	if !uo.AccessAll {
-		cs, err := oh.ucs.Find(&model.UCFilter{UserUuid: &uo.UserUuid, OrgUuid: &oUuid})
-		if err != nil {
-			return err
-		}
-
-		for _, c := range cs {
-			cls = append(cls, &model.UOCollection{
-				ID:            c.CollectionUuid,
-				ReadOnly:      c.ReadOnly,
-				HidePasswords: c.HidePasswords,
-			})
-		}
-
-	}
-
-	d := &model.UODetail{
-		ID:          uo.Uuid,
-		UserID:      uo.UserUuid,
-		Status:      int(uo.Status),
-		Type:        int(uo.Atype),
-		AccessAll:   uo.AccessAll,
-		Collections: cls,
-		Object:      "organizationUserDetails",
-	}
-
-	return c.JSON(http.StatusOK, d)
-}
-
-func (oh *OrganizationHandler) PutUser(c echo.Context) error {
-	return oh.EditUser(c)
-}
-
-func (oh *OrganizationHandler) DeleteUser(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-	uoUuid := c.Param("uouuid")
-
-	userOrg := auth.GetUserOrganization(c)
-	userOrgType := userOrg.Atype
-
-	if err := oh.deleteUserOrg(uoUuid, oUuid, &userOrgType); err != nil {
-		return err
-	}
-
-	return c.NoContent(http.StatusOK)
-}
-
-func (oh OrganizationHandler) deleteUserOrg(uoUuid, oUuid string, userOrgType *model.UOType) error {
-	uo, err := oh.uos.FindByUuid(uoUuid)
-	if err != nil {
-		return err
-	}
-
-	if uo.OrgUuid != oUuid {
-		return echo.NewHTTPError(http.StatusBadRequest, "User to delete isn't member of the organization")
-	}
-
-	if uo.Atype != model.UOTypeUser && *userOrgType != model.UOTypeOwner {
-		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can delete Admins or Owners")
-	}
-
-	if uo.Atype == model.UOTypeOwner {
-		owner := model.UOTypeOwner
-		uolist, err := oh.uos.Find(&model.UOFilter{OrgUuid: &oUuid, Atype: &owner})
-		if err != nil {
-			return err
-		}
-
-		if len(uolist) <= 1 {
-			return echo.NewHTTPError(http.StatusBadRequest, "Can't delete the last owner")
-		}
-	}
-
-	now := time.Now()
-	uu := &model.UpdateUser{
-		Uuid:      uo.UserUuid,
-		UpdatedAt: &now,
-	}
-	if err := oh.users.Update(uu); err != nil {
-		return err
-	}
-
-	if err := oh.ucs.DeleteAllByUserAndOrg(uo.UserUuid, oUuid); err != nil {
-		return err
-	}
-
-	if err := oh.uos.Delete(uo.Uuid); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-type OrgIDsData struct {
-	IDs []string `json:"Ids"`
-}
-
-func (oh *OrganizationHandler) BulkDeleteUser(c echo.Context) error {
-	oUuid := c.Param("ouuid")
-
-	userOrg := auth.GetUserOrganization(c)
-	userOrgType := userOrg.Atype
-
-	var data OrgIDsData
-	if err := c.Bind(&data); err != nil {
-		return err
-	}
-
-	type RespItem struct {
-		Object string `json:"Object"`
-		Id     string `json:"Id"`
-		Error  string `json:"Error"`
-	}
-	var resp []RespItem
-	for _, id := range data.IDs {
-		msg := ""
-		herr := echo.NewHTTPError(http.StatusBadRequest)
-		err := oh.deleteUserOrg(id, oUuid, &userOrgType)
-		if errors.As(err, &herr) {
-			msg = fmt.Sprintf("%s", herr.Message)
-		}
-
-		resp = append(resp, RespItem{
-			Object: "OrganizationBulkConfirmResponseModel",
-			Id:     id,
-			Error:  msg,
-		})
-	}
-
-	return c.JSON(http.StatusOK, struct {
-		Data              []RespItem `json:"Data"`
-		Object            string     `json:"Object"`
-		ContinuationToken any        `json:"ContinuationToken"`
-	}{
-		Data:              resp,
-		Object:            "list",
-		ContinuationToken: nil,
-	})
-}
-
-func (oh *OrganizationHandler) PostDeleteUser(c echo.Context) error {
-	return oh.DeleteUser(c)
-}
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+type OrganizationHandler struct {
+	users   store.User
+	ciphers store.Cipher
+	orgs    store.Organization
+	cs      store.Collection
+	ops     store.OrgPolicy
+	uos     store.UserOrganization
+	ucs     store.UserCollection
+	is      store.Invitation
+	as      store.Attachment
+	tfs     store.TwoFactor
+	groups  store.Group
+	events  store.Event
+	devices store.Device
+	tx      store.Tx
+	apiKeys store.OrgApiKey
+
+	auth *auth.Core
+	cfgs *config.Core
+
+	mailEnabled bool
+}
+
+func NewOrganizationHandler(
+	users store.User,
+	ciphers store.Cipher,
+	orgs store.Organization,
+	cs store.Collection,
+	ops store.OrgPolicy,
+	uos store.UserOrganization,
+	ucs store.UserCollection,
+	is store.Invitation,
+	as store.Attachment,
+	tfs store.TwoFactor,
+	groups store.Group,
+	events store.Event,
+	devices store.Device,
+	tx store.Tx,
+	apiKeys store.OrgApiKey,
+	auth *auth.Core,
+	cfgs *config.Core,
+) *OrganizationHandler {
+	return &OrganizationHandler{
+		users:   users,
+		ciphers: ciphers,
+		orgs:    orgs,
+		cs:      cs,
+		ops:     ops,
+		uos:     uos,
+		ucs:     ucs,
+		is:      is,
+		as:      as,
+		tfs:     tfs,
+		groups:  groups,
+		events:  events,
+		devices: devices,
+		tx:      tx,
+		apiKeys: apiKeys,
+		auth:    auth,
+		cfgs:    cfgs,
+
+		mailEnabled: cfgs.MailEnabled(),
+	}
+}
+
+func (oh *OrganizationHandler) Routes(e *echo.Echo) {
+	e.GET("/api/ciphers/organization-details", oh.GetOrgDetails, oh.auth.RequireAuth)
+	e.POST("/api/ciphers/import-organization", oh.PostOrgImport, oh.auth.RequireAdminAuth)
+	e.GET("/api/organizations/:ouuid/keys", oh.GetOrgKeys, oh.auth.RequireOrgAuth)
+
+	{
+		cl := e.Group("/api/collections")
+		cl.GET("", oh.GetUserCollections, oh.auth.RequireAuth)
+	}
+
+	{ // base auth
+		org := e.Group("/api/organizations", oh.auth.RequireAuth)
+		org.POST("", oh.CreateOrganization)
+		org.POST("/:uuid/leave", oh.LeaveOrganization)
+		org.POST("/:ouuid/users/:uouuid/accept", oh.AcceptInvite)
+		org.PUT("/:ouuid/users/:uouuid/reset-password-enrollment", oh.PutResetPasswordEnrollment)
+	}
+
+	{ // owner
+		org := e.Group("/api/organizations", oh.auth.RequireOwnerAuth)
+		org.GET("/:uuid", oh.GetOrganization)
+		org.DELETE(":uuid", oh.DeleteOrganization)
+		org.POST("/:uuid/delete", oh.PostDeleteOrganization)
+		org.PUT("/:uuid", oh.PutOrganization)
+		org.POST("/:uuid", oh.PostOrganization)
+		org.POST("/:uuid/api-key", oh.PostApiKey)
+		org.POST("/:uuid/rotate-api-key", oh.PostRotateApiKey)
+	}
+
+	{ // ManagerLoose
+		org := e.Group("/api/organizations", oh.auth.RequireManagerLooseAuth)
+		org.GET("/:uuid/collections", oh.GetOrgCollections)
+		org.POST("/:uuid/collections", oh.PostOrganizationCollections)
+		org.GET("/:ouuid/users", oh.GetOrgUsers)
+		org.GET("/:ouuid/groups", oh.GetGroups)
+	}
+
+	{ // Manager
+		org := e.Group("/api/organizations", oh.auth.RequireManagerAuth)
+		org.GET("/:ouuid/collections/:cuuid/details", oh.GetOrgCollectionDetail)
+		org.GET("/:ouuid/collections/:cuuid/users", oh.GetCollectionUsers)
+		org.PUT("/:ouuid/collections/:cuuid/users", oh.PutCollectionUsers)
+		org.POST("/:ouuid/collections/:cuuid", oh.PostOrganizationCollectionUpdate)
+		org.PUT("/:ouuid/collections/:cuuid", oh.PutOrganizationCollectionUpdate)
+		org.DELETE("/:ouuid/collections/:cuuid", oh.DeleteOrganizationCollection)
+		org.POST("/:ouuid/collections/:cuuid/delete", oh.PostOrganizationCollectionDelete)
+	}
+
+	{ // require admin
+		org := e.Group("/api/organizations", oh.auth.RequireAdminAuth)
+		org.DELETE("/:ouuid/collections/:cuuid/users/:uouuid", oh.DeleteOrganizationCollectionUser)
+		org.POST("/:ouuid/collections/:cuuid/delete-users/:uouuid", oh.PostOrganizationCollectionDeleteUser)
+		org.POST("/:ouuid/users/invite", oh.SendInvite)
+		org.POST("/:ouuid/users/reinvite", oh.BulkReinviteUser)
+		org.POST("/:ouuid/users/:uouuid/reinvite", oh.ReinviteUser)
+		org.POST("/:ouuid/users/confirm", oh.BulkConfirmInvite)
+		org.POST("/:ouuid/users/:uouuid/confirm", oh.ConfirmInvite)
+		org.POST("/:ouuid/users/public-keys", oh.BulkPublicKeys)
+		org.GET("/:ouuid/users/:uouuid", oh.GetUser)
+		org.POST("/:ouuid/users/:uouuid", oh.EditUser)
+		org.PUT("/:ouuid/users/:uouuid", oh.PutUser)
+		org.DELETE("/:ouuid/users/:uouuid", oh.DeleteUser)
+		org.DELETE("/:ouuid/users", oh.BulkDeleteUser)
+		org.GET("/:ouuid/users/:uouuid/reset-password-details", oh.GetResetPasswordDetails)
+		org.PUT("/:ouuid/users/:uouuid/reset-password", oh.PutResetPassword)
+		org.POST("/:ouuid/users/:uouuid/delete", oh.PostDeleteUser)
+		org.GET("/:ouuid/policies", oh.GetPolicies)
+		org.GET("/:ouuid/policies/:ptype", oh.GetPolicy)
+		org.PUT("/:ouuid/policies/:ptype", oh.PutPolicy)
+		org.POST("/:ouuid/groups", oh.PostGroups)
+		org.GET("/:ouuid/groups/:guuid", oh.GetGroup)
+		org.GET("/:ouuid/groups/:guuid/details", oh.GetGroupDetails)
+		org.PUT("/:ouuid/groups/:guuid", oh.PutGroup)
+		org.POST("/:ouuid/groups/:guuid", oh.PutGroup)
+		org.DELETE("/:ouuid/groups/:guuid", oh.DeleteGroup)
+		org.POST("/:ouuid/groups/:guuid/delete", oh.DeleteGroup)
+		org.GET("/:ouuid/groups/:guuid/users", oh.GetGroupUsers)
+		org.PUT("/:ouuid/groups/:guuid/users", oh.PutGroupUsers)
+		org.DELETE("/:ouuid/groups/:guuid/users/:uouuid", oh.DeleteGroupUser)
+		org.POST("/:ouuid/groups/:guuid/delete-user/:uouuid", oh.DeleteGroupUser)
+		org.GET("/:ouuid/users/:uouuid/groups", oh.GetUserGroups)
+		org.PUT("/:ouuid/users/:uouuid/groups", oh.PutUserGroups)
+		org.POST("/:ouuid/users/:uouuid/groups", oh.PutUserGroups)
+		org.POST("/:ouuid/keys", oh.PostOrgKeys)
+		org.POST("/:ouuid/import", oh.PostDirectoryImport)
+	}
+
+	{ // SCIM, authenticated by the SCIM API key of the organization
+		scim := e.Group("/scim/v2/:ouuid", oh.requireScimKey)
+		scim.GET("/Users", oh.GetScimUsers)
+		scim.POST("/Users", oh.PostScimUser)
+		scim.GET("/Users/:id", oh.GetScimUser)
+		scim.PUT("/Users/:id", oh.PutScimUser)
+		scim.PATCH("/Users/:id", oh.PatchScimUser)
+		scim.DELETE("/Users/:id", oh.DeleteScimUser)
+		scim.GET("/Groups", oh.GetScimGroups)
+		scim.POST("/Groups", oh.PostScimGroup)
+		scim.GET("/Groups/:id", oh.GetScimGroup)
+		scim.PUT("/Groups/:id", oh.PutScimGroup)
+		scim.PATCH("/Groups/:id", oh.PatchScimGroup)
+		scim.DELETE("/Groups/:id", oh.DeleteScimGroup)
+	}
+
+	{ // public API, authenticated by the API key of the organization
+		api := e.Group("/api/public", oh.auth.RequireOrgApiAuth)
+		api.GET("/members", oh.GetPublicMembers)
+		api.POST("/members", oh.PostPublicMember)
+		api.GET("/members/:id", oh.GetPublicMember)
+		api.PUT("/members/:id", oh.PutPublicMember)
+		api.DELETE("/members/:id", oh.DeletePublicMember)
+		api.GET("/members/:id/group-ids", oh.GetPublicMemberGroupIds)
+		api.PUT("/members/:id/group-ids", oh.PutPublicMemberGroupIds)
+		api.GET("/collections", oh.GetPublicCollections)
+		api.GET("/collections/:id", oh.GetPublicCollection)
+		api.DELETE("/collections/:id", oh.DeletePublicCollection)
+		api.GET("/groups", oh.GetPublicGroups)
+		api.POST("/groups", oh.PostPublicGroup)
+		api.GET("/groups/:id", oh.GetPublicGroup)
+		api.PUT("/groups/:id", oh.PutPublicGroup)
+		api.DELETE("/groups/:id", oh.DeletePublicGroup)
+		api.GET("/groups/:id/member-ids", oh.GetPublicGroupMemberIds)
+		api.PUT("/groups/:id/member-ids", oh.PutPublicGroupMemberIds)
+	}
+
+	// TODO:
+	// list_policies_token,
+	// get_organization_tax,
+	// get_plans,
+	// get_plans_tax_rates,
+}
+
+func (oh *OrganizationHandler) GetOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("uuid")
+
+	organization, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Can't find organization details").SetInternal(err)
+	}
+
+	if err := oh.loadStorage(ctx, organization); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, organization)
+}
+
+type OrgData struct {
+	BillingEmail   string      `json:"BillingEmail"`
+	CollectionName string      `json:"CollectionName"`
+	Key            *string     `json:"Key"`
+	Name           string      `json:"Name"`
+	Keys           *OrgKeyData `json:"Keys"`
+}
+
+type OrgKeyData struct {
+	EncryptedPrivateKey string `json:"EncryptedPrivateKey"`
+	PublicKey           string `json:"PublicKey"`
+}
+
+func (oh *OrganizationHandler) CreateOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(OrgData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	user, err := oh.users.FindByUuid(ctx, user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if !oh.cfgs.IsOrgCreationAllowed(user.Email) {
+		return echo.NewHTTPError(http.StatusBadRequest, "User not allowed to create organizations")
+	}
+
+	ps, err := oh.ops.FindBindingByUser(ctx, user.Uuid, model.OPTypeSingleOrg)
+	if err != nil {
+		return err
+	}
+
+	if len(ps) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "You may not create an organization. You belong to an organization which has a policy that prohibits you from being a member of any other organization.")
+	}
+
+	oUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	org := &model.Organization{
+		Uuid:         oUuid,
+		Name:         data.Name,
+		BillingEmail: data.BillingEmail,
+	}
+
+	if data.Keys != nil {
+		org.PublicKey = &data.Keys.PublicKey
+		org.PrivateKey = &data.Keys.EncryptedPrivateKey
+	}
+
+	if err := oh.orgs.Create(ctx, org); err != nil {
+		return err
+	}
+
+	uoUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	uo := &model.UserOrganization{
+		Uuid:      uoUuid,
+		UserUuid:  user.Uuid,
+		OrgUuid:   oUuid,
+		AccessAll: true,
+		Status:    model.UOStatusConfirmed,
+		Atype:     model.UOTypeOwner,
+		AKey:      data.Key,
+	}
+	if err := oh.uos.Create(ctx, uo); err != nil {
+		return err
+	}
+
+	cUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+	cl := &model.Collection{
+		Uuid:    cUuid,
+		Name:    data.CollectionName,
+		OrgUuid: oUuid,
+	}
+	if err := oh.cs.Save(ctx, cl); err != nil {
+		return err
+	}
+
+	org.Storage = model.Storage{Limit: org.Limits.StorageLimit(oh.cfgs.OrgStorageLimit())}
+
+	return c.JSON(http.StatusOK, org)
+}
+
+func (oh *OrganizationHandler) DeleteOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(PasswordData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("uuid")
+
+	user := auth.GetUser(c)
+
+	user, err := oh.users.FindByUuid(ctx, user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	ok := crypto.VerifyPassword(
+		data.MasterPasswordHash,
+		user.Salt,
+		user.PasswordHash,
+		user.PasswordIterations,
+	)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid password")
+	}
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Organization not found").SetInternal(err)
+	}
+
+	if err := oh.groups.DeleteAllByOrg(ctx, org.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.apiKeys.DeleteAllByOrg(ctx, org.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.orgs.Delete(ctx, org.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) PostDeleteOrganization(c echo.Context) error {
+	return oh.DeleteOrganization(c)
+}
+
+func (oh *OrganizationHandler) LeaveOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("uuid")
+
+	user := auth.GetUser(c)
+
+	uo, err := oh.uos.FindByUserAndOrg(ctx, user.Uuid, oUuid)
+	if err != nil {
+		return err
+	}
+
+	// delete users_collections
+	if !uo.AccessAll {
+		cs, err := oh.cs.Find(ctx, &model.CollectionFilter{OrgUuid: &oUuid})
+		if err != nil {
+			return err
+		}
+
+		IDs := make([]string, len(cs))
+		for _, c := range cs {
+			IDs = append(IDs, c.Uuid)
+		}
+
+		if err := oh.cs.DeleteUser(ctx, IDs, user.Uuid); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	uu := &model.UpdateUser{
+		Uuid: user.Uuid,
+
+		UpdatedAt: &now,
+	}
+	if err := oh.users.Update(ctx, uu); err != nil {
+		return err
+	}
+
+	if err := oh.uos.Delete(ctx, uo.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) GetUserCollections(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	collections, err := oh.cs.Find(ctx, &model.CollectionFilter{UserUuid: &user.Uuid})
+	if err != nil {
+		return err
+	}
+
+	resp := &struct {
+		Data              []*model.Collection `json:"Data"`
+		Object            string              `json:"Object"`
+		ContinuationToken any
+	}{
+		Data:              collections,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (oh *OrganizationHandler) GetOrgCollections(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("uuid")
+
+	collections, err := oh.cs.Find(ctx, &model.CollectionFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	resp := &struct {
+		Data              []*model.Collection `json:"Data"`
+		Object            string              `json:"Object"`
+		ContinuationToken any
+	}{
+		Data:              collections,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (oh *OrganizationHandler) GetOrgCollectionDetail(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+
+	user := auth.GetUser(c)
+
+	collection, err := oh.cs.FindByCollectionUser(ctx, cUuid, user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if collection.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
+	}
+
+	return c.JSON(http.StatusOK, collection)
+}
+
+func (oh *OrganizationHandler) GetCollectionUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+
+	collection, err := oh.cs.FindByCollectionOrg(ctx, cUuid, oUuid)
+	if err != nil {
+		return err
+	}
+
+	ucs, err := oh.ucs.Find(ctx, &model.UCFilter{CollectionUuid: &collection.Uuid})
+	if err != nil {
+		return err
+	}
+
+	type Resp struct {
+		ID            string `json:"Id"`
+		ReadOnly      bool   `json:"ReadOnly"`
+		HidePasswords bool   `json:"HidePasswords"`
+	}
+
+	resp := make([]*Resp, 0)
+	for _, cu := range ucs {
+		uo, err := oh.uos.FindByUserAndOrg(ctx, cu.UserUuid, oUuid)
+		if err != nil {
+			return err
+		}
+
+		resp = append(resp, &Resp{
+			ID:            uo.Uuid,
+			ReadOnly:      cu.ReadOnly,
+			HidePasswords: cu.HidePasswords,
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+type CollectionData struct {
+	ID            string `json:"Id"`
+	ReadOnly      bool   `json:"ReadOnly"`
+	HidePasswords bool   `json:"HidePasswords"`
+}
+
+func (oh *OrganizationHandler) PutCollectionUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+
+	data := new([]*CollectionData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	collection, err := oh.cs.FindByCollectionOrg(ctx, cUuid, oUuid)
+	if err != nil || collection == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection not found in Organization")
+	}
+
+	// delete all
+	if err := oh.ucs.DeleteAllByCollection(ctx, cUuid); err != nil {
+		return err
+	}
+
+	// add new
+	for _, v := range *data {
+		uo, err := oh.uos.FindByUuid(ctx, v.ID)
+		if err != nil || uo == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "User is not part of organization")
+		}
+
+		if uo.AccessAll {
+			continue
+		}
+
+		if err := oh.ucs.Save(ctx, cUuid, uo.UserUuid, v.ReadOnly, v.HidePasswords); err != nil {
+			return err
+		}
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionUpdated, collection); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type EditUserData struct {
+	Type        int               `json:"Type,string"`
+	Collections []*CollectionData `json:"Collections"`
+	AccessAll   bool              `json:"AccessAll"`
+}
+
+func (oh *OrganizationHandler) EditUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	uoUuid := c.Param("uouuid")
+	data := new(EditUserData)
+
+	userOrg := auth.GetUserOrganization(c)
+
+	uoType := userOrg.Atype
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	editUO, err := oh.uos.FindByUuid(ctx, uoUuid)
+	if err != nil || editUO.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "The specified user isn't member of the organization")
+	}
+
+	newType := model.UOType(data.Type)
+	if newType != model.UOTypeUser &&
+		(editUO.Atype >= model.UOTypeAdmin || newType >= model.UOTypeAdmin) &&
+		(uoType != model.UOTypeOwner) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only Owners can grant and remove Admin or Owner privileges")
+	}
+
+	if editUO.Atype == model.UOTypeOwner && uoType != model.UOTypeOwner {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only Owners can edit Owner users")
+	}
+
+	if editUO.Atype == model.UOTypeOwner && newType != model.UOTypeOwner {
+		owner := model.UOTypeOwner
+		uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid, Atype: &owner})
+		if err != nil {
+			return err
+		}
+
+		if len(uos) <= 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Can't delete the last owner")
+		}
+	}
+
+	editUO.AccessAll = data.AccessAll
+	editUO.Atype = newType
+
+	if err := oh.ucs.DeleteAllByUserAndOrg(ctx, editUO.UserUuid, oUuid); err != nil {
+		return err
+	}
+
+	if !data.AccessAll {
+		for _, cl := range data.Collections {
+			collection, err := oh.cs.FindByCollectionOrg(ctx, cl.ID, oUuid)
+			if err != nil {
+				return err
+			}
+
+			if err := oh.ucs.Save(ctx, collection.Uuid, editUO.UserUuid, cl.ReadOnly, cl.HidePasswords); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := oh.uos.Save(ctx, editUO); err != nil {
+		return err
+	}
+
+	if err := oh.logMemberEvent(ctx, model.EventOrgUserUpdated, editUO); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) PutOrganization(c echo.Context) error {
+	return oh.PostOrganization(c)
+}
+
+type OrganizationUpdateData struct {
+	BillingEmail string `json:"BillingEmail"`
+	Name         string `json:"Name"`
+}
+
+func (oh *OrganizationHandler) PostOrganization(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(OrganizationUpdateData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("uuid")
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	org.Name = data.Name
+	org.BillingEmail = data.BillingEmail
+
+	if err := oh.orgs.Save(ctx, org); err != nil {
+		return err
+	}
+
+	if err := logEvent(ctx, oh.events, &model.Event{
+		Atype:   model.EventOrgUpdated,
+		OrgUuid: &org.Uuid,
+	}); err != nil {
+		return err
+	}
+
+	if err := oh.loadStorage(ctx, org); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, org)
+}
+
+type NewCollectionData struct {
+	Name string `json:"Name"`
+}
+
+func (oh *OrganizationHandler) PostOrganizationCollections(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(NewCollectionData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("uuid")
+
+	user := auth.GetUser(c)
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	uo, err := oh.uos.FindByUserAndOrg(ctx, user.Uuid, org.Uuid)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "User is not part of organization")
+	}
+
+	if err := oh.checkCollections(ctx, org.Uuid); err != nil {
+		return err
+	}
+
+	cUuid, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	cl := &model.Collection{
+		Uuid:    cUuid,
+		OrgUuid: org.Uuid,
+		Name:    data.Name,
+	}
+
+	if err := oh.cs.Save(ctx, cl); err != nil {
+		return err
+	}
+
+	if !uo.AccessAll {
+		oh.ucs.Save(ctx, cUuid, uo.UserUuid, false, false)
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionCreated, cl); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cl)
+}
+
+func (oh *OrganizationHandler) DeleteOrganizationCollectionUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+	uoUuid := c.Param("uouuid")
+
+	cl, err := oh.cs.FindByUuid(ctx, cUuid)
+	if err != nil {
+		return err
+	}
+
+	if cl.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection and Organization id do not match")
+	}
+
+	uo, err := oh.uos.FindByUuid(ctx, uoUuid)
+	if err != nil {
+		return err
+	}
+
+	if uo.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "User not found in organization")
+	}
+
+	cu, err := oh.ucs.FindByCollectionUser(ctx, cUuid, uo.UserUuid)
+	if err != nil {
+		return err
+	}
+
+	if err := oh.ucs.DeleteByUserCollection(ctx, cu.CollectionUuid, cu.UserUuid); err != nil {
+		return err
+	}
+
+	if err := oh.users.UpdateRevision(ctx, uo.UserUuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) PostOrganizationCollectionDeleteUser(c echo.Context) error {
+	return oh.DeleteOrganizationCollectionUser(c)
+}
+
+func (oh *OrganizationHandler) PostOrganizationCollectionUpdate(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(NewCollectionData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	cl, err := oh.cs.FindByUuid(ctx, cUuid)
+	if err != nil {
+		return err
+	}
+
+	if cl.OrgUuid != org.Uuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
+	}
+
+	cl.Name = data.Name
+
+	if err := oh.cs.Save(ctx, cl); err != nil {
+		return err
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionUpdated, cl); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, cl)
+}
+
+func (oh *OrganizationHandler) PutOrganizationCollectionUpdate(c echo.Context) error {
+	return oh.PostOrganizationCollectionUpdate(c)
+}
+
+func (oh *OrganizationHandler) DeleteOrganizationCollection(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	cUuid := c.Param("cuuid")
+
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	cl, err := oh.cs.FindByUuid(ctx, cUuid)
+	if err != nil {
+		return err
+	}
+
+	if cl.OrgUuid != org.Uuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "Collection is not owned by organization")
+	}
+
+	if err := oh.groups.DeleteAllByCollection(ctx, cUuid); err != nil {
+		return err
+	}
+
+	if err := oh.cs.Delete(ctx, cUuid); err != nil {
+		return err
+	}
+
+	if err := oh.logCollectionEvent(ctx, model.EventCollectionDeleted, cl); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh *OrganizationHandler) PostOrganizationCollectionDelete(c echo.Context) error {
+	return oh.DeleteOrganizationCollection(c)
+}
+
+type OrgIdData struct {
+	OrganizationId string `query:"organizationId" form:"organizationId"`
+}
+
+func (oh *OrganizationHandler) GetOrgDetails(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(OrgIdData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	ciphers, err := oh.ciphers.FindByOrg(ctx, data.OrganizationId)
+	if err != nil {
+		return err
+	}
+
+	var cs []*response.Cipher
+	for i := range ciphers {
+		rc, err := response.NewCipher(ciphers[i])
+		if err != nil {
+			return err
+		}
+
+		cs = append(cs, rc)
+	}
+
+	resp := &RespListData{
+		Data:              cs,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+type RespListData struct {
+	Data              any    `json:"Data"`
+	Object            string `json:"Object"`
+	ContinuationToken any    `json:"ContinuationToken"`
+}
+
+func (oh *OrganizationHandler) GetOrgUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	resp := &RespListData{
+		Data:              uos,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+type InviteData struct {
+	Emails      []string         `json:"Emails"`
+	Type        *model.UOType    `json:"Type"`
+	Collections []CollectionData `json:"Collections"`
+	AccessAll   *bool            `json:"AccessAll"`
+}
+
+func (id *InviteData) UnmarshalJSON(data []byte) error {
+	type Alias InviteData
+	alias := &struct {
+		Type int `json:"Type"`
+		*Alias
+	}{
+		Alias: (*Alias)(id),
+	}
+
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+
+	t := model.UOType(alias.Type)
+
+	id.Type = &t
+
+	return nil
+}
+
+func (oh *OrganizationHandler) SendInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(InviteData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	oUuid := c.Param("ouuid")
+
+	userOrg := auth.GetUserOrganization(c)
+	userOrgType := userOrg.Atype
+
+	newType := *data.Type
+
+	if newType != model.UOTypeUser && userOrgType != model.UOTypeOwner {
+		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can invite Managers, Admins or Owners")
+	}
+
+	for _, email := range data.Emails {
+		uo := &model.UserOrganization{
+			OrgUuid:   oUuid,
+			AccessAll: *data.AccessAll,
+			Atype:     newType,
+		}
+
+		if err := oh.inviteMember(ctx, email, uo); err != nil {
+			return err
+		}
+
+		if !*data.AccessAll {
+			for _, c := range data.Collections {
+				cl, err := oh.cs.FindByCollectionOrg(ctx, c.ID, oUuid)
+				if err != nil {
+					return err
+				}
+
+				if err := oh.ucs.Save(ctx, cl.Uuid, uo.UserUuid, c.ReadOnly, c.HidePasswords); err != nil {
+					return err
+				}
+			}
+		}
+
+		// TODO: send email
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// inviteMember adds the user of email to the organization as uo, the user
+// is created when the email isn't registered yet.
+func (oh *OrganizationHandler) inviteMember(ctx context.Context, email string, uo *model.UserOrganization) error {
+	email = strings.ToLower(email)
+
+	if err := oh.checkSeats(ctx, uo.OrgUuid); err != nil {
+		return err
+	}
+
+	status := model.UOStatusAccepted
+	if oh.mailEnabled {
+		status = model.UOStatusInvited
+	}
+
+	user, err := oh.users.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	if errors.Is(err, model.ErrNotFound) {
+		if !oh.cfgs.IsInvitationsAllowed() {
+			return echo.NewHTTPError(http.StatusForbidden, "Invitations are disabled")
+		}
+
+		if !oh.cfgs.IsEmailDomainAllowed(email) {
+			return echo.NewHTTPError(http.StatusForbidden, "Email domain not eligible for invitations")
+		}
+
+		if !oh.mailEnabled {
+			if err := oh.is.Save(ctx, &model.Invitation{Email: email}); err != nil {
+				return err
+			}
+		}
+
+		user, err = newInvitedUser(email, oh.cfgs.PasswordIterations)
+		if err != nil {
+			return err
+		}
+		if err := oh.users.Create(ctx, user); err != nil {
+			return err
+		}
+
+		status = model.UOStatusInvited
+	} else {
+		_, err := oh.uos.FindByUserAndOrg(ctx, user.Uuid, uo.OrgUuid)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "User already in organization: "+email)
+		}
+		if !errors.Is(err, model.ErrNotFound) {
+			return err
+		}
+
+		if status != model.UOStatusInvited {
+			if err := oh.checkJoin(ctx, user.Uuid, uo.OrgUuid, uo.Atype); err != nil {
+				return err
+			}
+		}
+	}
+
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		return err
+	}
+
+	uo.Uuid = id
+	uo.UserUuid = user.Uuid
+	uo.Status = status
+
+	if err := oh.uos.Save(ctx, uo); err != nil {
+		return err
+	}
+
+	return oh.logMemberEvent(ctx, model.EventOrgUserInvited, uo)
+}
+
+// newInvitedUser returns the placeholder user created for an invited email.
+// It has no password until the invitee registers.
+func newInvitedUser(email string, iterations int) (*model.User, error) {
+	id, err := crypto.GenerateUuid()
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := crypto.GenerateUuid()
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := crypto.GenerateBytes(64)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &model.User{
+		Uuid:               id,
+		Enabled:            true,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		Email:              email,
+		Name:               email,
+		Salt:               salt,
+		PasswordIterations: iterations,
+		SecurityStamp:      ss,
+		ClientKdfType:      model.ClientKdfTypeDefault,
+		ClientKdfIter:      model.ClientKdfIterDefault,
+	}
+
+	return user, nil
+}
+
+func (oh *OrganizationHandler) GetUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	uoUuid := c.Param("uouuid")
+
+	uo, err := oh.uos.FindByUuid(ctx, uoUuid)
+	if err != nil {
+		return err
+	}
+
+	if uo.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "The specified user isn't a member of the organization")
+	}
+
+	var cls []*model.UOCollection
+
+	if !uo.AccessAll {
+		cs, err := oh.ucs.Find(ctx, &model.UCFilter{UserUuid: &uo.UserUuid, OrgUuid: &oUuid})
+		if err != nil {
+			return err
+		}
+
+		for _, c := range cs {
+			cls = append(cls, &model.UOCollection{
+				ID:            c.CollectionUuid,
+				ReadOnly:      c.ReadOnly,
+				HidePasswords: c.HidePasswords,
+			})
+		}
+
+	}
+
+	d := &model.UODetail{
+		ID:          uo.Uuid,
+		UserID:      uo.UserUuid,
+		Status:      int(uo.Status),
+		Type:        int(uo.Atype),
+		AccessAll:   uo.AccessAll,
+		Collections: cls,
+		Object:      "organizationUserDetails",
+
+		ResetPasswordEnrolled: uo.ResetPasswordKey != nil,
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+func (oh *OrganizationHandler) PutUser(c echo.Context) error {
+	return oh.EditUser(c)
+}
+
+func (oh *OrganizationHandler) DeleteUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+	uoUuid := c.Param("uouuid")
+
+	userOrg := auth.GetUserOrganization(c)
+	userOrgType := userOrg.Atype
+
+	if err := oh.deleteUserOrg(ctx, uoUuid, oUuid, &userOrgType); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (oh OrganizationHandler) deleteUserOrg(ctx context.Context, uoUuid, oUuid string, userOrgType *model.UOType) error {
+	uo, err := oh.uos.FindByUuid(ctx, uoUuid)
+	if err != nil {
+		return err
+	}
+
+	if uo.OrgUuid != oUuid {
+		return echo.NewHTTPError(http.StatusBadRequest, "User to delete isn't member of the organization")
+	}
+
+	if uo.Atype != model.UOTypeUser && *userOrgType != model.UOTypeOwner {
+		return echo.NewHTTPError(http.StatusForbidden, "Only Owners can delete Admins or Owners")
+	}
+
+	if uo.Atype == model.UOTypeOwner {
+		owner := model.UOTypeOwner
+		uolist, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid, Atype: &owner})
+		if err != nil {
+			return err
+		}
+
+		if len(uolist) <= 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Can't delete the last owner")
+		}
+	}
+
+	return oh.removeMember(ctx, uo)
+}
+
+// removeMember takes the user out of the organization and its collections.
+func (oh OrganizationHandler) removeMember(ctx context.Context, uo *model.UserOrganization) error {
+	now := time.Now()
+	uu := &model.UpdateUser{
+		Uuid:      uo.UserUuid,
+		UpdatedAt: &now,
+	}
+	if err := oh.users.Update(ctx, uu); err != nil {
+		return err
+	}
+
+	if err := oh.ucs.DeleteAllByUserAndOrg(ctx, uo.UserUuid, uo.OrgUuid); err != nil {
+		return err
+	}
+
+	if err := oh.groups.DeleteAllByMember(ctx, uo.Uuid); err != nil {
+		return err
+	}
+
+	if err := oh.uos.Delete(ctx, uo.Uuid); err != nil {
+		return err
+	}
+
+	return oh.logMemberEvent(ctx, model.EventOrgUserRemoved, uo)
+}
+
+// logMemberEvent records atype about the membership uo.
+func (oh OrganizationHandler) logMemberEvent(ctx context.Context, atype model.EventType, uo *model.UserOrganization) error {
+	return logEvent(ctx, oh.events, &model.Event{
+		Atype:       atype,
+		OrgUuid:     &uo.OrgUuid,
+		UserUuid:    &uo.UserUuid,
+		OrgUserUuid: &uo.Uuid,
+	})
+}
+
+// logCollectionEvent records atype about the collection cl.
+func (oh OrganizationHandler) logCollectionEvent(ctx context.Context, atype model.EventType, cl *model.Collection) error {
+	return logEvent(ctx, oh.events, &model.Event{
+		Atype:          atype,
+		OrgUuid:        &cl.OrgUuid,
+		CollectionUuid: &cl.Uuid,
+	})
+}
+
+type OrgIDsData struct {
+	IDs []string `json:"Ids"`
+}
+
+func (oh *OrganizationHandler) BulkDeleteUser(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	oUuid := c.Param("ouuid")
+
+	userOrg := auth.GetUserOrganization(c)
+	userOrgType := userOrg.Atype
+
+	var data OrgIDsData
+	if err := c.Bind(&data); err != nil {
+		return err
+	}
+
+	return oh.bulkResponse(c, data.IDs, func(id string) error {
+		return oh.deleteUserOrg(ctx, id, oUuid, &userOrgType)
+	})
+}
+
+func (oh *OrganizationHandler) PostDeleteUser(c echo.Context) error {
+	return oh.DeleteUser(c)
+}
+
+// loadStorage fills in the attachment usage reported with the organization.
+func (oh *OrganizationHandler) loadStorage(ctx context.Context, org *model.Organization) error {
+	used, err := oh.as.SizeByOrg(ctx, org.Uuid)
+	if err != nil {
+		return err
+	}
+
+	org.Storage = model.Storage{Used: used, Limit: org.Limits.StorageLimit(oh.cfgs.OrgStorageLimit())}
+
+	return nil
+}
+
+// checkSeats rejects one more member when the seats of the organization
+// are taken, revoked members don't hold a seat.
+func (oh *OrganizationHandler) checkSeats(ctx context.Context, oUuid string) error {
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	if org.Limits.MaxSeats == nil {
+		return nil
+	}
+
+	uos, err := oh.uos.Find(ctx, &model.UOFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	taken := 0
+	for _, uo := range uos {
+		if uo.Status != model.UOStatusRevoked {
+			taken++
+		}
+	}
+
+	if taken >= *org.Limits.MaxSeats {
+		return echo.NewHTTPError(http.StatusBadRequest, "You have reached the maximum number of seats of the organization")
+	}
+
+	return nil
+}
+
+// checkCollections rejects one more collection when the organization has
+// reached its limit.
+func (oh *OrganizationHandler) checkCollections(ctx context.Context, oUuid string) error {
+	org, err := oh.orgs.FindByUuid(ctx, oUuid)
+	if err != nil {
+		return err
+	}
+
+	if org.Limits.MaxCollections == nil {
+		return nil
+	}
+
+	collections, err := oh.cs.Find(ctx, &model.CollectionFilter{OrgUuid: &oUuid})
+	if err != nil {
+		return err
+	}
+
+	if len(collections) >= *org.Limits.MaxCollections {
+		return echo.NewHTTPError(http.StatusBadRequest, "You have reached the maximum number of collections of the organization")
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/togls/gowarden/model"
+)
+
+func TestSeatLimit(t *testing.T) {
+	seats := func(n int) model.OrgLimits { return model.OrgLimits{MaxSeats: &n} }
+
+	tests := []struct {
+		name    string
+		limits  model.OrgLimits
+		revoked bool
+		emails  []string
+		want    int
+	}{
+		{"no limit", model.OrgLimits{}, false, []string{"a@example.com", "b@example.com"}, http.StatusOK},
+		{"free seat", seats(3), false, []string{"a@example.com"}, http.StatusOK},
+		{"no free seat", seats(2), false, []string{"a@example.com"}, http.StatusBadRequest},
+		{"seat of a revoked member", seats(2), true, []string{"a@example.com"}, http.StatusOK},
+		{"more emails than free seats", seats(3), false, []string{"a@example.com", "b@example.com"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+
+			owner, token := ts.addUser("owner@example.com")
+			member, _ := ts.addUser("member@example.com")
+
+			org := ts.addOrg(tt.limits)
+			ts.addMember(org, owner, model.UOTypeOwner)
+			uo := ts.addMember(org, member, model.UOTypeUser)
+			if tt.revoked {
+				uo.Status = model.UOStatusRevoked
+			}
+
+			rec := ts.do(http.MethodPost, "/api/organizations/"+org.Uuid+"/users/invite", token, map[string]any{
+				"Emails":      tt.emails,
+				"Type":        model.UOTypeUser,
+				"AccessAll":   false,
+				"Collections": []any{},
+			})
+			ts.expect(rec, tt.want)
+
+			if rec.Code != http.StatusOK && !strings.Contains(rec.Body.String(), "maximum number of seats") {
+				t.Errorf("rejected for another reason: %s", rec.Body.String())
+			}
+
+			if tt.limits.MaxSeats == nil {
+				return
+			}
+
+			taken := 0
+			for _, uo := range ts.db.uos {
+				if uo.OrgUuid == org.Uuid && uo.Status != model.UOStatusRevoked {
+					taken++
+				}
+			}
+			if taken > *tt.limits.MaxSeats {
+				t.Errorf("%d seats taken, the organization has %d", taken, *tt.limits.MaxSeats)
+			}
+		})
+	}
+}
+
+func TestSeatLimitRestore(t *testing.T) {
+	ts, org, key := newScimServer(t)
+
+	seats := 2
+	org.Limits.MaxSeats = &seats
+
+	for _, email := range []string{"owner@example.com", "member@example.com"} {
+		user, _ := ts.addUser(email)
+		ts.addMember(org, user, model.UOTypeUser)
+	}
+
+	revoked, _ := ts.addUser("revoked@example.com")
+	uo := ts.addMember(org, revoked, model.UOTypeUser)
+	uo.Status = model.UOStatusRevoked
+
+	// the directory can't bring back a member without a free seat
+	rec := ts.do(http.MethodPatch, "/scim/v2/"+org.Uuid+"/Users/"+uo.Uuid, key,
+		`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","value":{"active":true}}]}`)
+	ts.expect(rec, http.StatusBadRequest)
+
+	if got := ts.db.uos[uo.Uuid].Status; got != model.UOStatusRevoked {
+		t.Errorf("member status = %d, want revoked", got)
+	}
+}
+
+func TestCollectionLimit(t *testing.T) {
+	ts := newTestServer(t)
+
+	max := 2
+	org := ts.addOrg(model.OrgLimits{MaxCollections: &max})
+
+	admin, token := ts.addUser("admin@example.com")
+	ts.addMember(org, admin, model.UOTypeAdmin)
+
+	ts.addCollection(org)
+
+	path := "/api/organizations/" + org.Uuid + "/collections"
+
+	ts.expect(ts.do(http.MethodPost, path, token, map[string]any{"Name": "second"}), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, path, token, map[string]any{"Name": "third"}), http.StatusBadRequest)
+
+	if len(ts.db.collections) != max {
+		t.Errorf("organization has %d collections, want %d", len(ts.db.collections), max)
+	}
+
+	// the limit of one organization doesn't hold back another
+	other := ts.addOrg(model.OrgLimits{})
+	ts.addMember(other, admin, model.UOTypeAdmin)
+	ts.expect(ts.do(http.MethodPost, "/api/organizations/"+other.Uuid+"/collections", token, map[string]any{"Name": "other"}), http.StatusOK)
+}
@@ -1,39 +1,71 @@
-package response
-
-import (
-	"fmt"
-
-	"github.com/togls/gowarden/model"
-)
-
-type Attachment struct {
-	ID       string  `json:"Id"`
-	Url      string  `json:"Url"`
-	FileName string  `json:"FileName"`
-	Size     string  `json:"Size"`
-	SizeName string  `json:"SizeName"`
-	Key      *string `json:"Key"`
-	Object   string  `json:"Object"`
-}
-
-func NewAttachment(attachment *model.Attachment, host string) *Attachment {
-	return &Attachment{
-		ID:       attachment.ID,
-		Url:      fmt.Sprintf("%s/attachments/%s/%s", host, attachment.CipherUuid, attachment.ID),
-		FileName: attachment.FileName,
-		Size:     fmt.Sprintf("%d", attachment.FileSize),
-		SizeName: fmt.Sprintf("%d bytes", attachment.FileSize), // TODO: use humanize
-		Key:      attachment.Akey,
-		Object:   "attachment",
-	}
-}
-
-func NewAttachments(attachments []*model.Attachment, host string) []*Attachment {
-	items := make([]*Attachment, len(attachments))
-
-	for i := range attachments {
-		items[i] = NewAttachment(attachments[i], host)
-	}
-
-	return items
-}
+package response
+
+import (
+	"fmt"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Attachment struct {
+	ID       string  `json:"Id"`
+	Url      string  `json:"Url"`
+	FileName string  `json:"FileName"`
+	Size     string  `json:"Size"`
+	SizeName string  `json:"SizeName"`
+	Key      *string `json:"Key"`
+	Object   string  `json:"Object"`
+}
+
+// AttachmentURL returns the download url of an attachment.
+type AttachmentURL func(attachment *model.Attachment) string
+
+func NewAttachment(attachment *model.Attachment, url AttachmentURL) *Attachment {
+	return &Attachment{
+		ID:       attachment.ID,
+		Url:      url(attachment),
+		FileName: attachment.FileName,
+		Size:     fmt.Sprintf("%d", attachment.FileSize),
+		SizeName: model.ReadableSize(int64(attachment.FileSize)),
+		Key:      attachment.Akey,
+		Object:   "attachment",
+	}
+}
+
+func NewAttachments(attachments []*model.Attachment, url AttachmentURL) []*Attachment {
+	items := make([]*Attachment, len(attachments))
+
+	for i := range attachments {
+		items[i] = NewAttachment(attachments[i], url)
+	}
+
+	return items
+}
+
+type AttachmentUpload struct {
+	Object             string  `json:"Object"`
+	AttachmentId       string  `json:"AttachmentId"`
+	Url                string  `json:"Url"`
+	FileUploadType     int     `json:"FileUploadType"`
+	CipherResponse     *Cipher `json:"CipherResponse"`
+	CipherMiniResponse *Cipher `json:"CipherMiniResponse"`
+}
+
+// FileUploadTypeDirect tells the client to post the file data back to Url.
+const FileUploadTypeDirect = 0
+
+func NewAttachmentUpload(attachment *model.Attachment, cipher *Cipher, admin bool) *AttachmentUpload {
+	item := &AttachmentUpload{
+		Object:         "attachment-fileUpload",
+		AttachmentId:   attachment.ID,
+		Url:            fmt.Sprintf("/ciphers/%s/attachment/%s", attachment.CipherUuid, attachment.ID),
+		FileUploadType: FileUploadTypeDirect,
+	}
+
+	if admin {
+		item.CipherMiniResponse = cipher
+	} else {
+		item.CipherResponse = cipher
+	}
+
+	return item
+}
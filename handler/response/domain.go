@@ -1,9 +1,9 @@
-package response
-
-import "github.com/togls/gowarden/config"
-
-type Domains struct {
-	EquivalentDomains       [][]string
-	GlobalEquivalentDomains config.GlobalDomains
-	Object                  string `json:"object"`
-}
+package response
+
+import "github.com/togls/gowarden/config"
+
+type Domains struct {
+	EquivalentDomains       [][]string
+	GlobalEquivalentDomains config.GlobalDomains
+	Object                  string `json:"object"`
+}
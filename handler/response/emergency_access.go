@@ -0,0 +1,102 @@
+package response
+
+import (
+	"github.com/togls/gowarden/model"
+)
+
+type EmergencyAccess struct {
+	Id           string `json:"Id"`
+	Status       int    `json:"Status"`
+	Type         int    `json:"Type"`
+	WaitTimeDays int    `json:"WaitTimeDays"`
+	Object       string `json:"Object"`
+}
+
+func NewEmergencyAccess(ea *model.EmergencyAccess) *EmergencyAccess {
+	return &EmergencyAccess{
+		Id:           ea.Uuid,
+		Status:       int(ea.Status),
+		Type:         int(ea.Atype),
+		WaitTimeDays: ea.WaitTimeDays,
+		Object:       "emergencyAccess",
+	}
+}
+
+// EmergencyAccessDetails is an emergency access together with the user on
+// the other side of it.
+type EmergencyAccessDetails struct {
+	Id           string  `json:"Id"`
+	Status       int     `json:"Status"`
+	Type         int     `json:"Type"`
+	WaitTimeDays int     `json:"WaitTimeDays"`
+	GranteeId    *string `json:"GranteeId,omitempty"`
+	GrantorId    *string `json:"GrantorId,omitempty"`
+	Email        *string `json:"Email"`
+	Name         *string `json:"Name"`
+	Object       string  `json:"Object"`
+}
+
+// NewEmergencyAccessGrantee shows the grantor who they trust. Until the
+// invite is accepted grantee is nil and only the invited email is known.
+func NewEmergencyAccessGrantee(ea *model.EmergencyAccess, grantee *model.User) *EmergencyAccessDetails {
+	item := &EmergencyAccessDetails{
+		Id:           ea.Uuid,
+		Status:       int(ea.Status),
+		Type:         int(ea.Atype),
+		WaitTimeDays: ea.WaitTimeDays,
+		Email:        ea.Email,
+		Object:       "emergencyAccessGranteeDetails",
+	}
+
+	if grantee != nil {
+		item.GranteeId = &grantee.Uuid
+		item.Email = &grantee.Email
+		item.Name = &grantee.Name
+	}
+
+	return item
+}
+
+// NewEmergencyAccessGrantor shows the grantee who granted them access.
+func NewEmergencyAccessGrantor(ea *model.EmergencyAccess, grantor *model.User) *EmergencyAccessDetails {
+	return &EmergencyAccessDetails{
+		Id:           ea.Uuid,
+		Status:       int(ea.Status),
+		Type:         int(ea.Atype),
+		WaitTimeDays: ea.WaitTimeDays,
+		GrantorId:    &grantor.Uuid,
+		Email:        &grantor.Email,
+		Name:         &grantor.Name,
+		Object:       "emergencyAccessGrantorDetails",
+	}
+}
+
+type EmergencyAccessView struct {
+	Ciphers      []*Cipher `json:"Ciphers"`
+	KeyEncrypted *string   `json:"KeyEncrypted"`
+	Object       string    `json:"Object"`
+}
+
+func NewEmergencyAccessView(ea *model.EmergencyAccess, ciphers []*Cipher) *EmergencyAccessView {
+	return &EmergencyAccessView{
+		Ciphers:      ciphers,
+		KeyEncrypted: ea.KeyEncrypted,
+		Object:       "emergencyAccessView",
+	}
+}
+
+type EmergencyAccessTakeover struct {
+	Kdf           int     `json:"Kdf"`
+	KdfIterations int     `json:"KdfIterations"`
+	KeyEncrypted  *string `json:"KeyEncrypted"`
+	Object        string  `json:"Object"`
+}
+
+func NewEmergencyAccessTakeover(ea *model.EmergencyAccess, grantor *model.User) *EmergencyAccessTakeover {
+	return &EmergencyAccessTakeover{
+		Kdf:           grantor.ClientKdfType,
+		KdfIterations: grantor.ClientKdfIter,
+		KeyEncrypted:  ea.KeyEncrypted,
+		Object:        "emergencyAccessTakeover",
+	}
+}
@@ -0,0 +1,49 @@
+package response
+
+import (
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Event struct {
+	Type               int       `json:"Type"`
+	UserId             *string   `json:"UserId"`
+	OrganizationId     *string   `json:"OrganizationId"`
+	CipherId           *string   `json:"CipherId"`
+	CollectionId       *string   `json:"CollectionId"`
+	GroupId            *string   `json:"GroupId"`
+	OrganizationUserId *string   `json:"OrganizationUserId"`
+	ActingUserId       *string   `json:"ActingUserId"`
+	Date               time.Time `json:"Date"`
+	DeviceType         *int      `json:"DeviceType"`
+	IpAddress          *string   `json:"IpAddress"`
+	PolicyId           *string   `json:"PolicyId"`
+	Object             string    `json:"Object"`
+}
+
+func NewEvent(event *model.Event) *Event {
+	return &Event{
+		Type:               int(event.Atype),
+		UserId:             event.UserUuid,
+		OrganizationId:     event.OrgUuid,
+		CipherId:           event.CipherUuid,
+		CollectionId:       event.CollectionUuid,
+		GroupId:            event.GroupUuid,
+		OrganizationUserId: event.OrgUserUuid,
+		ActingUserId:       event.ActUserUuid,
+		Date:               event.EventDate,
+		DeviceType:         event.DeviceType,
+		IpAddress:          event.IpAddress,
+		PolicyId:           event.PolicyUuid,
+		Object:             "event",
+	}
+}
+
+func NewEvents(events []*model.Event) []*Event {
+	result := make([]*Event, 0, len(events))
+	for _, event := range events {
+		result = append(result, NewEvent(event))
+	}
+	return result
+}
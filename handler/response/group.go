@@ -0,0 +1,53 @@
+package response
+
+import "github.com/togls/gowarden/model"
+
+type Group struct {
+	Id             string  `json:"Id"`
+	OrganizationId string  `json:"OrganizationId"`
+	Name           string  `json:"Name"`
+	AccessAll      bool    `json:"AccessAll"`
+	ExternalId     *string `json:"ExternalId"`
+	Object         string  `json:"Object"`
+}
+
+func NewGroup(group *model.Group) *Group {
+	return &Group{
+		Id:             group.Uuid,
+		OrganizationId: group.OrgUuid,
+		Name:           group.Name,
+		AccessAll:      group.AccessAll,
+		ExternalId:     group.ExternalId,
+		Object:         "group",
+	}
+}
+
+type GroupCollection struct {
+	Id            string `json:"Id"`
+	ReadOnly      bool   `json:"ReadOnly"`
+	HidePasswords bool   `json:"HidePasswords"`
+}
+
+type GroupDetails struct {
+	*Group
+	Collections []*GroupCollection `json:"Collections"`
+}
+
+func NewGroupDetails(group *model.Group, collections []*model.CollectionGroup) *GroupDetails {
+	details := &GroupDetails{
+		Group:       NewGroup(group),
+		Collections: make([]*GroupCollection, 0, len(collections)),
+	}
+
+	for _, cg := range collections {
+		details.Collections = append(details.Collections, &GroupCollection{
+			Id:            cg.CollectionUuid,
+			ReadOnly:      cg.ReadOnly,
+			HidePasswords: cg.HidePasswords,
+		})
+	}
+
+	details.Object = "groupDetails"
+
+	return details
+}
@@ -1,35 +1,41 @@
-package response
-
-import (
-	"encoding/json"
-
-	"github.com/togls/gowarden/model"
-)
-
-type Policy struct {
-	Id             string          `json:"Id"`
-	OrganizationId string          `json:"OrganizationId"`
-	Type           int             `json:"Type"`
-	Data           json.RawMessage `json:"Data"`
-	Enabled        bool            `json:"Enabled"`
-	Object         string          `json:"Object"`
-}
-
-func NewPolicy(policy *model.OrgPolicy) *Policy {
-	return &Policy{
-		Id:             policy.Uuid,
-		OrganizationId: policy.OrgUuid,
-		Type:           int(policy.Atype),
-		Data:           policy.Data,
-		Enabled:        policy.Enabled,
-		Object:         "policy",
-	}
-}
-
-func NewPolicies(policies []*model.OrgPolicy) []*Policy {
-	var result []*Policy
-	for _, policy := range policies {
-		result = append(result, NewPolicy(policy))
-	}
-	return result
-}
+package response
+
+import (
+	"encoding/json"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Policy struct {
+	Id             string          `json:"Id"`
+	OrganizationId string          `json:"OrganizationId"`
+	Type           int             `json:"Type"`
+	Data           json.RawMessage `json:"Data"`
+	Enabled        bool            `json:"Enabled"`
+	Object         string          `json:"Object"`
+}
+
+func NewPolicy(policy *model.OrgPolicy) *Policy {
+	p := &Policy{
+		Id:             policy.Uuid,
+		OrganizationId: policy.OrgUuid,
+		Type:           int(policy.Atype),
+		Enabled:        policy.Enabled,
+		Object:         "policy",
+	}
+
+	// an empty RawMessage is invalid json, leave it nil to encode null
+	if len(policy.Data) > 0 {
+		p.Data = policy.Data
+	}
+
+	return p
+}
+
+func NewPolicies(policies []*model.OrgPolicy) []*Policy {
+	result := make([]*Policy, 0, len(policies))
+	for _, policy := range policies {
+		result = append(result, NewPolicy(policy))
+	}
+	return result
+}
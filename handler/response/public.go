@@ -0,0 +1,159 @@
+package response
+
+import (
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+// The public API of Bitwarden names its fields in camel case, unlike the
+// client API.
+
+type PublicList struct {
+	Object            string `json:"object"`
+	Data              any    `json:"data"`
+	ContinuationToken any    `json:"continuationToken"`
+}
+
+func NewPublicList(data any, token any) *PublicList {
+	return &PublicList{
+		Object:            "list",
+		Data:              data,
+		ContinuationToken: token,
+	}
+}
+
+type PublicAssociation struct {
+	Id       string `json:"id"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+type PublicMember struct {
+	Object                string               `json:"object"`
+	Id                    string               `json:"id"`
+	UserId                string               `json:"userId"`
+	Name                  string               `json:"name"`
+	Email                 string               `json:"email"`
+	TwoFactorEnabled      bool                 `json:"twoFactorEnabled"`
+	Status                int                  `json:"status"`
+	Type                  int                  `json:"type"`
+	AccessAll             bool                 `json:"accessAll"`
+	ExternalId            *string              `json:"externalId"`
+	ResetPasswordEnrolled bool                 `json:"resetPasswordEnrolled"`
+	Collections           []*PublicAssociation `json:"collections"`
+}
+
+// NewPublicMember maps the membership uo of user, collections are the
+// direct grants of the member.
+func NewPublicMember(uo *model.UserOrganization, user *model.User, twoFactor bool, collections []*model.UserCollection) *PublicMember {
+	m := &PublicMember{
+		Object:                "member",
+		Id:                    uo.Uuid,
+		UserId:                uo.UserUuid,
+		Name:                  user.Name,
+		Email:                 user.Email,
+		TwoFactorEnabled:      twoFactor,
+		Status:                int(uo.Status),
+		Type:                  int(uo.Atype),
+		AccessAll:             uo.AccessAll,
+		ExternalId:            uo.ExternalId,
+		ResetPasswordEnrolled: uo.ResetPasswordKey != nil,
+		Collections:           make([]*PublicAssociation, 0, len(collections)),
+	}
+
+	for _, uc := range collections {
+		m.Collections = append(m.Collections, &PublicAssociation{
+			Id:       uc.CollectionUuid,
+			ReadOnly: uc.ReadOnly,
+		})
+	}
+
+	return m
+}
+
+type PublicCollection struct {
+	Object     string               `json:"object"`
+	Id         string               `json:"id"`
+	ExternalId *string              `json:"externalId"`
+	Groups     []*PublicAssociation `json:"groups"`
+}
+
+func NewPublicCollection(cl *model.Collection, groups []*model.CollectionGroup) *PublicCollection {
+	p := &PublicCollection{
+		Object: "collection",
+		Id:     cl.Uuid,
+		Groups: make([]*PublicAssociation, 0, len(groups)),
+	}
+
+	for _, cg := range groups {
+		p.Groups = append(p.Groups, &PublicAssociation{
+			Id:       cg.GroupUuid,
+			ReadOnly: cg.ReadOnly,
+		})
+	}
+
+	return p
+}
+
+type PublicGroup struct {
+	Object      string               `json:"object"`
+	Id          string               `json:"id"`
+	Name        string               `json:"name"`
+	AccessAll   bool                 `json:"accessAll"`
+	ExternalId  *string              `json:"externalId"`
+	Collections []*PublicAssociation `json:"collections"`
+}
+
+func NewPublicGroup(group *model.Group, collections []*model.CollectionGroup) *PublicGroup {
+	p := &PublicGroup{
+		Object:      "group",
+		Id:          group.Uuid,
+		Name:        group.Name,
+		AccessAll:   group.AccessAll,
+		ExternalId:  group.ExternalId,
+		Collections: make([]*PublicAssociation, 0, len(collections)),
+	}
+
+	for _, cg := range collections {
+		p.Collections = append(p.Collections, &PublicAssociation{
+			Id:       cg.CollectionUuid,
+			ReadOnly: cg.ReadOnly,
+		})
+	}
+
+	return p
+}
+
+type PublicEvent struct {
+	Object       string    `json:"object"`
+	Type         int       `json:"type"`
+	ItemId       *string   `json:"itemId"`
+	CollectionId *string   `json:"collectionId"`
+	GroupId      *string   `json:"groupId"`
+	PolicyId     *string   `json:"policyId"`
+	MemberId     *string   `json:"memberId"`
+	ActingUserId *string   `json:"actingUserId"`
+	Date         time.Time `json:"date"`
+	Device       *int      `json:"device"`
+	IpAddress    *string   `json:"ipAddress"`
+}
+
+func NewPublicEvents(events []*model.Event) []*PublicEvent {
+	result := make([]*PublicEvent, 0, len(events))
+	for _, event := range events {
+		result = append(result, &PublicEvent{
+			Object:       "event",
+			Type:         int(event.Atype),
+			ItemId:       event.CipherUuid,
+			CollectionId: event.CollectionUuid,
+			GroupId:      event.GroupUuid,
+			PolicyId:     event.PolicyUuid,
+			MemberId:     event.OrgUserUuid,
+			ActingUserId: event.ActUserUuid,
+			Date:         event.EventDate,
+			Device:       event.DeviceType,
+			IpAddress:    event.IpAddress,
+		})
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package response
+
+import (
+	"strconv"
+
+	"github.com/togls/gowarden/model"
+)
+
+const (
+	ScimSchemaUser  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	ScimSchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ScimSchemaList  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	ScimSchemaError = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ScimSchemaPatch = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+)
+
+type ScimMeta struct {
+	ResourceType string `json:"resourceType"`
+}
+
+type ScimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Primary bool   `json:"primary"`
+}
+
+type ScimUser struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id"`
+	ExternalId  *string     `json:"externalId"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName"`
+	Active      bool        `json:"active"`
+	Emails      []ScimEmail `json:"emails"`
+	Meta        ScimMeta    `json:"meta"`
+}
+
+// NewScimUser maps the membership uo of user to a SCIM user, the id is the
+// one of the membership.
+func NewScimUser(uo *model.UserOrganization, user *model.User) *ScimUser {
+	return &ScimUser{
+		Schemas:     []string{ScimSchemaUser},
+		Id:          uo.Uuid,
+		ExternalId:  uo.ExternalId,
+		UserName:    user.Email,
+		DisplayName: user.Name,
+		Active:      uo.Status != model.UOStatusRevoked,
+		Emails: []ScimEmail{
+			{Value: user.Email, Type: "work", Primary: true},
+		},
+		Meta: ScimMeta{ResourceType: "User"},
+	}
+}
+
+type ScimMember struct {
+	Value string `json:"value"`
+}
+
+type ScimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	Id          string       `json:"id"`
+	ExternalId  *string      `json:"externalId"`
+	DisplayName string       `json:"displayName"`
+	Members     []ScimMember `json:"members"`
+	Meta        ScimMeta     `json:"meta"`
+}
+
+// NewScimGroup maps the group to a SCIM group, members are membership
+// uuids.
+func NewScimGroup(group *model.Group, members []string) *ScimGroup {
+	g := &ScimGroup{
+		Schemas:     []string{ScimSchemaGroup},
+		Id:          group.Uuid,
+		ExternalId:  group.ExternalId,
+		DisplayName: group.Name,
+		Members:     make([]ScimMember, 0, len(members)),
+		Meta:        ScimMeta{ResourceType: "Group"},
+	}
+
+	for _, m := range members {
+		g.Members = append(g.Members, ScimMember{Value: m})
+	}
+
+	return g
+}
+
+type ScimList struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+func NewScimList(resources any, total, startIndex, count int) *ScimList {
+	return &ScimList{
+		Schemas:      []string{ScimSchemaList},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+type ScimError struct {
+	Schemas []string `json:"schemas"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail"`
+}
+
+func NewScimError(status int, detail string) *ScimError {
+	return &ScimError{
+		Schemas: []string{ScimSchemaError},
+		Status:  strconv.Itoa(status),
+		Detail:  detail,
+	}
+}
@@ -1,71 +1,159 @@
-package response
-
-import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"time"
-
-	"github.com/togls/gowarden/model"
-)
-
-type Send struct {
-	AccessCount    int             `json:"AccessCount"`
-	AccessId       string          `json:"AccessId"`
-	DeletionDate   time.Time       `json:"DeletionDate"`
-	Disabled       bool            `json:"Disabled"`
-	ExpirationDate *time.Time      `json:"ExpirationDate"`
-	File           json.RawMessage `json:"File"`
-	HideEmail      *bool           `json:"HideEmail"`
-	Id             string          `json:"Id"`
-	Key            string          `json:"Key"`
-	MaxAccessCount *int            `json:"MaxAccessCount"`
-	Name           string          `json:"Name"`
-	Notes          *string         `json:"Notes"`
-	Object         string          `json:"Object"`
-	Password       *string         `json:"Password"`
-	RevisionDate   time.Time       `json:"RevisionDate"`
-	Text           json.RawMessage `json:"Text"`
-	Type           int             `json:"Type"`
-}
-
-func NewSend(src *model.Send) (*Send, error) {
-	aux := Send{
-		Object: "send",
-	}
-
-	id, err := base64.RawURLEncoding.DecodeString(src.Uuid)
-	if err != nil {
-		return nil, fmt.Errorf("NewSend invalid send id: %s, MarshalJSON err: %w", src.Uuid, err)
-	}
-
-	aux.AccessId = string(id)
-
-	data, err := json.Marshal(src.Data)
-	if err != nil {
-		return nil, fmt.Errorf("NewSend MarshalJSON err: %w", err)
-	}
-
-	switch src.Atype {
-	case model.SendTypeText:
-		aux.Text = data
-	case model.SendTypeFile:
-		aux.File = data
-	default:
-		return nil, fmt.Errorf("NewSend invalid send type: %d", src.Atype)
-	}
-
-	return &aux, nil
-}
-
-func NewSends(src []*model.Send) ([]*Send, error) {
-	var result []*Send
-	for _, item := range src {
-		aux, err := NewSend(item)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, aux)
-	}
-	return result, nil
-}
+package response
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Send struct {
+	AccessCount    int             `json:"AccessCount"`
+	AccessId       string          `json:"AccessId"`
+	DeletionDate   time.Time       `json:"DeletionDate"`
+	Disabled       bool            `json:"Disabled"`
+	ExpirationDate *time.Time      `json:"ExpirationDate"`
+	File           json.RawMessage `json:"File"`
+	HideEmail      *bool           `json:"HideEmail"`
+	Id             string          `json:"Id"`
+	Key            string          `json:"Key"`
+	MaxAccessCount *int            `json:"MaxAccessCount"`
+	Name           string          `json:"Name"`
+	Notes          *string         `json:"Notes"`
+	Object         string          `json:"Object"`
+	Password       *string         `json:"Password"`
+	RevisionDate   time.Time       `json:"RevisionDate"`
+	Text           json.RawMessage `json:"Text"`
+	Type           int             `json:"Type"`
+}
+
+func NewSend(src *model.Send) (*Send, error) {
+	aux := Send{
+		AccessCount:    src.AccessCount,
+		DeletionDate:   src.DeletionDate,
+		Disabled:       src.Disabled,
+		ExpirationDate: src.ExpirationDate,
+		HideEmail:      src.HideEmail,
+		Id:             src.Uuid,
+		Key:            src.Akey,
+		MaxAccessCount: src.MaxAccessCount,
+		Name:           src.Name,
+		Notes:          src.Notes,
+		Object:         "send",
+		RevisionDate:   src.RevisionDate,
+		Type:           int(src.Atype),
+	}
+
+	id, err := src.AccessId()
+	if err != nil {
+		return nil, fmt.Errorf("NewSend err: %w", err)
+	}
+
+	aux.AccessId = id
+
+	if src.PasswordHash != nil {
+		pw := base64.RawURLEncoding.EncodeToString(*src.PasswordHash)
+		aux.Password = &pw
+	}
+
+	if err := setSendData(src, &aux.Text, &aux.File); err != nil {
+		return nil, err
+	}
+
+	return &aux, nil
+}
+
+func NewSends(src []*model.Send) ([]*Send, error) {
+	result := make([]*Send, 0, len(src))
+	for _, item := range src {
+		aux, err := NewSend(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, aux)
+	}
+	return result, nil
+}
+
+// SendAccess is what anonymous users get when opening a send.
+type SendAccess struct {
+	Id                string          `json:"Id"`
+	Type              int             `json:"Type"`
+	Name              string          `json:"Name"`
+	Text              json.RawMessage `json:"Text"`
+	File              json.RawMessage `json:"File"`
+	ExpirationDate    *time.Time      `json:"ExpirationDate"`
+	CreatorIdentifier *string         `json:"CreatorIdentifier"`
+	Object            string          `json:"Object"`
+}
+
+// NewSendAccess builds the anonymous view of src, creator is only shown
+// when the send doesn't hide it.
+func NewSendAccess(src *model.Send, creator *string) (*SendAccess, error) {
+	aux := SendAccess{
+		Id:             src.Uuid,
+		Type:           int(src.Atype),
+		Name:           src.Name,
+		ExpirationDate: src.ExpirationDate,
+		Object:         "send-access",
+	}
+
+	if src.HideEmail == nil || !*src.HideEmail {
+		aux.CreatorIdentifier = creator
+	}
+
+	if err := setSendData(src, &aux.Text, &aux.File); err != nil {
+		return nil, err
+	}
+
+	return &aux, nil
+}
+
+func setSendData(src *model.Send, text, file *json.RawMessage) error {
+	if !json.Valid(src.Data) {
+		return fmt.Errorf("invalid send data: %s", src.Uuid)
+	}
+
+	switch src.Atype {
+	case model.SendTypeText:
+		*text = src.Data
+	case model.SendTypeFile:
+		*file = src.Data
+	default:
+		return fmt.Errorf("invalid send type: %d", src.Atype)
+	}
+
+	return nil
+}
+
+type SendFileUpload struct {
+	Object         string `json:"Object"`
+	Url            string `json:"Url"`
+	FileUploadType int    `json:"FileUploadType"`
+	SendResponse   *Send  `json:"SendResponse"`
+}
+
+func NewSendFileUpload(send *Send, fileId string) *SendFileUpload {
+	return &SendFileUpload{
+		Object:         "send-fileUpload",
+		Url:            fmt.Sprintf("/sends/%s/file/%s", send.Id, fileId),
+		FileUploadType: FileUploadTypeDirect,
+		SendResponse:   send,
+	}
+}
+
+type SendFileDownload struct {
+	Id     string `json:"Id"`
+	Url    string `json:"Url"`
+	Object string `json:"Object"`
+}
+
+func NewSendFileDownload(fileId, url string) *SendFileDownload {
+	return &SendFileDownload{
+		Id:     fileId,
+		Url:    url,
+		Object: "send-fileDownload",
+	}
+}
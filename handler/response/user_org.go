@@ -1,77 +1,113 @@
-package response
-
-import "github.com/togls/gowarden/model"
-
-type UserOrganization struct {
-	Enabled                 bool    `json:"Enabled"`
-	HasPublicAndPrivateKeys bool    `json:"HasPublicAndPrivateKeys"`
-	Id                      string  `json:"Id"`
-	Identifier              any     `json:"Identifier"`
-	Key                     *string `json:"Key"`
-	MaxCollections          int     `json:"MaxCollections"`
-	MaxStorageGb            int     `json:"MaxStorageGb"`
-	Name                    string  `json:"Name"`
-	Object                  string  `json:"Object"`
-	ProviderId              any     `json:"ProviderId"`
-	ProviderName            any     `json:"ProviderName"`
-	ResetPasswordEnrolled   bool    `json:"ResetPasswordEnrolled"`
-	Seats                   int     `json:"Seats"`
-	SelfHost                bool    `json:"SelfHost"`
-	SsoBound                bool    `json:"SsoBound"`
-	Status                  int     `json:"Status"`
-	Type                    int     `json:"Type"`
-	Use2fa                  bool    `json:"Use2fa"`
-	UsersGetPremium         bool    `json:"UsersGetPremium"`
-	UseDirectory            bool    `json:"UseDirectory"`
-	UseEvents               bool    `json:"UseEvents"`
-	UseGroups               bool    `json:"UseGroups"`
-	UseTotp                 bool    `json:"UseTotp"`
-	UsePolicies             bool    `json:"UsePolicies"`
-	UseApi                  bool    `json:"UseApi"`
-	UseSso                  bool    `json:"UseSso"`
-	UseBusinessPortal       bool    `json:"UseBusinessPortal"`
-}
-
-func NewUserOrganization(userOrg *model.UserOrganization) *UserOrganization {
-	return &UserOrganization{
-		Id:              userOrg.OrgUuid,
-		Identifier:      nil,
-		Name:            userOrg.Name,
-		Seats:           10,
-		MaxCollections:  10,
-		UsersGetPremium: true,
-
-		Use2fa:                  true,
-		UseDirectory:            false,
-		UseEvents:               false,
-		UseGroups:               false,
-		UseTotp:                 true,
-		UsePolicies:             true,
-		UseApi:                  false,
-		SelfHost:                true,
-		HasPublicAndPrivateKeys: (userOrg.PrivateKey != nil && userOrg.PublicKey != nil),
-		ResetPasswordEnrolled:   false,
-		SsoBound:                false,
-		UseSso:                  false,
-		UseBusinessPortal:       false,
-		ProviderId:              nil,
-		ProviderName:            nil,
-
-		MaxStorageGb: 10,
-
-		Key:     userOrg.AKey,
-		Status:  int(userOrg.Status),
-		Type:    int(userOrg.Atype),
-		Enabled: true,
-
-		Object: "profileOrganization",
-	}
-}
-
-func NewUserOrganizations(userOrgs []*model.UserOrganization) []*UserOrganization {
-	var result []*UserOrganization
-	for _, userOrg := range userOrgs {
-		result = append(result, NewUserOrganization(userOrg))
-	}
-	return result
-}
+package response
+
+import "github.com/togls/gowarden/model"
+
+type UserOrganization struct {
+	Enabled                 bool    `json:"Enabled"`
+	HasPublicAndPrivateKeys bool    `json:"HasPublicAndPrivateKeys"`
+	Id                      string  `json:"Id"`
+	Identifier              any     `json:"Identifier"`
+	Key                     *string `json:"Key"`
+	MaxCollections          *int    `json:"MaxCollections"`
+	MaxStorageGb            int     `json:"MaxStorageGb"`
+	Name                    string  `json:"Name"`
+	Object                  string  `json:"Object"`
+	ProviderId              any     `json:"ProviderId"`
+	ProviderName            any     `json:"ProviderName"`
+	ResetPasswordEnrolled   bool    `json:"ResetPasswordEnrolled"`
+	Seats                   *int    `json:"Seats"`
+	SelfHost                bool    `json:"SelfHost"`
+	SsoBound                bool    `json:"SsoBound"`
+	Status                  int     `json:"Status"`
+	Type                    int     `json:"Type"`
+	Use2fa                  bool    `json:"Use2fa"`
+	UsersGetPremium         bool    `json:"UsersGetPremium"`
+	UseDirectory            bool    `json:"UseDirectory"`
+	UseEvents               bool    `json:"UseEvents"`
+	UseGroups               bool    `json:"UseGroups"`
+	UseTotp                 bool    `json:"UseTotp"`
+	UsePolicies             bool    `json:"UsePolicies"`
+	UseResetPassword        bool    `json:"UseResetPassword"`
+	UseScim                 bool    `json:"UseScim"`
+	UseApi                  bool    `json:"UseApi"`
+	UseSso                  bool    `json:"UseSso"`
+	UseBusinessPortal       bool    `json:"UseBusinessPortal"`
+}
+
+// NewUserOrganization maps the membership, storageLimit is the server wide
+// attachment limit of organizations without their own.
+func NewUserOrganization(userOrg *model.UserOrganization, storageLimit int64) *UserOrganization {
+	return &UserOrganization{
+		Id:              userOrg.OrgUuid,
+		Identifier:      nil,
+		Name:            userOrg.Name,
+		Seats:           userOrg.Limits.MaxSeats,
+		MaxCollections:  userOrg.Limits.MaxCollections,
+		UsersGetPremium: true,
+
+		Use2fa:                  true,
+		UseDirectory:            true,
+		UseEvents:               true,
+		UseGroups:               true,
+		UseTotp:                 true,
+		UsePolicies:             true,
+		UseResetPassword:        true,
+		UseScim:                 true,
+		UseApi:                  true,
+		SelfHost:                true,
+		HasPublicAndPrivateKeys: (userOrg.PrivateKey != nil && userOrg.PublicKey != nil),
+		ResetPasswordEnrolled:   userOrg.ResetPasswordKey != nil,
+		SsoBound:                false,
+		UseSso:                  false,
+		UseBusinessPortal:       false,
+		ProviderId:              nil,
+		ProviderName:            nil,
+
+		MaxStorageGb: model.Storage{Limit: userOrg.Limits.StorageLimit(storageLimit)}.MaxStorageGb(),
+
+		Key:     userOrg.AKey,
+		Status:  int(userOrg.Status),
+		Type:    int(userOrg.Atype),
+		Enabled: true,
+
+		Object: "profileOrganization",
+	}
+}
+
+func NewUserOrganizations(userOrgs []*model.UserOrganization, storageLimit int64) []*UserOrganization {
+	var result []*UserOrganization
+	for _, userOrg := range userOrgs {
+		result = append(result, NewUserOrganization(userOrg, storageLimit))
+	}
+	return result
+}
+
+type OrgUserPublicKey struct {
+	Id     string  `json:"Id"`
+	UserId string  `json:"UserId"`
+	Key    *string `json:"Key"`
+	Object string  `json:"Object"`
+}
+
+func NewOrgUserPublicKey(uo *model.UserOrganization, user *model.User) *OrgUserPublicKey {
+	return &OrgUserPublicKey{
+		Id:     uo.Uuid,
+		UserId: user.Uuid,
+		Key:    user.PublicKey,
+		Object: "organizationUserPublicKeyResponseModel",
+	}
+}
+
+// InviteToken carries the token of an organization invite when there is no
+// mail to send it with.
+type InviteToken struct {
+	Token  string `json:"Token"`
+	Object string `json:"Object"`
+}
+
+func NewInviteToken(token string) *InviteToken {
+	return &InviteToken{
+		Token:  token,
+		Object: "organizationUserInviteToken",
+	}
+}
@@ -0,0 +1,511 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+type SendHandler struct {
+	sends store.Send
+	users store.User
+	ops   store.OrgPolicy
+	blobs store.Blob
+
+	auth   *auth.Core
+	logger *zerolog.Logger
+
+	domain        string
+	sendsAllowed  bool
+	sendSizeLimit int64
+}
+
+func NewSendHandler(
+	cfg *config.Core,
+	auth *auth.Core,
+	blobs store.Blob,
+	ops store.OrgPolicy,
+	sends store.Send,
+	users store.User,
+) *SendHandler {
+	return &SendHandler{
+		sends: sends,
+		users: users,
+		ops:   ops,
+		blobs: blobs,
+
+		auth:   auth,
+		logger: cfg.Logger,
+
+		domain:        strings.TrimSuffix(cfg.Domain, "/"),
+		sendsAllowed:  cfg.SendsAllowed,
+		sendSizeLimit: cfg.SendSizeLimit(),
+	}
+}
+
+func (sh *SendHandler) Routes(e *echo.Echo) {
+	e.POST("/api/sends/access/:access_id", sh.PostAccess)
+	e.POST("/api/sends/:uuid/access/file/:file_id", sh.PostAccessFile)
+	e.GET("/api/sends/:uuid/:file_id", sh.DownloadSendFile)
+
+	send := e.Group("/api/sends", sh.auth.RequireAuth)
+
+	send.GET("", sh.GetSends)
+	send.GET("/:uuid", sh.GetSend)
+	send.POST("", sh.PostSend)
+	send.POST("/file/v2", sh.PostSendFileV2)
+	send.POST("/:uuid/file/:file_id", sh.PostSendFileV2Data)
+	send.PUT("/:uuid", sh.PutSend)
+	send.DELETE("/:uuid", sh.DeleteSend)
+	send.PUT("/:uuid/remove-password", sh.PutRemovePassword)
+}
+
+func (sh *SendHandler) GetSends(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	sends, err := sh.sends.Find(ctx, &model.SendFilter{UserUuid: &user.Uuid})
+	if err != nil {
+		return err
+	}
+
+	data, err := response.NewSends(sends)
+	if err != nil {
+		return err
+	}
+
+	resp := struct {
+		Data              []*response.Send `json:"Data"`
+		Object            string           `json:"Object"`
+		ContinuationToken any              `json:"ContinuationToken"`
+	}{
+		Data:              data,
+		Object:            "list",
+		ContinuationToken: nil,
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (sh *SendHandler) GetSend(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	send, err := sh.ownSend(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	return sh.sendResponse(c, send)
+}
+
+type SendData struct {
+	Type           model.SendType  `json:"Type"`
+	Key            string          `json:"Key"`
+	Password       *string         `json:"Password"`
+	MaxAccessCount *int            `json:"MaxAccessCount"`
+	ExpirationDate *time.Time      `json:"ExpirationDate"`
+	DeletionDate   time.Time       `json:"DeletionDate"`
+	Disabled       bool            `json:"Disabled"`
+	HideEmail      *bool           `json:"HideEmail"`
+	Name           string          `json:"Name"`
+	Notes          *string         `json:"Notes"`
+	Text           json.RawMessage `json:"Text"`
+	File           json.RawMessage `json:"File"`
+	FileLength     *int64          `json:"FileLength"`
+}
+
+// validate checks the fields that don't depend on the stored send.
+func (sd *SendData) validate() error {
+	if sd.DeletionDate.After(time.Now().AddDate(0, 0, model.SendMaxDeletionDays)) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"You cannot have a Send with a deletion date that far into the future. Adjust the Deletion Date to a value less than 31 days from now and try again.")
+	}
+
+	return nil
+}
+
+// content returns the text or file data of the send as it is stored.
+func (sd *SendData) content() (map[string]any, error) {
+	var raw json.RawMessage
+	switch sd.Type {
+	case model.SendTypeText:
+		raw = sd.Text
+	case model.SendTypeFile:
+		raw = sd.File
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid send type")
+	}
+
+	data := make(map[string]any)
+	if err := json.Unmarshal(raw, &data); err != nil || len(data) == 0 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Send data not provided")
+	}
+
+	// the clients echo back the response object
+	delete(data, "Response")
+
+	return data, nil
+}
+
+// apply copies the editable fields onto send. The file of a file send can't
+// be changed, its data is set when the send is created.
+func (sd *SendData) apply(send *model.Send) error {
+	if send.Atype == model.SendTypeText {
+		content, err := sd.content()
+		if err != nil {
+			return err
+		}
+
+		send.Data, err = json.Marshal(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	send.Name = sd.Name
+	send.Notes = sd.Notes
+	send.Akey = sd.Key
+	send.MaxAccessCount = sd.MaxAccessCount
+	send.ExpirationDate = sd.ExpirationDate
+	send.DeletionDate = sd.DeletionDate
+	send.Disabled = sd.Disabled
+	send.HideEmail = sd.HideEmail
+
+	if sd.Password != nil {
+		return setSendPassword(send, *sd.Password)
+	}
+
+	return nil
+}
+
+func (sh *SendHandler) PostSend(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(SendData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	if err := sh.checkSend(ctx, user.Uuid, data); err != nil {
+		return err
+	}
+
+	if data.Type != model.SendTypeText {
+		return echo.NewHTTPError(http.StatusBadRequest, "File sends should use /api/sends/file/v2")
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	send := &model.Send{
+		Uuid:     id.String(),
+		UserUuid: &user.Uuid,
+		Atype:    data.Type,
+	}
+
+	if err := data.apply(send); err != nil {
+		return err
+	}
+
+	if err := sh.sends.Create(ctx, send); err != nil {
+		return err
+	}
+
+	if err := sh.users.UpdateRevision(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	return sh.sendResponse(c, send)
+}
+
+func (sh *SendHandler) PutSend(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(SendData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	if err := sh.checkSend(ctx, user.Uuid, data); err != nil {
+		return err
+	}
+
+	send, err := sh.ownSend(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if send.Atype != data.Type {
+		return echo.NewHTTPError(http.StatusBadRequest, "Sends can't change type")
+	}
+
+	if err := data.apply(send); err != nil {
+		return err
+	}
+
+	if err := sh.sends.Save(ctx, send); err != nil {
+		return err
+	}
+
+	if err := sh.users.UpdateRevision(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	return sh.sendResponse(c, send)
+}
+
+func (sh *SendHandler) DeleteSend(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	send, err := sh.ownSend(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	if err := sh.sends.Delete(ctx, send.Uuid); err != nil {
+		return err
+	}
+
+	removeSendFile(ctx, sh.blobs, sh.logger, send)
+
+	if err := sh.users.UpdateRevision(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (sh *SendHandler) PutRemovePassword(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	send, err := sh.ownSend(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	send.PasswordHash = nil
+	send.PasswordSalt = nil
+	send.PasswordIter = nil
+
+	if err := sh.sends.Save(ctx, send); err != nil {
+		return err
+	}
+
+	if err := sh.users.UpdateRevision(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	return sh.sendResponse(c, send)
+}
+
+type SendAccessData struct {
+	Password *string `json:"Password"`
+}
+
+// PostAccess opens a send for anyone holding its link.
+func (sh *SendHandler) PostAccess(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(SendAccessData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	sUuid, err := model.SendUuidFromAccessId(c.Param("access_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+
+	send, err := sh.accessibleSend(ctx, sUuid, data.Password)
+	if err != nil {
+		return err
+	}
+
+	// file sends are counted when the file is downloaded
+	if send.Atype == model.SendTypeText {
+		if err := sh.access(ctx, send); err != nil {
+			return err
+		}
+	}
+
+	var creator *string
+	if send.UserUuid != nil {
+		owner, err := sh.users.FindByUuid(ctx, *send.UserUuid)
+		if err != nil {
+			return err
+		}
+
+		creator = &owner.Email
+	}
+
+	resp, err := response.NewSendAccess(send, creator)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// accessibleSend finds the send and checks it can still be opened with
+// password.
+func (sh *SendHandler) accessibleSend(ctx context.Context, sUuid string, password *string) (*model.Send, error) {
+	if !sh.sendsAllowed {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+
+	send, err := sh.sends.FindByUuid(ctx, sUuid)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := send.Available(time.Now()); errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	} else if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+
+	if send.HasPassword() {
+		if password == nil {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Password not provided")
+		}
+
+		if !crypto.VerifyPassword(*password, *send.PasswordSalt, *send.PasswordHash, *send.PasswordIter) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid password")
+		}
+	}
+
+	return send, nil
+}
+
+// access counts one access of send.
+func (sh *SendHandler) access(ctx context.Context, send *model.Send) error {
+	err := sh.sends.Access(ctx, send.Uuid)
+	if errors.Is(err, model.ErrSendMaxAccess) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	send.AccessCount++
+
+	return nil
+}
+
+func (sh *SendHandler) ownSend(ctx context.Context, sUuid, user string) (*model.Send, error) {
+	send, err := sh.sends.FindByUuid(ctx, sUuid)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if send.UserUuid == nil || *send.UserUuid != user {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+
+	return send, nil
+}
+
+// checkSend enforces the server setting and organization policies on
+// creating or editing a send.
+func (sh *SendHandler) checkSend(ctx context.Context, user string, data *SendData) error {
+	if !sh.sendsAllowed {
+		return echo.NewHTTPError(http.StatusBadRequest, "Sends are disabled on this server")
+	}
+
+	if err := data.validate(); err != nil {
+		return err
+	}
+
+	disabled, err := sh.ops.FindBindingByUser(ctx, user, model.OPTypeDisableSend)
+	if err != nil {
+		return err
+	}
+
+	if len(disabled) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Due to an Enterprise Policy, you are only able to delete an existing Send.")
+	}
+
+	if data.HideEmail == nil || !*data.HideEmail {
+		return nil
+	}
+
+	options, err := sh.ops.FindBindingByUser(ctx, user, model.OPTypeSendOptions)
+	if err != nil {
+		return err
+	}
+
+	for _, policy := range options {
+		o, err := policy.SendOptions()
+		if err != nil {
+			sh.logger.Warn().Err(err).Str("policy", policy.Uuid).Msg("invalid send options policy")
+			continue
+		}
+
+		if o.DisableHideEmail {
+			return echo.NewHTTPError(http.StatusBadRequest, "Due to an Enterprise Policy, you are not allowed to hide your email address from recipients when creating or editing a Send.")
+		}
+	}
+
+	return nil
+}
+
+func (sh *SendHandler) sendResponse(c echo.Context, send *model.Send) error {
+	resp, err := response.NewSend(send)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func setSendPassword(send *model.Send, password string) error {
+	salt, err := crypto.GenerateBytes(64)
+	if err != nil {
+		return err
+	}
+
+	iter := model.SendPasswordIter
+	hash := crypto.GeneratePassword(password, salt, iter)
+
+	send.PasswordHash = &hash
+	send.PasswordSalt = &salt
+	send.PasswordIter = &iter
+
+	return nil
+}
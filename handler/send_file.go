@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/auth"
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/pkg/crypto"
+	"github.com/togls/gowarden/store"
+)
+
+// post_send_file_v2
+// Reserves a file send, the file data is uploaded afterwards to the
+// returned url.
+
+func (sh *SendHandler) PostSendFileV2(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(SendData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	user := auth.GetUser(c)
+
+	if err := sh.checkSend(ctx, user.Uuid, data); err != nil {
+		return err
+	}
+
+	if data.Type != model.SendTypeFile {
+		return echo.NewHTTPError(http.StatusBadRequest, "Send content is not a file")
+	}
+
+	if data.FileLength == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid send length")
+	}
+
+	size := *data.FileLength
+	if size < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Send size can't be negative")
+	}
+
+	if sh.sendSizeLimit > 0 && size > sh.sendSizeLimit {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"Send file can't be larger than %s", model.ReadableSize(sh.sendSizeLimit)))
+	}
+
+	content, err := data.content()
+	if err != nil {
+		return err
+	}
+
+	fileId, err := crypto.GenerateAttachmentId()
+	if err != nil {
+		return err
+	}
+
+	content["Id"] = fileId
+	content["Size"] = strconv.FormatInt(size, 10)
+	content["SizeName"] = model.ReadableSize(size)
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	send := &model.Send{
+		Uuid:     id.String(),
+		UserUuid: &user.Uuid,
+		Atype:    data.Type,
+	}
+
+	send.Data, err = json.Marshal(content)
+	if err != nil {
+		return err
+	}
+
+	if err := data.apply(send); err != nil {
+		return err
+	}
+
+	if err := sh.sends.Create(ctx, send); err != nil {
+		return err
+	}
+
+	if err := sh.users.UpdateRevision(ctx, user.Uuid); err != nil {
+		return err
+	}
+
+	resp, err := response.NewSend(send)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, response.NewSendFileUpload(resp, fileId))
+}
+
+// post_send_file_v2_data
+// Streams the file of a reserved send into storage, the form isn't buffered
+// so large files don't end up in memory or temp files.
+
+func (sh *SendHandler) PostSendFileV2Data(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	user := auth.GetUser(c)
+
+	send, err := sh.ownSend(ctx, c.Param("uuid"), user.Uuid)
+	if err != nil {
+		return err
+	}
+
+	file, err := send.File()
+	if err != nil || file.Id != c.Param("file_id") {
+		return echo.NewHTTPError(http.StatusNotFound, "Send file doesn't exist")
+	}
+
+	mr, err := c.Request().MultipartReader()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "No send data provided")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "No send data provided")
+		}
+
+		if part.FormName() != "data" {
+			continue
+		}
+
+		r := &exactReader{r: part, left: file.Size}
+
+		err = sh.blobs.Put(ctx, model.SendFileKey(send.Uuid, file.Id), r, file.Size)
+		if errors.Is(err, errSizeMismatch) {
+			return echo.NewHTTPError(http.StatusBadRequest, "Send file size doesn't match the reserved size")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store send file: %w", err)
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// PostAccessFile opens a file send, it hands out a url the file can be
+// downloaded from once. Every url counts as an access of the send.
+func (sh *SendHandler) PostAccessFile(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	data := new(SendAccessData)
+
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+
+	send, err := sh.accessibleSend(ctx, c.Param("uuid"), data.Password)
+	if err != nil {
+		return err
+	}
+
+	file, err := send.File()
+	if err != nil || file.Id != c.Param("file_id") {
+		return echo.NewHTTPError(http.StatusNotFound, "Send file doesn't exist")
+	}
+
+	if err := sh.access(ctx, send); err != nil {
+		return err
+	}
+
+	token, err := sh.auth.EncodeSendDownload(send.Uuid, file.Id)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/api/sends/%s/%s?t=%s", sh.domain, send.Uuid, file.Id, token)
+
+	return c.JSON(http.StatusOK, response.NewSendFileDownload(file.Id, url))
+}
+
+func (sh *SendHandler) DownloadSendFile(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	sUuid, fileId := c.Param("uuid"), c.Param("file_id")
+
+	claims, err := sh.auth.DecodeSendDownload(c.QueryParam("t"), sUuid, fileId)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid download token").SetInternal(err)
+	}
+
+	// the send may have been disabled or deleted since the token was
+	// issued, the access was already counted then
+	send, err := sh.sends.FindByUuid(ctx, sUuid)
+	if errors.Is(err, model.ErrNotFound) || !sh.sendsAllowed {
+		return echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := send.Available(time.Now()); err != nil && !errors.Is(err, model.ErrSendMaxAccess) {
+		return echo.NewHTTPError(http.StatusNotFound, "Send not found")
+	}
+
+	ok, err := sh.sends.SpendToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Download token has already been used")
+	}
+
+	return serveBlob(c, sh.blobs, model.SendFileKey(sUuid, fileId))
+}
+
+// removeSendFile deletes the file of a file send. A failure only leaves an
+// orphaned file behind, so it is logged.
+func removeSendFile(ctx context.Context, blobs store.Blob, logger *zerolog.Logger, send *model.Send) {
+	if send.Atype != model.SendTypeFile {
+		return
+	}
+
+	file, err := send.File()
+	if err != nil {
+		logger.Warn().Err(err).Str("send", send.Uuid).Msg("failed to find send file")
+		return
+	}
+
+	key := model.SendFileKey(send.Uuid, file.Id)
+	if err := blobs.Delete(ctx, key); err != nil {
+		logger.Warn().Err(err).Str("key", key).Msg("failed to remove send file")
+	}
+}
+
+// errSizeMismatch is returned by exactReader when the data doesn't have the
+// expected size.
+var errSizeMismatch = errors.New("size mismatch")
+
+// exactReader fails unless r holds exactly left more bytes.
+type exactReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (er *exactReader) Read(p []byte) (int, error) {
+	if er.left <= 0 {
+		var b [1]byte
+		n, err := er.r.Read(b[:])
+		if n > 0 {
+			return 0, errSizeMismatch
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > er.left {
+		p = p[:er.left]
+	}
+
+	n, err := er.r.Read(p)
+	er.left -= int64(n)
+
+	if errors.Is(err, io.EOF) && er.left > 0 {
+		return n, errSizeMismatch
+	}
+
+	return n, err
+}
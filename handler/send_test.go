@@ -0,0 +1,388 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togls/gowarden/handler/response"
+	"github.com/togls/gowarden/model"
+)
+
+// textSend is the body of a text send as the clients post it.
+func textSend(fields map[string]any) map[string]any {
+	send := map[string]any{
+		"Type":         model.SendTypeText,
+		"Key":          "key",
+		"Name":         "send",
+		"Text":         map[string]any{"Text": "secret text", "Hidden": false},
+		"DeletionDate": time.Now().AddDate(0, 0, 7),
+	}
+	for k, v := range fields {
+		send[k] = v
+	}
+
+	return send
+}
+
+// addSend stores a text send of owner changed by edit.
+func (ts *testServer) addSend(owner *model.User, edit func(*model.Send)) *model.Send {
+	send := &model.Send{
+		Uuid:         newTestUuid(ts.t),
+		UserUuid:     &owner.Uuid,
+		Name:         "send",
+		Atype:        model.SendTypeText,
+		Data:         []byte(`{"Text":"secret text"}`),
+		Akey:         "key",
+		DeletionDate: time.Now().AddDate(0, 0, 7),
+	}
+	if edit != nil {
+		edit(send)
+	}
+	ts.db.sends[send.Uuid] = send
+
+	return send
+}
+
+func TestTextSend(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	_, otherToken := ts.addUser("other@example.com")
+
+	rec := ts.do(http.MethodPost, "/api/sends", token, textSend(map[string]any{
+		"Password":       "hunter2",
+		"MaxAccessCount": 2,
+	}))
+	ts.expect(rec, http.StatusOK)
+
+	var send response.Send
+	decodeJSON(t, rec, &send)
+
+	if send.Password == nil {
+		t.Fatal("created send has no password")
+	}
+
+	stored := ts.db.sends[send.Id]
+	if stored == nil || stored.UserUuid == nil || *stored.UserUuid != owner.Uuid {
+		t.Fatalf("stored send = %+v", stored)
+	}
+
+	var list struct{ Data []*response.Send }
+	rec = ts.do(http.MethodGet, "/api/sends", token, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &list)
+	if len(list.Data) != 1 || list.Data[0].Id != send.Id {
+		t.Fatalf("sends = %+v, want the created send", list.Data)
+	}
+
+	ts.expect(ts.do(http.MethodGet, "/api/sends/"+send.Id, token, nil), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, "/api/sends/"+send.Id, otherToken, nil), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodDelete, "/api/sends/"+send.Id, otherToken, nil), http.StatusNotFound)
+
+	rec = ts.do(http.MethodPut, "/api/sends/"+send.Id, token, textSend(map[string]any{
+		"Name":           "renamed",
+		"MaxAccessCount": 2,
+	}))
+	ts.expect(rec, http.StatusOK)
+
+	if ts.db.sends[send.Id].Name != "renamed" {
+		t.Errorf("name = %s, want renamed", ts.db.sends[send.Id].Name)
+	}
+	// a missing password keeps the current one
+	if !ts.db.sends[send.Id].HasPassword() {
+		t.Error("editing the send removed its password")
+	}
+
+	ts.expect(ts.do(http.MethodPut, "/api/sends/"+send.Id, token, textSend(map[string]any{
+		"Type": model.SendTypeFile,
+		"File": map[string]any{"FileName": "file"},
+	})), http.StatusBadRequest)
+
+	path := "/api/sends/access/" + send.AccessId
+
+	ts.expect(ts.do(http.MethodPost, path, "", map[string]any{}), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodPost, path, "", map[string]any{"Password": "wrong"}), http.StatusBadRequest)
+
+	rec = ts.do(http.MethodPost, path, "", map[string]any{"Password": "hunter2"})
+	ts.expect(rec, http.StatusOK)
+
+	var access response.SendAccess
+	decodeJSON(t, rec, &access)
+
+	if access.Id != send.Id || string(access.Text) != `{"Hidden":false,"Text":"secret text"}` {
+		t.Errorf("access = %+v, text %s", access, access.Text)
+	}
+	if access.CreatorIdentifier == nil || *access.CreatorIdentifier != owner.Email {
+		t.Errorf("creator = %v, want %s", access.CreatorIdentifier, owner.Email)
+	}
+
+	rec = ts.do(http.MethodPut, "/api/sends/"+send.Id+"/remove-password", token, nil)
+	ts.expect(rec, http.StatusOK)
+
+	ts.expect(ts.do(http.MethodPost, path, "", map[string]any{}), http.StatusOK)
+
+	if got := ts.db.sends[send.Id].AccessCount; got != 2 {
+		t.Errorf("access count = %d, want 2", got)
+	}
+
+	// the max access count is reached
+	ts.expect(ts.do(http.MethodPost, path, "", map[string]any{}), http.StatusNotFound)
+
+	ts.expect(ts.do(http.MethodDelete, "/api/sends/"+send.Id, token, nil), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, "/api/sends/"+send.Id, token, nil), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, path, "", map[string]any{}), http.StatusNotFound)
+}
+
+func TestPostSendInvalid(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, token := ts.addUser("owner@example.com")
+
+	tests := []struct {
+		name string
+		send map[string]any
+	}{
+		{"deleted too late", textSend(map[string]any{"DeletionDate": time.Now().AddDate(0, 0, model.SendMaxDeletionDays+1)})},
+		{"no text", textSend(map[string]any{"Text": map[string]any{}})},
+		{"file send", textSend(map[string]any{"Type": model.SendTypeFile, "File": map[string]any{"FileName": "file"}})},
+		{"unknown type", textSend(map[string]any{"Type": 7})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.expect(ts.do(http.MethodPost, "/api/sends", token, tt.send), http.StatusBadRequest)
+		})
+	}
+
+	if len(ts.db.sends) != 0 {
+		t.Errorf("%d sends stored, want none", len(ts.db.sends))
+	}
+}
+
+func TestSendAccessUnavailable(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, _ := ts.addUser("owner@example.com")
+
+	past := time.Now().Add(-time.Minute)
+	max := 1
+
+	tests := []struct {
+		name string
+		edit func(*model.Send)
+	}{
+		{"expired", func(s *model.Send) { s.ExpirationDate = &past }},
+		{"deletion date passed", func(s *model.Send) { s.DeletionDate = past }},
+		{"disabled", func(s *model.Send) { s.Disabled = true }},
+		{"max access count", func(s *model.Send) { s.MaxAccessCount, s.AccessCount = &max, 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := ts.addSend(owner, tt.edit)
+
+			id, err := send.AccessId()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ts.expect(ts.do(http.MethodPost, "/api/sends/access/"+id, "", map[string]any{}), http.StatusNotFound)
+		})
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends/access/not-an-id", "", map[string]any{}), http.StatusNotFound)
+}
+
+func TestSendsNotAllowed(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, token := ts.addUser("owner@example.com")
+	send := ts.addSend(owner, nil)
+
+	ts.sh.sendsAllowed = false
+	ts.routes()
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends", token, textSend(nil)), http.StatusBadRequest)
+
+	id, err := send.AccessId()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends/access/"+id, "", map[string]any{}), http.StatusNotFound)
+}
+
+// fileSend is the body of a file send reservation for a file of size bytes.
+func fileSend(size int64) map[string]any {
+	return textSend(map[string]any{
+		"Type":       model.SendTypeFile,
+		"Text":       nil,
+		"File":       map[string]any{"FileName": "file.txt"},
+		"FileLength": size,
+	})
+}
+
+func TestFileSend(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, token := ts.addUser("owner@example.com")
+	_, otherToken := ts.addUser("other@example.com")
+
+	data := []byte("file data")
+
+	rec := ts.do(http.MethodPost, "/api/sends/file/v2", token, fileSend(int64(len(data))))
+	ts.expect(rec, http.StatusOK)
+
+	var upload response.SendFileUpload
+	decodeJSON(t, rec, &upload)
+
+	send := upload.SendResponse
+
+	var file struct{ Id, Size, FileName string }
+	if err := json.Unmarshal(send.File, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Id == "" || file.Size != "9" || file.FileName != "file.txt" {
+		t.Fatalf("file = %+v", file)
+	}
+	if want := "/sends/" + send.Id + "/file/" + file.Id; upload.Url != want {
+		t.Errorf("upload url = %s, want %s", upload.Url, want)
+	}
+
+	uploadPath := "/api" + upload.Url
+
+	ts.expect(ts.upload(uploadPath, otherToken, nil, data), http.StatusNotFound)
+	ts.expect(ts.upload("/api/sends/"+send.Id+"/file/other", token, nil, data), http.StatusNotFound)
+	ts.expect(ts.upload(uploadPath, token, nil, data[1:]), http.StatusBadRequest)
+	ts.expect(ts.upload(uploadPath, token, nil, append(data, '!')), http.StatusBadRequest)
+	ts.expect(ts.upload(uploadPath, token, nil, data), http.StatusOK)
+
+	accessPath := "/api/sends/" + send.Id + "/access/file/" + file.Id
+
+	rec = ts.do(http.MethodPost, accessPath, "", map[string]any{})
+	ts.expect(rec, http.StatusOK)
+
+	var download response.SendFileDownload
+	decodeJSON(t, rec, &download)
+
+	if got := ts.db.sends[send.Id].AccessCount; got != 1 {
+		t.Errorf("access count = %d, want 1", got)
+	}
+
+	downloadPath := strings.TrimPrefix(download.Url, ts.cfg.Domain)
+
+	rec = ts.do(http.MethodGet, downloadPath, "", nil)
+	ts.expect(rec, http.StatusOK)
+	if rec.Body.String() != string(data) {
+		t.Errorf("downloaded %q, want %q", rec.Body.String(), data)
+	}
+
+	// the download urls work once
+	ts.expect(ts.do(http.MethodGet, downloadPath, "", nil), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodGet, "/api/sends/"+send.Id+"/"+file.Id+"?t=invalid", "", nil), http.StatusUnauthorized)
+
+	ts.expect(ts.do(http.MethodDelete, "/api/sends/"+send.Id, token, nil), http.StatusOK)
+
+	key := model.SendFileKey(send.Id, file.Id)
+	if _, err := ts.blobs.Size(context.Background(), key); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("send file = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileSendDisabledAfterAccess(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, token := ts.addUser("owner@example.com")
+
+	rec := ts.do(http.MethodPost, "/api/sends/file/v2", token, fileSend(1))
+	ts.expect(rec, http.StatusOK)
+
+	var upload response.SendFileUpload
+	decodeJSON(t, rec, &upload)
+	ts.expect(ts.upload("/api"+upload.Url, token, nil, []byte("x")), http.StatusOK)
+
+	sUuid := upload.SendResponse.Id
+	fileId := upload.Url[strings.LastIndex(upload.Url, "/")+1:]
+
+	rec = ts.do(http.MethodPost, "/api/sends/"+sUuid+"/access/file/"+fileId, "", map[string]any{})
+	ts.expect(rec, http.StatusOK)
+
+	var download response.SendFileDownload
+	decodeJSON(t, rec, &download)
+
+	ts.db.sends[sUuid].Disabled = true
+
+	ts.expect(ts.do(http.MethodGet, strings.TrimPrefix(download.Url, ts.cfg.Domain), "", nil), http.StatusNotFound)
+}
+
+func TestPostSendFileV2Invalid(t *testing.T) {
+	ts := newTestServer(t)
+
+	_, token := ts.addUser("owner@example.com")
+
+	ts.sh.sendSizeLimit = 10
+	ts.routes()
+
+	noLength := fileSend(0)
+	delete(noLength, "FileLength")
+
+	tests := []struct {
+		name string
+		send map[string]any
+	}{
+		{"too large", fileSend(11)},
+		{"negative size", fileSend(-1)},
+		{"no length", noLength},
+		{"text send", textSend(map[string]any{"FileLength": 1})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts.expect(ts.do(http.MethodPost, "/api/sends/file/v2", token, tt.send), http.StatusBadRequest)
+		})
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends/file/v2", token, fileSend(10)), http.StatusOK)
+}
+
+func TestSendPolicies(t *testing.T) {
+	ts := newTestServer(t)
+
+	owner, ownerToken := ts.addUser("owner@example.com")
+	member, memberToken := ts.addUser("member@example.com")
+
+	org := ts.addOrg(model.OrgLimits{})
+	ts.addMember(org, owner, model.UOTypeOwner)
+	ts.addMember(org, member, model.UOTypeUser)
+
+	options := ts.addPolicy(org, model.OPTypeSendOptions, `{"disableHideEmail": true}`)
+
+	hidden := textSend(map[string]any{"HideEmail": true})
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends", memberToken, hidden), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodPost, "/api/sends", memberToken, textSend(nil)), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/api/sends", ownerToken, hidden), http.StatusOK)
+
+	options.Enabled = false
+	ts.expect(ts.do(http.MethodPost, "/api/sends", memberToken, hidden), http.StatusOK)
+
+	ts.addPolicy(org, model.OPTypeDisableSend, "")
+
+	var list struct{ Data []*response.Send }
+	rec := ts.do(http.MethodGet, "/api/sends", memberToken, nil)
+	ts.expect(rec, http.StatusOK)
+	decodeJSON(t, rec, &list)
+
+	ts.expect(ts.do(http.MethodPost, "/api/sends", memberToken, textSend(nil)), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodPost, "/api/sends/file/v2", memberToken, fileSend(1)), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodPut, "/api/sends/"+list.Data[0].Id, memberToken, textSend(nil)), http.StatusBadRequest)
+
+	// existing sends can still be deleted
+	ts.expect(ts.do(http.MethodDelete, "/api/sends/"+list.Data[0].Id, memberToken, nil), http.StatusOK)
+}
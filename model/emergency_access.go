@@ -1,29 +1,65 @@
-package model
-
-import (
-	"database/sql"
-	"time"
-)
-
-type EmergencyAccess struct {
-	Uuid         string `json:"Id"`
-	Status       int    `json:"Status"`
-	Atype        int    `json:"Type"`
-	WaitTimeDays int    `json:"WaitTimeDays"`
-
-	GrantorUuid *string `json:"-"`
-	GranteeUuid *string `json:"-"`
-	Email       *string `json:"-"`
-	Name        string  `json:"-"`
-
-	KeyEncrypted        *string      `json:"-"`
-	RecoveryInitiatedAt sql.NullTime `json:"-"`
-	LastNotificationAt  sql.NullTime `json:"-"`
-	UpdatedAt           time.Time    `json:"-"`
-	CreatedAt           time.Time    `json:"-"`
-}
-
-type EAFilter struct {
-	GrantorUuid *string
-	GranteeUuid *string
-}
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type EmergencyAccess struct {
+	Uuid         string   `json:"Id"`
+	Status       EAStatus `json:"Status"`
+	Atype        EAType   `json:"Type"`
+	WaitTimeDays int      `json:"WaitTimeDays"`
+
+	GrantorUuid *string `json:"-"`
+	GranteeUuid *string `json:"-"`
+	Email       *string `json:"-"`
+	Name        string  `json:"-"`
+
+	KeyEncrypted        *string      `json:"-"`
+	RecoveryInitiatedAt sql.NullTime `json:"-"`
+	LastNotificationAt  sql.NullTime `json:"-"`
+	UpdatedAt           time.Time    `json:"-"`
+	CreatedAt           time.Time    `json:"-"`
+}
+
+// RecoveryDue reports whether the grantor let the wait time of an initiated
+// recovery pass without rejecting it.
+func (ea *EmergencyAccess) RecoveryDue(now time.Time) bool {
+	if ea.Status != EAStatusRecoveryInitiated || !ea.RecoveryInitiatedAt.Valid {
+		return false
+	}
+
+	return !now.Before(ea.RecoveryInitiatedAt.Time.AddDate(0, 0, ea.WaitTimeDays))
+}
+
+type EAFilter struct {
+	GrantorUuid *string
+	GranteeUuid *string
+	Email       *string
+	Status      *EAStatus
+}
+
+// Invited = 0
+// Accepted = 1
+// Confirmed = 2
+// RecoveryInitiated = 3
+// RecoveryApproved = 4
+type EAStatus int
+
+const (
+	EAStatusInvited EAStatus = iota
+	EAStatusAccepted
+	EAStatusConfirmed
+	EAStatusRecoveryInitiated
+	EAStatusRecoveryApproved
+)
+
+// View = 0
+// Takeover = 1
+type EAType int
+
+const (
+	EATypeView EAType = iota
+	EATypeTakeover
+)
@@ -0,0 +1,94 @@
+package model
+
+import "time"
+
+// Event is an entry of the organization event log.
+type Event struct {
+	Uuid           string
+	Atype          EventType
+	UserUuid       *string
+	OrgUuid        *string
+	CipherUuid     *string
+	CollectionUuid *string
+	GroupUuid      *string
+	OrgUserUuid    *string
+	ActUserUuid    *string
+	DeviceType     *int
+	IpAddress      *string
+	EventDate      time.Time
+	PolicyUuid     *string
+}
+
+type EventType int
+
+// The values are the ones of the Bitwarden clients.
+const (
+	// User
+	EventUserLoggedIn      EventType = 1000
+	EventUserFailedLogIn   EventType = 1005
+	EventUserFailedLogIn2f EventType = 1006
+
+	// Cipher
+	EventCipherCreated            EventType = 1100
+	EventCipherUpdated            EventType = 1101
+	EventCipherDeleted            EventType = 1102
+	EventCipherAttachmentCreated  EventType = 1103
+	EventCipherAttachmentDeleted  EventType = 1104
+	EventCipherShared             EventType = 1105
+	EventCipherUpdatedCollections EventType = 1106
+	EventCipherSoftDeleted        EventType = 1115
+	EventCipherRestored           EventType = 1116
+
+	// Collection
+	EventCollectionCreated EventType = 1300
+	EventCollectionUpdated EventType = 1301
+	EventCollectionDeleted EventType = 1302
+
+	// Group
+	EventGroupCreated EventType = 1400
+	EventGroupUpdated EventType = 1401
+	EventGroupDeleted EventType = 1402
+
+	// Organization user
+	EventOrgUserInvited       EventType = 1500
+	EventOrgUserConfirmed     EventType = 1501
+	EventOrgUserUpdated       EventType = 1502
+	EventOrgUserRemoved       EventType = 1503
+	EventOrgUserUpdatedGroups EventType = 1504
+
+	EventOrgUserResetPasswordEnroll   EventType = 1506
+	EventOrgUserResetPasswordWithdraw EventType = 1507
+	EventOrgUserAdminResetPassword    EventType = 1508
+	EventOrgUserRevoked               EventType = 1511
+	EventOrgUserRestored              EventType = 1512
+
+	// Organization
+	EventOrgUpdated EventType = 1600
+
+	// Policy
+	EventPolicyUpdated EventType = 1700
+)
+
+// EventFilter selects the events between Start and End, newest first.
+type EventFilter struct {
+	OrgUuid    *string
+	CipherUuid *string
+
+	// MemberUuid keeps the events about or by the user
+	MemberUuid *string
+
+	Start time.Time
+	End   time.Time
+	Limit uint64
+
+	// Cursor, when set, replaces End: the page resumes right after the
+	// last event of the previous one.
+	Cursor *EventCursor
+}
+
+// EventCursor marks an event in the event log, events of the same second
+// are told apart by their uuid.
+type EventCursor struct {
+	Date time.Time
+	Uuid string
+}
@@ -1,6 +1,6 @@
-package model
-
-type Favorite struct {
-	UserUuid   string
-	CipherUuid string
-}
+package model
+
+type Favorite struct {
+	UserUuid   string
+	CipherUuid string
+}
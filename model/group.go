@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Group gives its members access to collections of the organization, next
+// to the access granted to each member directly.
+type Group struct {
+	Uuid       string
+	OrgUuid    string
+	Name       string
+	AccessAll  bool
+	ExternalId *string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type CollectionGroup struct {
+	CollectionUuid string
+	GroupUuid      string
+	ReadOnly       bool
+	HidePasswords  bool
+}
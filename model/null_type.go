@@ -1 +1 @@
-package model
+package model
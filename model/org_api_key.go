@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// OrgApiKey authenticates services acting on behalf of an organization.
+type OrgApiKey struct {
+	Uuid         string
+	OrgUuid      string
+	Atype        OrgApiKeyType
+	ApiKey       string
+	RevisionDate time.Time
+}
+
+// Default = 0
+// BillingSync = 1
+// Scim = 2
+type OrgApiKeyType int
+
+const (
+	OrgApiKeyTypeDefault OrgApiKeyType = iota
+	OrgApiKeyTypeBillingSync
+	OrgApiKeyTypeScim
+)
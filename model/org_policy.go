@@ -1,22 +1,225 @@
-package model
-
-type OrgPolicy struct {
-	Uuid    string
-	OrgUuid string
-	Atype   OrgPolicyType
-	Enabled bool
-	Data    []byte
-}
-
-type OrgPolicyType int
-
-const (
-	OPTypeTwoFactor OrgPolicyType = 0 + iota
-	OPTypeMasterPassword
-	OPTypePasswordGenerator
-	OPTypeSingleOrg
-	OPTypeRequireSso
-	OPTypePersonalOwnership
-	OPTypeDisableSend
-	OPTypeSendOptions
-)
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type OrgPolicy struct {
+	Uuid    string
+	OrgUuid string
+	Atype   OrgPolicyType
+	Enabled bool
+	Data    []byte
+}
+
+type OrgPolicyType int
+
+const (
+	OPTypeTwoFactor OrgPolicyType = 0 + iota
+	OPTypeMasterPassword
+	OPTypePasswordGenerator
+	OPTypeSingleOrg
+	OPTypeRequireSso
+	OPTypePersonalOwnership
+	OPTypeDisableSend
+	OPTypeSendOptions
+	OPTypeResetPassword
+)
+
+var ErrInvalidPolicyType = errors.New("invalid policy type")
+
+func (t OrgPolicyType) Valid() bool {
+	return t >= OPTypeTwoFactor && t <= OPTypeResetPassword
+}
+
+// MasterPasswordPolicy is the Data of an OPTypeMasterPassword policy.
+type MasterPasswordPolicy struct {
+	MinComplexity  *int  `json:"minComplexity"`
+	MinLength      *int  `json:"minLength"`
+	RequireUpper   bool  `json:"requireUpper"`
+	RequireLower   bool  `json:"requireLower"`
+	RequireNumbers bool  `json:"requireNumbers"`
+	RequireSpecial bool  `json:"requireSpecial"`
+	EnforceOnLogin *bool `json:"enforceOnLogin,omitempty"`
+}
+
+// Merge tightens p with the requirements of o, so a user in several
+// organizations meets all of them.
+func (p *MasterPasswordPolicy) Merge(o *MasterPasswordPolicy) {
+	p.MinComplexity = maxInt(p.MinComplexity, o.MinComplexity)
+	p.MinLength = maxInt(p.MinLength, o.MinLength)
+	p.RequireUpper = p.RequireUpper || o.RequireUpper
+	p.RequireLower = p.RequireLower || o.RequireLower
+	p.RequireNumbers = p.RequireNumbers || o.RequireNumbers
+	p.RequireSpecial = p.RequireSpecial || o.RequireSpecial
+
+	if o.EnforceOnLogin != nil && *o.EnforceOnLogin {
+		p.EnforceOnLogin = o.EnforceOnLogin
+	}
+}
+
+func maxInt(a, b *int) *int {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+
+	return a
+}
+
+func (p *MasterPasswordPolicy) validate() error {
+	if p.MinComplexity != nil && (*p.MinComplexity < 0 || *p.MinComplexity > 4) {
+		return errors.New("minComplexity must be between 0 and 4")
+	}
+
+	if p.MinLength != nil && (*p.MinLength < 0 || *p.MinLength > 128) {
+		return errors.New("minLength must be between 0 and 128")
+	}
+
+	return nil
+}
+
+// PasswordGeneratorPolicy is the Data of an OPTypePasswordGenerator policy.
+type PasswordGeneratorPolicy struct {
+	DefaultType    *string `json:"defaultType"`
+	MinLength      *int    `json:"minLength"`
+	UseUpper       bool    `json:"useUpper"`
+	UseLower       bool    `json:"useLower"`
+	UseNumbers     bool    `json:"useNumbers"`
+	UseSpecial     bool    `json:"useSpecial"`
+	MinNumbers     *int    `json:"minNumbers"`
+	MinSpecial     *int    `json:"minSpecial"`
+	MinNumberWords *int    `json:"minNumberWords"`
+	Capitalize     bool    `json:"capitalize"`
+	IncludeNumber  bool    `json:"includeNumber"`
+}
+
+func (p *PasswordGeneratorPolicy) validate() error {
+	if p.DefaultType != nil && *p.DefaultType != "" &&
+		*p.DefaultType != "password" && *p.DefaultType != "passphrase" {
+		return errors.New("defaultType must be password or passphrase")
+	}
+
+	if p.MinLength != nil && (*p.MinLength < 5 || *p.MinLength > 128) {
+		return errors.New("minLength must be between 5 and 128")
+	}
+
+	if p.MinNumbers != nil && (*p.MinNumbers < 0 || *p.MinNumbers > 9) {
+		return errors.New("minNumbers must be between 0 and 9")
+	}
+
+	if p.MinSpecial != nil && (*p.MinSpecial < 0 || *p.MinSpecial > 9) {
+		return errors.New("minSpecial must be between 0 and 9")
+	}
+
+	if p.MinNumberWords != nil && (*p.MinNumberWords < 3 || *p.MinNumberWords > 20) {
+		return errors.New("minNumberWords must be between 3 and 20")
+	}
+
+	return nil
+}
+
+// SendOptionsPolicy is the Data of an OPTypeSendOptions policy.
+type SendOptionsPolicy struct {
+	DisableHideEmail bool `json:"disableHideEmail"`
+}
+
+func (p *SendOptionsPolicy) validate() error {
+	return nil
+}
+
+// ResetPasswordPolicy is the Data of an OPTypeResetPassword policy.
+type ResetPasswordPolicy struct {
+	AutoEnrollEnabled bool `json:"autoEnrollEnabled"`
+}
+
+func (p *ResetPasswordPolicy) validate() error {
+	return nil
+}
+
+type policyData interface {
+	validate() error
+}
+
+// newData returns the typed Data of the policy type, nil for the types that
+// are only switched on or off.
+func (t OrgPolicyType) newData() policyData {
+	switch t {
+	case OPTypeMasterPassword:
+		return new(MasterPasswordPolicy)
+	case OPTypePasswordGenerator:
+		return new(PasswordGeneratorPolicy)
+	case OPTypeSendOptions:
+		return new(SendOptionsPolicy)
+	case OPTypeResetPassword:
+		return new(ResetPasswordPolicy)
+	default:
+		return nil
+	}
+}
+
+// ParseData parses and validates the Data sent for a policy of type t. It
+// returns the json to store, which is the client's own so the fields this
+// server doesn't know about survive.
+func (t OrgPolicyType) ParseData(data []byte) ([]byte, error) {
+	if !t.Valid() {
+		return nil, ErrInvalidPolicyType
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return []byte("null"), nil
+	}
+
+	if v := t.newData(); v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, fmt.Errorf("invalid policy data: %w", err)
+		}
+
+		if err := v.validate(); err != nil {
+			return nil, fmt.Errorf("invalid policy data: %w", err)
+		}
+	}
+
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, data); err != nil {
+		return nil, fmt.Errorf("invalid policy data: %w", err)
+	}
+
+	return compact.Bytes(), nil
+}
+
+// unmarshalData decodes the stored Data into v, expecting policy type t.
+func (op *OrgPolicy) unmarshalData(t OrgPolicyType, v any) error {
+	if op.Atype != t {
+		return ErrInvalidPolicyType
+	}
+
+	if len(op.Data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(op.Data, v)
+}
+
+func (op *OrgPolicy) MasterPassword() (*MasterPasswordPolicy, error) {
+	p := new(MasterPasswordPolicy)
+	return p, op.unmarshalData(OPTypeMasterPassword, p)
+}
+
+func (op *OrgPolicy) PasswordGenerator() (*PasswordGeneratorPolicy, error) {
+	p := new(PasswordGeneratorPolicy)
+	return p, op.unmarshalData(OPTypePasswordGenerator, p)
+}
+
+func (op *OrgPolicy) SendOptions() (*SendOptionsPolicy, error) {
+	p := new(SendOptionsPolicy)
+	return p, op.unmarshalData(OPTypeSendOptions, p)
+}
+
+func (op *OrgPolicy) ResetPassword() (*ResetPasswordPolicy, error) {
+	p := new(ResetPasswordPolicy)
+	return p, op.unmarshalData(OPTypeResetPassword, p)
+}
@@ -1,86 +1,116 @@
-package model
-
-import (
-	"encoding/json"
-)
-
-type Organization struct {
-	Uuid         string
-	Name         string
-	BillingEmail string
-	PrivateKey   *string
-	PublicKey    *string
-}
-
-func (o Organization) MarshalJSON() ([]byte, error) {
-	keys := false
-	if o.PrivateKey != nil && o.PublicKey != nil {
-		keys = true
-	}
-
-	data := &struct {
-		ID                      string `json:"Id"`
-		Name                    string `json:"Name"`
-		HasPublicAndPrivateKeys bool   `json:"HasPublicAndPrivateKeys"`
-		BillingEmail            string `json:"BillingEmail"`
-
-		Identifier     any  `json:"Identifier"`
-		Seats          int  `json:"Seats"`
-		MaxCollections int  `json:"MaxCollections"`
-		MaxStorageGb   int  `json:"MaxStorageGb"`
-		Use2fa         bool `json:"Use2fa"`
-		UseDirectory   bool `json:"UseDirectory"`
-		UseEvents      bool `json:"UseEvents"`
-		UseGroups      bool `json:"UseGroups"`
-		UseTotp        bool `json:"UseTotp"`
-		UsePolicies    bool `json:"UsePolicies"`
-		UseSso         bool `json:"UseSso"`
-		SelfHost       bool `json:"SelfHost"`
-		UseApi         bool `json:"UseApi"`
-
-		BusinessName      any `json:"BusinessName"`
-		BusinessAddress1  any `json:"BusinessAddress1"`
-		BusinessAddress2  any `json:"BusinessAddress2"`
-		BusinessAddress3  any `json:"BusinessAddress3"`
-		BusinessCountry   any `json:"BusinessCountry"`
-		BusinessTaxNumber any `json:"BusinessTaxNumber"`
-
-		Plan            string `json:"Plan"`
-		PlanType        int    `json:"PlanType"`
-		UsersGetPremium bool   `json:"UsersGetPremium"`
-		Object          string `json:"Object"`
-	}{
-		ID:                      o.Uuid,
-		Name:                    o.Name,
-		HasPublicAndPrivateKeys: keys,
-		BillingEmail:            o.BillingEmail,
-
-		Identifier:     nil,
-		Seats:          10,
-		MaxCollections: 10,
-		MaxStorageGb:   10,
-		Use2fa:         true,
-		UseDirectory:   false,
-		UseEvents:      false,
-		UseGroups:      false,
-		UseTotp:        true,
-		UsePolicies:    true,
-		UseSso:         false,
-		SelfHost:       true,
-		UseApi:         false,
-
-		BusinessName:      nil,
-		BusinessAddress1:  nil,
-		BusinessAddress2:  nil,
-		BusinessAddress3:  nil,
-		BusinessCountry:   nil,
-		BusinessTaxNumber: nil,
-
-		Plan:            "TeamsAnnually",
-		PlanType:        5,
-		UsersGetPremium: true,
-		Object:          "organization",
-	}
-
-	return json.Marshal(data)
-}
+package model
+
+import (
+	"encoding/json"
+)
+
+type Organization struct {
+	Uuid         string
+	Name         string
+	BillingEmail string
+	PrivateKey   *string
+	PublicKey    *string
+
+	Limits OrgLimits
+
+	Storage Storage // attachment usage, filled by the handler
+}
+
+// OrgLimits are the limits the server admin sets on an organization, a nil
+// limit is unlimited.
+type OrgLimits struct {
+	MaxSeats       *int
+	MaxCollections *int
+	MaxStorageGb   *int
+}
+
+// StorageLimit returns the attachment limit in bytes, the server wide
+// fallback applies when the organization has none.
+func (l OrgLimits) StorageLimit(fallback int64) int64 {
+	if l.MaxStorageGb == nil {
+		return fallback
+	}
+
+	return int64(*l.MaxStorageGb) * gigabyte
+}
+
+func (o Organization) MarshalJSON() ([]byte, error) {
+	keys := false
+	if o.PrivateKey != nil && o.PublicKey != nil {
+		keys = true
+	}
+
+	data := &struct {
+		ID                      string `json:"Id"`
+		Name                    string `json:"Name"`
+		HasPublicAndPrivateKeys bool   `json:"HasPublicAndPrivateKeys"`
+		BillingEmail            string `json:"BillingEmail"`
+
+		Identifier       any     `json:"Identifier"`
+		Seats            *int    `json:"Seats"`
+		MaxCollections   *int    `json:"MaxCollections"`
+		MaxStorageGb     int     `json:"MaxStorageGb"`
+		StorageGb        float64 `json:"StorageGb"`
+		StorageName      string  `json:"StorageName"`
+		Use2fa           bool    `json:"Use2fa"`
+		UseDirectory     bool    `json:"UseDirectory"`
+		UseEvents        bool    `json:"UseEvents"`
+		UseGroups        bool    `json:"UseGroups"`
+		UseTotp          bool    `json:"UseTotp"`
+		UsePolicies      bool    `json:"UsePolicies"`
+		UseResetPassword bool    `json:"UseResetPassword"`
+		UseScim          bool    `json:"UseScim"`
+		UseSso           bool    `json:"UseSso"`
+		SelfHost         bool    `json:"SelfHost"`
+		UseApi           bool    `json:"UseApi"`
+
+		BusinessName      any `json:"BusinessName"`
+		BusinessAddress1  any `json:"BusinessAddress1"`
+		BusinessAddress2  any `json:"BusinessAddress2"`
+		BusinessAddress3  any `json:"BusinessAddress3"`
+		BusinessCountry   any `json:"BusinessCountry"`
+		BusinessTaxNumber any `json:"BusinessTaxNumber"`
+
+		Plan            string `json:"Plan"`
+		PlanType        int    `json:"PlanType"`
+		UsersGetPremium bool   `json:"UsersGetPremium"`
+		Object          string `json:"Object"`
+	}{
+		ID:                      o.Uuid,
+		Name:                    o.Name,
+		HasPublicAndPrivateKeys: keys,
+		BillingEmail:            o.BillingEmail,
+
+		Identifier:       nil,
+		Seats:            o.Limits.MaxSeats,
+		MaxCollections:   o.Limits.MaxCollections,
+		MaxStorageGb:     o.Storage.MaxStorageGb(),
+		StorageGb:        o.Storage.UsedGb(),
+		StorageName:      o.Storage.UsedName(),
+		Use2fa:           true,
+		UseDirectory:     true,
+		UseEvents:        true,
+		UseGroups:        true,
+		UseTotp:          true,
+		UsePolicies:      true,
+		UseResetPassword: true,
+		UseScim:          true,
+		UseSso:           false,
+		SelfHost:         true,
+		UseApi:           true,
+
+		BusinessName:      nil,
+		BusinessAddress1:  nil,
+		BusinessAddress2:  nil,
+		BusinessAddress3:  nil,
+		BusinessCountry:   nil,
+		BusinessTaxNumber: nil,
+
+		Plan:            "TeamsAnnually",
+		PlanType:        5,
+		UsersGetPremium: true,
+		Object:          "organization",
+	}
+
+	return json.Marshal(data)
+}
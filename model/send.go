@@ -1,82 +1,145 @@
-package model
-
-import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"time"
-)
-
-type Send struct {
-	Uuid             string
-	UserUuid         *string
-	OrganizationUuid *string
-
-	Name  string
-	Notes *string
-	Atype SendType
-	Data  []byte
-	Akey  string
-
-	PasswordHash *[]byte
-	PasswordSalt *[]byte
-	PasswordIter *int
-
-	MaxAccessCount *int
-	AccessCount    int
-
-	CreationDate   time.Time
-	RevisionDate   time.Time
-	ExpirationDate *time.Time
-	DeletionDate   time.Time
-
-	Disabled  bool
-	HideEmail *bool
-}
-
-func (item Send) MarshalJSON() ([]byte, error) {
-	type Alias Send
-	aux := &struct {
-		*Alias
-		AccessId string          `json:"AccessId"`
-		Text     json.RawMessage `json:"Text"`
-		File     json.RawMessage `json:"File"`
-		Object   string          `json:"Object"`
-	}{
-		Alias:  (*Alias)(&item),
-		Object: "send",
-	}
-
-	id, err := base64.RawURLEncoding.DecodeString(item.Uuid)
-	if err != nil {
-		return nil, fmt.Errorf("invalid send id: %s, MarshalJSON err: %w", item.Uuid, err)
-	}
-
-	aux.AccessId = string(id)
-
-	data, err := json.Marshal(item.Data)
-	if err != nil {
-		return nil, fmt.Errorf("Send MarshalJSON err: %w", err)
-	}
-
-	if item.Atype == SendTypeText {
-		aux.Text = data
-	} else if item.Atype == SendTypeFile {
-		aux.File = data
-	}
-
-	return json.Marshal(aux)
-}
-
-// Text = 0
-// File = 1
-type SendType int
-
-const (
-	SendTypeText SendType = iota
-	SendTypeFile
-)
-
-type SendFilter struct {
-	UserUuid *string
-}
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Send struct {
+	Uuid             string
+	UserUuid         *string
+	OrganizationUuid *string
+
+	Name  string
+	Notes *string
+	Atype SendType
+	Data  []byte
+	Akey  string
+
+	PasswordHash *[]byte
+	PasswordSalt *[]byte
+	PasswordIter *int
+
+	MaxAccessCount *int
+	AccessCount    int
+
+	CreationDate   time.Time
+	RevisionDate   time.Time
+	ExpirationDate *time.Time
+	DeletionDate   time.Time
+
+	Disabled  bool
+	HideEmail *bool
+}
+
+// AccessId is the url safe id anonymous users open the send with.
+func (s *Send) AccessId() (string, error) {
+	id, err := uuid.Parse(s.Uuid)
+	if err != nil {
+		return "", fmt.Errorf("invalid send id: %s, err: %w", s.Uuid, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(id[:]), nil
+}
+
+// SendUuidFromAccessId reverses Send.AccessId.
+func SendUuidFromAccessId(accessId string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(accessId)
+	if err != nil {
+		return "", fmt.Errorf("invalid send access id: %s, err: %w", accessId, err)
+	}
+
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return "", fmt.Errorf("invalid send access id: %s, err: %w", accessId, err)
+	}
+
+	return id.String(), nil
+}
+
+// SendFile is the part of the file send data the server relies on.
+type SendFile struct {
+	Id   string
+	Size int64
+}
+
+// File returns the file of a file send.
+func (s *Send) File() (*SendFile, error) {
+	if s.Atype != SendTypeFile {
+		return nil, errors.New("not a file send")
+	}
+
+	// the size is kept as a string for the clients
+	var data struct {
+		Id   string      `json:"Id"`
+		Size json.Number `json:"Size"`
+	}
+
+	if err := json.Unmarshal(s.Data, &data); err != nil {
+		return nil, fmt.Errorf("invalid send file data: %s, err: %w", s.Uuid, err)
+	}
+
+	size, err := data.Size.Int64()
+	if err != nil || data.Id == "" {
+		return nil, fmt.Errorf("invalid send file data: %s", s.Uuid)
+	}
+
+	return &SendFile{Id: data.Id, Size: size}, nil
+}
+
+// SendFileKey is where the file of a send is stored.
+func SendFileKey(send, file string) string {
+	return path.Join("sends", send, file)
+}
+
+func (s *Send) HasPassword() bool {
+	return s.PasswordHash != nil
+}
+
+// Available reports why the send can't be accessed anymore, nil if it can.
+// Expired sends are gone as far as their recipients are concerned, they
+// are ErrNotFound until the purge job deletes them.
+func (s *Send) Available(now time.Time) error {
+	switch {
+	case s.MaxAccessCount != nil && s.AccessCount >= *s.MaxAccessCount:
+		return ErrSendMaxAccess
+	case s.ExpirationDate != nil && !now.Before(*s.ExpirationDate),
+		!now.Before(s.DeletionDate):
+		return ErrNotFound
+	case s.Disabled:
+		return ErrSendDisabled
+	}
+
+	return nil
+}
+
+const (
+	ErrSendMaxAccess = storeErr("Max access count reached")
+	ErrSendDisabled  = storeErr("Send has been disabled")
+)
+
+// SendPasswordIter is the pbkdf2 iterations applied to send passwords.
+const SendPasswordIter = 100_000
+
+// SendMaxDeletionDays is how far in the future a send may be deleted.
+const SendMaxDeletionDays = 31
+
+// Text = 0
+// File = 1
+type SendType int
+
+const (
+	SendTypeText SendType = iota
+	SendTypeFile
+)
+
+type SendFilter struct {
+	UserUuid      *string
+	DeletedBefore *time.Time
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxStorageGbUnlimited is reported as MaxStorageGb when no limit is set.
+const MaxStorageGbUnlimited = math.MaxInt16
+
+const gigabyte = 1 << 30
+
+// Storage is the attachment usage of a user or an organization in bytes,
+// a zero Limit means unlimited.
+type Storage struct {
+	Used  int64
+	Limit int64
+}
+
+// Left returns the remaining bytes, ok is false when the storage is unlimited.
+func (s Storage) Left() (left int64, ok bool) {
+	if s.Limit <= 0 {
+		return 0, false
+	}
+
+	return s.Limit - s.Used, true
+}
+
+func (s Storage) MaxStorageGb() int {
+	if s.Limit <= 0 {
+		return MaxStorageGbUnlimited
+	}
+
+	gb := (s.Limit + gigabyte - 1) / gigabyte
+	if gb > MaxStorageGbUnlimited {
+		return MaxStorageGbUnlimited
+	}
+
+	return int(gb)
+}
+
+func (s Storage) UsedGb() float64 {
+	return math.Round(float64(s.Used)/gigabyte*100) / 100
+}
+
+func (s Storage) UsedName() string {
+	return ReadableSize(s.Used)
+}
+
+// ReadableSize formats a byte count the way Bitwarden displays sizes.
+func ReadableSize(size int64) string {
+	units := []string{"Bytes", "KB", "MB", "GB", "TB"}
+
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[i])
+	}
+
+	return fmt.Sprintf("%.2f %s", value, units[i])
+}
@@ -1,64 +1,76 @@
-package model
-
-type UserOrganization struct {
-	Uuid     string
-	UserUuid string
-	OrgUuid  string
-
-	AccessAll bool
-	AKey      *string
-
-	Status UOStatus
-	Atype  UOType
-
-	Name       string
-	PrivateKey *string
-	PublicKey  *string
-}
-
-type UOFilter struct {
-	UserUuid *string
-	OrgUuid  *string
-	Status   *UOStatus
-	Atype    *UOType
-}
-
-// Invited = 0
-// Accepted = 1
-// Confirmed = 2
-type UOStatus int
-
-const (
-	UOStatusInvited UOStatus = iota
-	UOStatusAccepted
-	UOStatusConfirmed
-)
-
-// Owner = 0
-// Admin = 1
-// User = 2
-// Manager = 3
-type UOType int
-
-const (
-	UOTypeOwner UOType = iota
-	UOTypeAdmin
-	UOTypeUser
-	UOTypeManager
-)
-
-type UODetail struct {
-	ID          string          `json:"Id"`
-	UserID      string          `json:"UserId"`
-	Status      int             `json:"Status"`
-	Type        int             `json:"Type"`
-	AccessAll   bool            `json:"AccessAll"`
-	Collections []*UOCollection `json:"Collections"`
-	Object      string          `json:"Object"`
-}
-
-type UOCollection struct {
-	ID            string `json:"Id"`
-	ReadOnly      bool   `json:"ReadOnly"`
-	HidePasswords bool   `json:"HidePasswords"`
-}
+package model
+
+type UserOrganization struct {
+	Uuid     string
+	UserUuid string
+	OrgUuid  string
+
+	AccessAll bool
+	AKey      *string
+
+	// ResetPasswordKey is the user key encrypted with the org public key,
+	// set once the member enrolled in account recovery.
+	ResetPasswordKey *string
+
+	// ExternalId is the id of the member in the synced directory.
+	ExternalId *string
+
+	Status UOStatus
+	Atype  UOType
+
+	Name       string
+	PrivateKey *string
+	PublicKey  *string
+	Limits     OrgLimits
+}
+
+type UOFilter struct {
+	UserUuid *string
+	OrgUuid  *string
+	Status   *UOStatus
+	Atype    *UOType
+}
+
+// Revoked = -1
+// Invited = 0
+// Accepted = 1
+// Confirmed = 2
+type UOStatus int
+
+const (
+	UOStatusRevoked UOStatus = iota - 1
+	UOStatusInvited
+	UOStatusAccepted
+	UOStatusConfirmed
+)
+
+// Owner = 0
+// Admin = 1
+// User = 2
+// Manager = 3
+type UOType int
+
+const (
+	UOTypeOwner UOType = iota
+	UOTypeAdmin
+	UOTypeUser
+	UOTypeManager
+)
+
+type UODetail struct {
+	ID          string          `json:"Id"`
+	UserID      string          `json:"UserId"`
+	Status      int             `json:"Status"`
+	Type        int             `json:"Type"`
+	AccessAll   bool            `json:"AccessAll"`
+	Collections []*UOCollection `json:"Collections"`
+
+	ResetPasswordEnrolled bool   `json:"ResetPasswordEnrolled"`
+	Object                string `json:"Object"`
+}
+
+type UOCollection struct {
+	ID            string `json:"Id"`
+	ReadOnly      bool   `json:"ReadOnly"`
+	HidePasswords bool   `json:"HidePasswords"`
+}
@@ -0,0 +1,60 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2022, time.August, 15, 10, 20, 30, 500, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * * *", time.Date(2022, time.August, 15, 10, 20, 31, 0, time.UTC)},
+		{"0 5 * * * *", time.Date(2022, time.August, 15, 11, 5, 0, 0, time.UTC)},
+		{"30 * * * * *", time.Date(2022, time.August, 15, 10, 21, 30, 0, time.UTC)},
+		{"0 5 0 * * *", time.Date(2022, time.August, 16, 0, 5, 0, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2022, time.August, 15, 10, 20, 45, 0, time.UTC)},
+		{"0 0 12 1 * ?", time.Date(2022, time.September, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 0 * * 0", time.Date(2022, time.August, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 0 * * 7", time.Date(2022, time.August, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 0 1 1 *", time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 9-17/4 * * 1-5", time.Date(2022, time.August, 15, 13, 0, 0, 0, time.UTC)},
+		{"0,10 20 10 * * *", time.Date(2022, time.August, 16, 10, 20, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2022, time.August, 15, 10, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * * *",
+		"* * 24 * * *",
+		"* * * 0 * *",
+		"* * * * 13 *",
+		"*/0 * * * * *",
+		"5-1 * * * * *",
+		"a * * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected an error", spec)
+		}
+	}
+}
@@ -1 +1 @@
-package serializer
+package serializer
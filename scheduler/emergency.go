@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+// EmergencyTimeout approves the recoveries whose grantor let the wait time
+// pass without rejecting them.
+type EmergencyTimeout struct {
+	eas store.EmergencyAccess
+
+	logger  *zerolog.Logger
+	allowed bool
+}
+
+func NewEmergencyTimeout(cfg *config.Core, eas store.EmergencyAccess) *EmergencyTimeout {
+	return &EmergencyTimeout{
+		eas: eas,
+
+		logger:  cfg.Logger,
+		allowed: cfg.EmergencyAccessAllowed,
+	}
+}
+
+func (et *EmergencyTimeout) Run(ctx context.Context) error {
+	if !et.allowed {
+		return nil
+	}
+
+	eas, err := findRecoveriesInitiated(ctx, et.eas)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	approved := 0
+	for _, ea := range eas {
+		if !ea.RecoveryDue(now) {
+			continue
+		}
+
+		ea.Status = model.EAStatusRecoveryApproved
+		if err := et.eas.Save(ctx, ea); err != nil {
+			return err
+		}
+		approved++
+
+		// TODO: notify grantor and grantee by email
+	}
+
+	if approved > 0 {
+		et.logger.Info().Int("emergency_access", approved).Msg("approved timed out recoveries")
+	}
+
+	return nil
+}
+
+func findRecoveriesInitiated(ctx context.Context, eas store.EmergencyAccess) ([]*model.EmergencyAccess, error) {
+	status := model.EAStatusRecoveryInitiated
+	return eas.Find(ctx, model.EAFilter{Status: &status})
+}
@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/wire"
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/pkg/cron"
+)
+
+var WireSet = wire.NewSet(
+	New,
+	NewTrashPurge,
+	NewSendPurge,
+	NewEmergencyTimeout,
+)
+
+// Func is the work of a job. Its context is cancelled on shutdown.
+type Func func(ctx context.Context) error
+
+type job struct {
+	name     string
+	spec     string
+	schedule *cron.Schedule
+	fn       Func
+
+	// guarded by Scheduler.mu
+	next    time.Time
+	running bool
+	status  Status
+}
+
+// Status is the state of a job as shown to admins.
+type Status struct {
+	Name         string     `json:"Name"`
+	Schedule     string     `json:"Schedule"`
+	Running      bool       `json:"Running"`
+	NextRun      time.Time  `json:"NextRun"`
+	LastRun      *time.Time `json:"LastRun"`
+	LastDuration string     `json:"LastDuration"`
+	LastError    *string    `json:"LastError"`
+}
+
+// Scheduler runs the jobs of config.Jobs on their cron schedules. Due jobs
+// are checked every poll interval, a job still running when it is due again
+// is skipped.
+type Scheduler struct {
+	logger *zerolog.Logger
+	poll   time.Duration
+
+	mu   sync.Mutex
+	jobs []*job
+	wg   sync.WaitGroup
+}
+
+func New(
+	cfg *config.Core,
+	trash *TrashPurge,
+	sends *SendPurge,
+	timeout *EmergencyTimeout,
+) (*Scheduler, error) {
+	s := &Scheduler{
+		logger: cfg.Logger,
+		poll:   time.Duration(cfg.PollInterval) * time.Millisecond,
+	}
+
+	if err := s.Add("trash_purge", cfg.TrashPurge, trash.Run); err != nil {
+		return nil, err
+	}
+
+	if err := s.Add("send_purge", cfg.SendPurge, sends.Run); err != nil {
+		return nil, err
+	}
+
+	if err := s.Add("emergency_request_timeout", cfg.EmergencyRequestTimeout, timeout.Run); err != nil {
+		return nil, err
+	}
+
+	// the emergency reminder and incomplete 2fa jobs only send mails, they
+	// are registered once a mailer exists
+
+	return s, nil
+}
+
+// Add registers a job, an empty spec disables it.
+func (s *Scheduler) Add(name, spec string, fn Func) error {
+	if spec == "" {
+		s.logger.Info().Str("job", name).Msg("job disabled")
+		return nil
+	}
+
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule of job %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		spec:     spec,
+		schedule: schedule,
+		fn:       fn,
+		next:     schedule.Next(time.Now()),
+	})
+
+	return nil
+}
+
+// Run starts due jobs until ctx is done, then waits for the running ones.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.wg.Wait()
+
+	if s.poll <= 0 {
+		s.logger.Info().Msg("job scheduler disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.next.IsZero() || now.Before(j.next) {
+			continue
+		}
+
+		j.next = j.schedule.Next(now)
+
+		if j.running {
+			s.logger.Warn().Str("job", j.name).Msg("job still running, skipped")
+			continue
+		}
+
+		j.running = true
+		s.wg.Add(1)
+
+		go s.run(ctx, j)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	defer s.wg.Done()
+
+	start := time.Now()
+
+	err := s.call(ctx, j)
+
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	j.running = false
+	j.status.LastRun = &start
+	j.status.LastDuration = duration.String()
+	j.status.LastError = nil
+	if err != nil {
+		msg := err.Error()
+		j.status.LastError = &msg
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.logger.Error().Err(err).Str("job", j.name).Dur("duration", duration).Msg("job failed")
+		return
+	}
+
+	s.logger.Info().Str("job", j.name).Dur("duration", duration).Msg("job finished")
+}
+
+// call runs the job, a panic fails the job instead of the server.
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+
+	return j.fn(ctx)
+}
+
+// Status returns the state of the registered jobs.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		st := j.status
+		st.Name = j.name
+		st.Schedule = j.spec
+		st.Running = j.running
+		st.NextRun = j.next
+		list = append(list, st)
+	}
+
+	return list
+}
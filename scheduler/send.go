@@ -0,0 +1,83 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+// SendPurge deletes the sends past their deletion date together with their
+// files, and the spent download tokens once expired.
+type SendPurge struct {
+	blobs store.Blob
+	sends store.Send
+	users store.User
+
+	logger *zerolog.Logger
+}
+
+func NewSendPurge(
+	cfg *config.Core,
+	blobs store.Blob,
+	sends store.Send,
+	users store.User,
+) *SendPurge {
+	return &SendPurge{
+		blobs: blobs,
+		sends: sends,
+		users: users,
+
+		logger: cfg.Logger,
+	}
+}
+
+func (sp *SendPurge) Run(ctx context.Context) error {
+	now := time.Now()
+
+	sends, err := sp.sends.Find(ctx, &model.SendFilter{DeletedBefore: &now})
+	if err != nil {
+		return err
+	}
+
+	for _, send := range sends {
+		if err := sp.sends.Delete(ctx, send.Uuid); err != nil {
+			return err
+		}
+
+		sp.removeFile(ctx, send)
+
+		if send.UserUuid != nil {
+			if err := sp.users.UpdateRevision(ctx, *send.UserUuid); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(sends) > 0 {
+		sp.logger.Info().Int("sends", len(sends)).Msg("purged sends")
+	}
+
+	return sp.sends.DeleteTokensExpiredBefore(ctx, now)
+}
+
+func (sp *SendPurge) removeFile(ctx context.Context, send *model.Send) {
+	if send.Atype != model.SendTypeFile {
+		return
+	}
+
+	file, err := send.File()
+	if err != nil {
+		sp.logger.Warn().Err(err).Str("send", send.Uuid).Msg("failed to find send file")
+		return
+	}
+
+	key := model.SendFileKey(send.Uuid, file.Id)
+	if err := sp.blobs.Delete(ctx, key); err != nil {
+		sp.logger.Warn().Err(err).Str("key", key).Msg("failed to remove send file")
+	}
+}
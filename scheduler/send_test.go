@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+	"github.com/togls/gowarden/store/blob"
+)
+
+// sendDB backs the stores of the send purge.
+type sendDB struct {
+	sends     map[string]*model.Send
+	tokens    map[string]time.Time
+	revisions []string
+}
+
+type purgeSends struct {
+	store.Send
+	db *sendDB
+}
+
+func (s purgeSends) Find(ctx context.Context, filter *model.SendFilter) ([]*model.Send, error) {
+	var list []*model.Send
+	for _, send := range s.db.sends {
+		if send.DeletionDate.Before(*filter.DeletedBefore) {
+			list = append(list, send)
+		}
+	}
+
+	return list, nil
+}
+
+func (s purgeSends) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.sends, uuid)
+	return nil
+}
+
+func (s purgeSends) DeleteTokensExpiredBefore(ctx context.Context, before time.Time) error {
+	for id, expires := range s.db.tokens {
+		if expires.Before(before) {
+			delete(s.db.tokens, id)
+		}
+	}
+
+	return nil
+}
+
+type purgeUsers struct {
+	store.User
+	db *sendDB
+}
+
+func (s purgeUsers) UpdateRevision(ctx context.Context, uuid string) error {
+	s.db.revisions = append(s.db.revisions, uuid)
+	return nil
+}
+
+func TestSendPurge(t *testing.T) {
+	logger := zerolog.Nop()
+	cfg := &config.Core{Logger: &logger}
+
+	ctx := context.Background()
+	user := "user"
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	db := &sendDB{
+		sends: map[string]*model.Send{
+			"deleted text": {Uuid: "deleted text", UserUuid: &user, Atype: model.SendTypeText, DeletionDate: past},
+			"deleted file": {Uuid: "deleted file", UserUuid: &user, Atype: model.SendTypeFile, DeletionDate: past,
+				Data: []byte(`{"Id":"file","Size":"1"}`)},
+			// expired sends are kept until their deletion date
+			"expired": {Uuid: "expired", UserUuid: &user, ExpirationDate: &past, DeletionDate: future},
+		},
+		tokens: map[string]time.Time{
+			"spent":  past,
+			"usable": future,
+		},
+	}
+
+	blobs := blob.NewFilesystem(t.TempDir())
+	key := model.SendFileKey("deleted file", "file")
+	if err := blobs.Put(ctx, key, bytes.NewReader([]byte("x")), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	sp := NewSendPurge(cfg, blobs, purgeSends{db: db}, purgeUsers{db: db})
+	if err := sp.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(db.sends) != 1 || db.sends["expired"] == nil {
+		t.Errorf("sends left = %v, want [expired]", db.sends)
+	}
+
+	if _, err := blobs.Size(ctx, key); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("send file = %v, want ErrNotFound", err)
+	}
+
+	if len(db.revisions) != 2 || db.revisions[0] != user || db.revisions[1] != user {
+		t.Errorf("revisions updated for %q, want [user user]", db.revisions)
+	}
+
+	if _, ok := db.tokens["spent"]; ok || len(db.tokens) != 1 {
+		t.Errorf("tokens left = %v, want [usable]", db.tokens)
+	}
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/service"
+	"github.com/togls/gowarden/store"
+)
+
+// TrashPurge hard deletes the ciphers that stayed in the trash for longer
+// than trash_auto_delete_days.
+type TrashPurge struct {
+	ciphers store.Cipher
+	uos     store.UserOrganization
+	users   store.User
+	purge   *service.CipherPurge
+
+	logger *zerolog.Logger
+	days   int
+}
+
+func NewTrashPurge(
+	cfg *config.Core,
+	ciphers store.Cipher,
+	uos store.UserOrganization,
+	users store.User,
+	purge *service.CipherPurge,
+) *TrashPurge {
+	return &TrashPurge{
+		ciphers: ciphers,
+		uos:     uos,
+		users:   users,
+		purge:   purge,
+
+		logger: cfg.Logger,
+		days:   cfg.TrashAutoDelete,
+	}
+}
+
+func (tp *TrashPurge) Run(ctx context.Context) error {
+	if tp.days <= 0 {
+		return nil
+	}
+
+	ciphers, err := tp.ciphers.FindDeletedBefore(ctx, time.Now().AddDate(0, 0, -tp.days))
+	if err != nil {
+		return err
+	}
+
+	// users who could see a purged cipher have to resync
+	users := make(map[string]struct{})
+
+	for _, cipher := range ciphers {
+		owners, err := tp.owners(ctx, cipher)
+		if err != nil {
+			return err
+		}
+
+		if err := tp.purge.Purge(ctx, cipher); err != nil {
+			return fmt.Errorf("failed to purge cipher %s: %w", cipher.Uuid, err)
+		}
+
+		for _, owner := range owners {
+			users[owner] = struct{}{}
+		}
+	}
+
+	for user := range users {
+		if err := tp.users.UpdateRevision(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if len(ciphers) > 0 {
+		tp.logger.Info().Int("ciphers", len(ciphers)).Msg("purged trash")
+	}
+
+	return nil
+}
+
+func (tp *TrashPurge) owners(ctx context.Context, cipher *model.Cipher) ([]string, error) {
+	if cipher.UserUuid != nil {
+		return []string{*cipher.UserUuid}, nil
+	}
+
+	if cipher.OrganizationUuid == nil {
+		return nil, nil
+	}
+
+	uos, err := tp.uos.Find(ctx, &model.UOFilter{OrgUuid: cipher.OrganizationUuid})
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make([]string, 0, len(uos))
+	for _, uo := range uos {
+		owners = append(owners, uo.UserUuid)
+	}
+
+	return owners, nil
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/service"
+	"github.com/togls/gowarden/store"
+	"github.com/togls/gowarden/store/blob"
+)
+
+// trashDB backs the stores of the trash purge, the other methods of the
+// embedded interfaces panic.
+type trashDB struct {
+	ciphers   map[string]*model.Cipher
+	uos       []*model.UserOrganization
+	revisions []string
+}
+
+type trashCiphers struct {
+	store.Cipher
+	db *trashDB
+}
+
+func (s trashCiphers) FindDeletedBefore(ctx context.Context, before time.Time) ([]*model.Cipher, error) {
+	var list []*model.Cipher
+	for _, c := range s.db.ciphers {
+		if c.DeletedAt != nil && c.DeletedAt.Before(before) {
+			list = append(list, c)
+		}
+	}
+
+	return list, nil
+}
+
+func (s trashCiphers) Delete(ctx context.Context, uuid string) error {
+	delete(s.db.ciphers, uuid)
+	return nil
+}
+
+type trashUOs struct {
+	store.UserOrganization
+	db *trashDB
+}
+
+func (s trashUOs) Find(ctx context.Context, filter *model.UOFilter) ([]*model.UserOrganization, error) {
+	var list []*model.UserOrganization
+	for _, uo := range s.db.uos {
+		if uo.OrgUuid == *filter.OrgUuid {
+			list = append(list, uo)
+		}
+	}
+
+	return list, nil
+}
+
+type trashUsers struct {
+	store.User
+	db *trashDB
+}
+
+func (s trashUsers) UpdateRevision(ctx context.Context, uuid string) error {
+	s.db.revisions = append(s.db.revisions, uuid)
+	return nil
+}
+
+type trashAttachments struct{ store.Attachment }
+
+func (trashAttachments) Find(ctx context.Context, cipher string) ([]*model.Attachment, error) {
+	return nil, nil
+}
+
+type trashCollections struct{ store.Collection }
+
+func (trashCollections) DeleteAllByCipher(ctx context.Context, cipher string) error { return nil }
+
+type trashFavorites struct{ store.Favorite }
+
+func (trashFavorites) DeleteAllByCipher(ctx context.Context, cipher string) error { return nil }
+
+type trashFolders struct{ store.Folder }
+
+func (trashFolders) RemoveCipher(ctx context.Context, cipher string) error { return nil }
+
+type trashTx struct{}
+
+func (trashTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestTrashPurge(t *testing.T) {
+	logger := zerolog.Nop()
+	cfg := &config.Core{Logger: &logger}
+	cfg.TrashAutoDelete = 30
+
+	user, org := "user", "org"
+	old := time.Now().AddDate(0, 0, -31)
+	recent := time.Now().AddDate(0, 0, -29)
+
+	db := &trashDB{
+		ciphers: map[string]*model.Cipher{
+			"old personal": {Uuid: "old personal", UserUuid: &user, DeletedAt: &old},
+			"old shared":   {Uuid: "old shared", OrganizationUuid: &org, DeletedAt: &old},
+			"recent":       {Uuid: "recent", UserUuid: &user, DeletedAt: &recent},
+			"kept":         {Uuid: "kept", UserUuid: &user},
+		},
+		uos: []*model.UserOrganization{
+			{UserUuid: "member", OrgUuid: org},
+			{UserUuid: "outsider", OrgUuid: "other org"},
+		},
+	}
+
+	ciphers := trashCiphers{db: db}
+	purge := service.NewCipherPurge(cfg, trashAttachments{}, blob.NewFilesystem(t.TempDir()), ciphers,
+		trashCollections{}, trashFavorites{}, trashFolders{}, trashTx{})
+
+	tp := NewTrashPurge(cfg, ciphers, trashUOs{db: db}, trashUsers{db: db}, purge)
+	if err := tp.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var left []string
+	for uuid := range db.ciphers {
+		left = append(left, uuid)
+	}
+	sort.Strings(left)
+
+	if len(left) != 2 || left[0] != "kept" || left[1] != "recent" {
+		t.Errorf("ciphers left = %q, want [kept recent]", left)
+	}
+
+	sort.Strings(db.revisions)
+	if len(db.revisions) != 2 || db.revisions[0] != "member" || db.revisions[1] != user {
+		t.Errorf("revisions updated for %q, want [member user]", db.revisions)
+	}
+
+	// a zero setting turns the purge off
+	cfg.TrashAutoDelete = 0
+	db.ciphers["old personal"] = &model.Cipher{Uuid: "old personal", UserUuid: &user, DeletedAt: &old}
+
+	if err := NewTrashPurge(cfg, ciphers, trashUOs{db: db}, trashUsers{db: db}, purge).Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, ok := db.ciphers["old personal"]; !ok {
+		t.Errorf("disabled purge deleted a cipher")
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+// CipherPurge hard deletes ciphers, it's shared by the trash purge job and
+// the account deletion.
+type CipherPurge struct {
+	as      store.Attachment
+	blobs   store.Blob
+	ciphers store.Cipher
+	cs      store.Collection
+	favs    store.Favorite
+	folders store.Folder
+	tx      store.Tx
+
+	logger *zerolog.Logger
+}
+
+func NewCipherPurge(
+	cfg *config.Core,
+	as store.Attachment,
+	blobs store.Blob,
+	ciphers store.Cipher,
+	cs store.Collection,
+	favs store.Favorite,
+	folders store.Folder,
+	tx store.Tx,
+) *CipherPurge {
+	return &CipherPurge{
+		as:      as,
+		blobs:   blobs,
+		ciphers: ciphers,
+		cs:      cs,
+		favs:    favs,
+		folders: folders,
+		tx:      tx,
+
+		logger: cfg.Logger,
+	}
+}
+
+// Purge deletes the cipher with everything linking to it, the attachment
+// files go once the rows are gone.
+func (cp *CipherPurge) Purge(ctx context.Context, cipher *model.Cipher) error {
+	attachments, err := cp.as.Find(ctx, cipher.Uuid)
+	if err != nil {
+		return err
+	}
+
+	err = cp.tx.WithTx(ctx, func(ctx context.Context) error {
+		for _, attachment := range attachments {
+			if err := cp.as.Delete(ctx, attachment.ID); err != nil {
+				return err
+			}
+		}
+
+		if err := cp.folders.RemoveCipher(ctx, cipher.Uuid); err != nil {
+			return err
+		}
+
+		if err := cp.favs.DeleteAllByCipher(ctx, cipher.Uuid); err != nil {
+			return err
+		}
+
+		if err := cp.cs.DeleteAllByCipher(ctx, cipher.Uuid); err != nil {
+			return err
+		}
+
+		return cp.ciphers.Delete(ctx, cipher.Uuid)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		key := attachment.FileKey()
+		if err := cp.blobs.Delete(ctx, key); err != nil {
+			cp.logger.Warn().Err(err).Str("key", key).Msg("failed to remove attachment file")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+	"github.com/togls/gowarden/store/blob"
+)
+
+// purgeStores records what the purge deletes, the other methods of the
+// interfaces embedded by the stores built on it panic.
+type purgeStores struct {
+	attachments map[string]*model.Attachment
+	deleted     []string
+	fail        error
+}
+
+func (s *purgeStores) record(what string) error {
+	if s.fail != nil {
+		return s.fail
+	}
+
+	s.deleted = append(s.deleted, what)
+	return nil
+}
+
+type purgeAttachments struct {
+	store.Attachment
+	*purgeStores
+}
+
+func (s purgeAttachments) Find(ctx context.Context, cipher string) ([]*model.Attachment, error) {
+	var list []*model.Attachment
+	for _, a := range s.attachments {
+		if a.CipherUuid == cipher {
+			list = append(list, a)
+		}
+	}
+
+	return list, nil
+}
+
+func (s purgeAttachments) Delete(ctx context.Context, id string) error {
+	return s.record("attachment " + id)
+}
+
+type purgeCiphers struct {
+	store.Cipher
+	*purgeStores
+}
+
+func (s purgeCiphers) Delete(ctx context.Context, uuid string) error {
+	return s.record("cipher " + uuid)
+}
+
+type purgeCollections struct {
+	store.Collection
+	*purgeStores
+}
+
+func (s purgeCollections) DeleteAllByCipher(ctx context.Context, cipher string) error {
+	return s.record("collections " + cipher)
+}
+
+type purgeFavorites struct {
+	store.Favorite
+	*purgeStores
+}
+
+func (s purgeFavorites) DeleteAllByCipher(ctx context.Context, cipher string) error {
+	return s.record("favorites " + cipher)
+}
+
+type purgeFolders struct {
+	store.Folder
+	*purgeStores
+}
+
+func (s purgeFolders) RemoveCipher(ctx context.Context, cipher string) error {
+	return s.record("folders " + cipher)
+}
+
+type purgeTx struct{}
+
+func (purgeTx) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestCipherPurge(t *testing.T) {
+	ctx := context.Background()
+
+	logger := zerolog.Nop()
+	cfg := &config.Core{Logger: &logger}
+
+	for _, fail := range []error{nil, errors.New("database is gone")} {
+		blobs := blob.NewFilesystem(t.TempDir())
+
+		stores := &purgeStores{
+			attachments: map[string]*model.Attachment{
+				"a": {ID: "a", CipherUuid: "cipher"},
+				"b": {ID: "b", CipherUuid: "other"},
+			},
+			fail: fail,
+		}
+
+		for _, a := range stores.attachments {
+			if err := blobs.Put(ctx, a.FileKey(), bytes.NewReader([]byte("x")), 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cp := NewCipherPurge(cfg,
+			purgeAttachments{purgeStores: stores}, blobs, purgeCiphers{purgeStores: stores},
+			purgeCollections{purgeStores: stores}, purgeFavorites{purgeStores: stores},
+			purgeFolders{purgeStores: stores}, purgeTx{})
+
+		err := cp.Purge(ctx, &model.Cipher{Uuid: "cipher"})
+		if !errors.Is(err, fail) {
+			t.Fatalf("Purge() error = %v, want %v", err, fail)
+		}
+
+		_, err = blobs.Size(ctx, stores.attachments["a"].FileKey())
+		if fail != nil {
+			// the rows are still there, so must be the file
+			if err != nil {
+				t.Errorf("failed purge removed the attachment file: %v", err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, model.ErrNotFound) {
+			t.Errorf("attachment file of the purged cipher = %v, want ErrNotFound", err)
+		}
+
+		if _, err := blobs.Size(ctx, stores.attachments["b"].FileKey()); err != nil {
+			t.Errorf("attachment file of another cipher removed: %v", err)
+		}
+
+		want := []string{"attachment a", "folders cipher", "favorites cipher", "collections cipher", "cipher cipher"}
+		if len(stores.deleted) != len(want) {
+			t.Fatalf("deleted %q, want %q", stores.deleted, want)
+		}
+		for i := range want {
+			if stores.deleted[i] != want[i] {
+				t.Errorf("deleted %q, want %q", stores.deleted, want)
+				break
+			}
+		}
+	}
+}
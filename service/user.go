@@ -1,11 +1,11 @@
-package service
-
-import "github.com/togls/gowarden/model"
-
-type User struct {
-	data *model.User
-}
-
-func (u User) CheckValidPassword(pw string) bool {
-	return false
-}
+package service
+
+import "github.com/togls/gowarden/model"
+
+type User struct {
+	data *model.User
+}
+
+func (u User) CheckValidPassword(pw string) bool {
+	return false
+}
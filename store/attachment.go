@@ -1,9 +1,21 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Attachment interface {
-	Find(cipher string) ([]*model.Attachment, error)
-
-	FindByUuid(uuid string) (*model.Attachment, error)
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Attachment interface {
+	Find(ctx context.Context, cipher string) ([]*model.Attachment, error)
+
+	FindByUuid(ctx context.Context, uuid string) (*model.Attachment, error)
+
+	// SizeByUser and SizeByOrg sum the file sizes of the owned ciphers.
+	SizeByUser(ctx context.Context, user string) (int64, error)
+	SizeByOrg(ctx context.Context, org string) (int64, error)
+
+	Create(ctx context.Context, a *model.Attachment) error
+	Save(ctx context.Context, a *model.Attachment) error
+	Delete(ctx context.Context, uuid string) error
+}
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+	"io"
+)
+
+// Blob stores file content, attachments live under
+// "attachments/<cipher>/<id>" and file sends under "sends/<send>/<file>".
+type Blob interface {
+	// Put stores size bytes read from r under key, replacing any existing content.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Get reads length bytes starting at offset, a negative length reads to the end.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Size returns the stored size in bytes.
+	Size(ctx context.Context, key string) (int64, error)
+
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,42 @@
+package blob
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/wire"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/store"
+)
+
+var WireSet = wire.NewSet(
+	New,
+)
+
+// New returns the blob store selected by the storage_backend setting.
+func New(cfg *config.Core) (store.Blob, error) {
+	switch cfg.StorageBackend {
+	case "", config.StorageFilesystem:
+		return NewFilesystem(cfg.Data), nil
+	case config.StorageS3:
+		return NewS3(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		}, http.DefaultClient)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
+
+// cleanKey normalises a key the same way for every backend: the key is
+// cleaned as a rooted path so ".." can't climb out, and the root is dropped.
+func cleanKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
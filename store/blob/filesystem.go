@@ -0,0 +1,112 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type filesystem struct {
+	root string
+}
+
+var _ store.Blob = (*filesystem)(nil)
+
+func NewFilesystem(root string) store.Blob {
+	return &filesystem{root: root}
+}
+
+func (fs filesystem) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name := fs.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return fmt.Errorf("failed to create blob folder: %w", err)
+	}
+
+	// write next to the target and rename, so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if n != size {
+		return fmt.Errorf("blob size mismatch: expected %d, got %d", size, n)
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (fs filesystem) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(fs.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, model.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if length < 0 {
+		return f, nil
+	}
+
+	return &limitedFile{Reader: io.LimitReader(f, length), Closer: f}, nil
+}
+
+func (fs filesystem) Size(ctx context.Context, key string) (int64, error) {
+	fi, err := os.Stat(fs.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, model.ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+func (fs filesystem) Delete(ctx context.Context, key string) error {
+	name := fs.path(key)
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// drop the parent folder once its last file is gone, fails while not empty
+	if dir := filepath.Dir(name); dir != filepath.Clean(fs.root) {
+		os.Remove(dir)
+	}
+
+	return nil
+}
+
+// path maps the cleaned key below root.
+func (fs filesystem) path(key string) string {
+	return filepath.Join(fs.root, filepath.FromSlash(cleanKey(key)))
+}
+
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
@@ -1,18 +1,24 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Cipher interface {
-	Create(c *model.Cipher) error
-	Save(c *model.Cipher) error
-
-	FindByUuid(uuid string) (*model.Cipher, error)
-
-	FindByOrg(org string) ([]*model.Cipher, error)
-	FindByUser(uuid string) ([]*model.Cipher, error)
-	FindByUserVisible(uuid string) ([]*model.Cipher, error)
-
-	Delete(uuid string) error
-	DeleteByOrg(org string) error
-	DeleteByUser(user string) error
-}
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Cipher interface {
+	Create(ctx context.Context, c *model.Cipher) error
+	Save(ctx context.Context, c *model.Cipher) error
+
+	FindByUuid(ctx context.Context, uuid string) (*model.Cipher, error)
+
+	FindByOrg(ctx context.Context, org string) ([]*model.Cipher, error)
+	FindByUser(ctx context.Context, uuid string) ([]*model.Cipher, error)
+	FindByUserVisible(ctx context.Context, uuid string) ([]*model.Cipher, error)
+	FindDeletedBefore(ctx context.Context, before time.Time) ([]*model.Cipher, error)
+
+	Delete(ctx context.Context, uuid string) error
+	DeleteByOrg(ctx context.Context, org string) error
+	DeleteByUser(ctx context.Context, user string) error
+}
@@ -1,41 +1,46 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Collection interface {
-	Find(filter *model.CollectionFilter) (model.CollectionList, error)
-
-	FindByUuid(uuid string) (*model.Collection, error)
-	FindByCipherAndOrg(cipher, org string) (*model.Collection, error)
-	FindByCollectionUser(collection, user string) (*model.Collection, error)
-	FindByCollectionOrg(collection, org string) (*model.Collection, error)
-
-	Save(c *model.Collection) error
-	Delete(uuid string) error
-
-	// CipherCollection
-
-	FindCollectionIds(cipher, user string) ([]string, error)
-	SaveCipher(collectionIDs []string, cipher string) error
-	DeleteCipher(collectionIDs []string, cipher string) error
-
-	// UserCollection
-
-	SaveUser(collectionIDs []string, user string, readOnly, hidePasswords bool) error
-	DeleteUser(collectionIDs []string, user string) error
-
-	CollectionWriteable(collection, user string) (bool, error)
-}
-
-type UserCollection interface {
-	Save(collection, user string, readOnly, hidePasswords bool) error
-
-	Find(filter *model.UCFilter) (model.UCList, error)
-
-	FindByCollectionUser(collection, user string) (*model.UserCollection, error)
-	FindByUserCipher(user, cipher string) (*model.UserCollection, error)
-
-	DeleteAllByCollection(collection string) error
-	DeleteAllByUserAndOrg(user, org string) error
-	DeleteByUserCollection(collection, user string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Collection interface {
+	Find(ctx context.Context, filter *model.CollectionFilter) (model.CollectionList, error)
+
+	FindByUuid(ctx context.Context, uuid string) (*model.Collection, error)
+	FindByCipherAndOrg(ctx context.Context, cipher, org string) (*model.Collection, error)
+	FindByCollectionUser(ctx context.Context, collection, user string) (*model.Collection, error)
+	FindByCollectionOrg(ctx context.Context, collection, org string) (*model.Collection, error)
+
+	Save(ctx context.Context, c *model.Collection) error
+	Delete(ctx context.Context, uuid string) error
+
+	// CipherCollection
+
+	FindCollectionIds(ctx context.Context, cipher, user string) ([]string, error)
+	SaveCipher(ctx context.Context, collectionIDs []string, cipher string) error
+	DeleteCipher(ctx context.Context, collectionIDs []string, cipher string) error
+	DeleteAllByCipher(ctx context.Context, cipher string) error
+
+	// UserCollection
+
+	SaveUser(ctx context.Context, collectionIDs []string, user string, readOnly, hidePasswords bool) error
+	DeleteUser(ctx context.Context, collectionIDs []string, user string) error
+
+	CollectionWriteable(ctx context.Context, collection, user string) (bool, error)
+}
+
+type UserCollection interface {
+	Save(ctx context.Context, collection, user string, readOnly, hidePasswords bool) error
+
+	Find(ctx context.Context, filter *model.UCFilter) (model.UCList, error)
+
+	FindByCollectionUser(ctx context.Context, collection, user string) (*model.UserCollection, error)
+	FindByUserCipher(ctx context.Context, user, cipher string) (*model.UserCollection, error)
+
+	DeleteAllByCollection(ctx context.Context, collection string) error
+	DeleteAllByUserAndOrg(ctx context.Context, user, org string) error
+	DeleteByUserCollection(ctx context.Context, collection, user string) error
+}
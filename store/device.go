@@ -1,14 +1,15 @@
-package store
-
-import (
-	"github.com/togls/gowarden/model"
-)
-
-type Device interface {
-	Create(device *model.Device) error
-	Save(device *model.Device) error
-	Delete(uuid string) error
-	FindByUuid(uuid string) (*model.Device, error)
-	FindByRefreshToken(token string) (*model.Device, error)
-	DeleteAllByUser(user string) error
-}
+package store
+
+import (
+	"context"
+	"github.com/togls/gowarden/model"
+)
+
+type Device interface {
+	Create(ctx context.Context, device *model.Device) error
+	Save(ctx context.Context, device *model.Device) error
+	Delete(ctx context.Context, uuid string) error
+	FindByUuid(ctx context.Context, uuid string) (*model.Device, error)
+	FindByRefreshToken(ctx context.Context, token string) (*model.Device, error)
+	DeleteAllByUser(ctx context.Context, user string) error
+}
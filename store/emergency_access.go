@@ -1,8 +1,18 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type EmergencyAccess interface {
-	Find(filter model.EAFilter) ([]*model.EmergencyAccess, error)
-	DeleteAllByUser(user string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type EmergencyAccess interface {
+	Find(ctx context.Context, filter model.EAFilter) ([]*model.EmergencyAccess, error)
+	FindByUuid(ctx context.Context, uuid string) (*model.EmergencyAccess, error)
+
+	Create(ctx context.Context, ea *model.EmergencyAccess) error
+	Save(ctx context.Context, ea *model.EmergencyAccess) error
+
+	Delete(ctx context.Context, uuid string) error
+	DeleteAllByUser(ctx context.Context, user string) error
+}
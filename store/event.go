@@ -0,0 +1,13 @@
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Event interface {
+	Create(ctx context.Context, event *model.Event) error
+
+	Find(ctx context.Context, filter *model.EventFilter) ([]*model.Event, error)
+}
@@ -1,7 +1,10 @@
-package store
-
-type Favorite interface {
-	IsFavorite(cipher, user string) (bool, error)
-	AddFavorite(cipher, user string) error
-	DeleteAllByUser(user string) error
-}
+package store
+
+import "context"
+
+type Favorite interface {
+	IsFavorite(ctx context.Context, cipher, user string) (bool, error)
+	AddFavorite(ctx context.Context, cipher, user string) error
+	DeleteAllByUser(ctx context.Context, user string) error
+	DeleteAllByCipher(ctx context.Context, cipher string) error
+}
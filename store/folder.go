@@ -1,17 +1,22 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Folder interface {
-	Create(folder *model.Folder) error
-
-	FindByUser(uuid string) ([]*model.Folder, error)
-
-	FindByUuid(uuid string) (*model.Folder, error)
-	FindByUserCipher(user, cipher string) (*model.Folder, error)
-
-	AddCipher(folder, cipher string) error
-
-	Delete(uuid string) error
-	DeleteAllByUser(user string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Folder interface {
+	Create(ctx context.Context, folder *model.Folder) error
+
+	FindByUser(ctx context.Context, uuid string) ([]*model.Folder, error)
+
+	FindByUuid(ctx context.Context, uuid string) (*model.Folder, error)
+	FindByUserCipher(ctx context.Context, user, cipher string) (*model.Folder, error)
+
+	AddCipher(ctx context.Context, folder, cipher string) error
+	RemoveCipher(ctx context.Context, cipher string) error
+
+	Delete(ctx context.Context, uuid string) error
+	DeleteAllByUser(ctx context.Context, user string) error
+}
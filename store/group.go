@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Group interface {
+	FindByOrg(ctx context.Context, org string) ([]*model.Group, error)
+	FindByUuid(ctx context.Context, uuid string) (*model.Group, error)
+
+	Create(ctx context.Context, group *model.Group) error
+	Save(ctx context.Context, group *model.Group) error
+	Delete(ctx context.Context, uuid string) error
+	DeleteAllByOrg(ctx context.Context, org string) error
+
+	// CollectionGroup
+
+	FindCollections(ctx context.Context, group string) ([]*model.CollectionGroup, error)
+	// SaveCollections replaces the collections of the group.
+	SaveCollections(ctx context.Context, group string, collections []*model.CollectionGroup) error
+	DeleteAllByCollection(ctx context.Context, collection string) error
+
+	// GroupUser, members are users_organizations uuids
+
+	FindMembers(ctx context.Context, group string) ([]string, error)
+	FindGroupIds(ctx context.Context, member string) ([]string, error)
+	// SaveMembers replaces the members of the group.
+	SaveMembers(ctx context.Context, group string, members []string) error
+	// SaveMemberGroups replaces the groups of the member.
+	SaveMemberGroups(ctx context.Context, member string, groups []string) error
+	DeleteMember(ctx context.Context, group, member string) error
+	DeleteAllByMember(ctx context.Context, member string) error
+}
@@ -0,0 +1,7 @@
+package store
+
+import "context"
+
+type Health interface {
+	Ping(ctx context.Context) error
+}
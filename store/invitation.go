@@ -1,9 +1,13 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Invitation interface {
-	Save(invitation *model.Invitation) error
-	FindByEmail(email string) (*model.Invitation, error)
-	Delete(email string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Invitation interface {
+	Save(ctx context.Context, invitation *model.Invitation) error
+	FindByEmail(ctx context.Context, email string) (*model.Invitation, error)
+	Delete(ctx context.Context, email string) error
+}
@@ -0,0 +1,14 @@
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type OrgApiKey interface {
+	FindByOrgAndType(ctx context.Context, org string, atype model.OrgApiKeyType) (*model.OrgApiKey, error)
+
+	Save(ctx context.Context, key *model.OrgApiKey) error
+	DeleteAllByOrg(ctx context.Context, org string) error
+}
@@ -1,7 +1,19 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type OrgPolicy interface {
-	FindConfirmedByUser(userUUID string) ([]*model.OrgPolicy, error)
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type OrgPolicy interface {
+	FindConfirmedByUser(ctx context.Context, userUUID string) ([]*model.OrgPolicy, error)
+	FindByOrg(ctx context.Context, org string) ([]*model.OrgPolicy, error)
+	FindByOrgAndType(ctx context.Context, org string, atype model.OrgPolicyType) (*model.OrgPolicy, error)
+	// FindBindingByUser returns the enabled policies of atype from the
+	// organizations the user joined. Owners and admins are exempt from
+	// their organization's.
+	FindBindingByUser(ctx context.Context, user string, atype model.OrgPolicyType) ([]*model.OrgPolicy, error)
+
+	Save(ctx context.Context, policy *model.OrgPolicy) error
+}
@@ -1,11 +1,15 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Organization interface {
-	FindByUuid(uuid string) (*model.Organization, error)
-
-	Create(org *model.Organization) error
-	Save(org *model.Organization) error
-	Delete(uuid string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Organization interface {
+	FindByUuid(ctx context.Context, uuid string) (*model.Organization, error)
+
+	Create(ctx context.Context, org *model.Organization) error
+	Save(ctx context.Context, org *model.Organization) error
+	Delete(ctx context.Context, uuid string) error
+}
@@ -1,96 +1,172 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type attachmentStore struct {
-	db *sql.DB
-}
-
-var _ store.Attachment = (*attachmentStore)(nil)
-
-func NewAttachmentStore(db *sql.DB) store.Attachment {
-	return &attachmentStore{db: db}
-}
-
-func (as attachmentStore) Find(cipher string) ([]*model.Attachment, error) {
-	sql, args, err := squirrel.Select(as.fields()...).From("attachments").
-		Where(squirrel.Eq{"cipher_uuid": cipher}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := as.db.Query(sql, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var attachments []*model.Attachment
-	for rows.Next() {
-		attachment := new(model.Attachment)
-		if err := rows.Scan(
-			&attachment.ID,
-			&attachment.CipherUuid,
-			&attachment.FileName,
-			&attachment.FileSize,
-			&attachment.Akey,
-		); err != nil {
-			return nil, err
-		}
-
-		attachments = append(attachments, attachment)
-	}
-
-	return attachments, nil
-}
-
-func (as attachmentStore) FindByUuid(uuid string) (*model.Attachment, error) {
-	sql, args, err := squirrel.Select(as.fields()...).From("attachments").
-		Where(squirrel.Eq{"id": uuid}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := as.db.Query(sql, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	if !rows.Next() {
-		return nil, model.ErrNotFound
-	}
-
-	attachment := new(model.Attachment)
-	if err := rows.Scan(
-		&attachment.ID,
-		&attachment.CipherUuid,
-		&attachment.FileName,
-		&attachment.FileSize,
-		&attachment.Akey,
-	); err != nil {
-		return nil, err
-	}
-
-	return attachment, nil
-}
-
-func (as attachmentStore) fields() []string {
-	return []string{
-		"id",
-		"cipher_uuid",
-		"file_name",
-		"file_size",
-		"akey",
-	}
-}
-
-func findAttachmentsByCipher(db *sql.DB, cipher string) ([]*model.Attachment, error) {
-	panic("implement me")
-}
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type attachmentStore struct {
+	db *DB
+}
+
+var _ store.Attachment = (*attachmentStore)(nil)
+
+func NewAttachmentStore(db *DB) store.Attachment {
+	return &attachmentStore{db: db}
+}
+
+func (as attachmentStore) Find(ctx context.Context, cipher string) ([]*model.Attachment, error) {
+	sql, args, err := squirrel.Select(as.fields()...).From("attachments").
+		Where(squirrel.Eq{"cipher_uuid": cipher}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, as.db).QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*model.Attachment
+	for rows.Next() {
+		attachment := new(model.Attachment)
+		if err := rows.Scan(
+			&attachment.ID,
+			&attachment.CipherUuid,
+			&attachment.FileName,
+			&attachment.FileSize,
+			&attachment.Akey,
+		); err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
+func (as attachmentStore) FindByUuid(ctx context.Context, uuid string) (*model.Attachment, error) {
+	sql, args, err := squirrel.Select(as.fields()...).From("attachments").
+		Where(squirrel.Eq{"id": uuid}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, as.db).QueryContext(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, model.ErrNotFound
+	}
+
+	attachment := new(model.Attachment)
+	if err := rows.Scan(
+		&attachment.ID,
+		&attachment.CipherUuid,
+		&attachment.FileName,
+		&attachment.FileSize,
+		&attachment.Akey,
+	); err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+func (as attachmentStore) SizeByUser(ctx context.Context, user string) (int64, error) {
+	return as.size(ctx, squirrel.Eq{"c.user_uuid": user})
+}
+
+func (as attachmentStore) SizeByOrg(ctx context.Context, org string) (int64, error) {
+	return as.size(ctx, squirrel.Eq{"c.organization_uuid": org})
+}
+
+func (as attachmentStore) size(ctx context.Context, owner squirrel.Eq) (int64, error) {
+	sqls, args, err := squirrel.Select("COALESCE(SUM(a.file_size), 0)").
+		From("attachments AS a").
+		Join("ciphers AS c ON c.uuid = a.cipher_uuid").
+		Where(owner).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	err = conn(ctx, as.db).QueryRowContext(ctx, sqls, args...).Scan(&size)
+	return size, err
+}
+
+func (as attachmentStore) Create(ctx context.Context, a *model.Attachment) error {
+	sqls, args, err := squirrel.Insert("attachments").
+		Columns(as.fields()...).
+		Values(a.ID, a.CipherUuid, a.FileName, a.FileSize, a.Akey).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, as.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (as attachmentStore) Save(ctx context.Context, a *model.Attachment) error {
+	sqls, args, err := squirrel.Update("attachments").
+		Set("file_name", a.FileName).
+		Set("file_size", a.FileSize).
+		Set("akey", a.Akey).
+		Where(squirrel.Eq{"id": a.ID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, as.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (as attachmentStore) Delete(ctx context.Context, uuid string) error {
+	sqls, args, err := squirrel.Delete("attachments").
+		Where(squirrel.Eq{"id": uuid}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	result, err := conn(ctx, as.db).ExecContext(ctx, sqls, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return model.ErrNotFound
+	}
+
+	return nil
+}
+
+func (as attachmentStore) fields() []string {
+	return []string{
+		"id",
+		"cipher_uuid",
+		"file_name",
+		"file_size",
+		"akey",
+	}
+}
+
+func findAttachmentsByCipher(ctx context.Context, db *DB, cipher string) ([]*model.Attachment, error) {
+	panic("implement me")
+}
@@ -1,48 +1,203 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type emergencyAccessStore struct {
-	db *sql.DB
-}
-
-var _ store.EmergencyAccess = (*emergencyAccessStore)(nil)
-
-func NewEmergencyAccessStore(db *sql.DB) store.EmergencyAccess {
-	return &emergencyAccessStore{db: db}
-}
-
-func (eas *emergencyAccessStore) Find(filter model.EAFilter) ([]*model.EmergencyAccess, error) {
-	builder := squirrel.Select("*").From("emergency_access")
-
-	if filter.GrantorUuid != nil {
-		builder = builder.Where("grantor_uuid = ?", *filter.GrantorUuid)
-	}
-
-	if filter.GranteeUuid != nil {
-		builder = builder.Where("grantee_uuid = ?", *filter.GranteeUuid)
-	}
-
-	panic("TODO: implement")
-}
-
-func (eas emergencyAccessStore) DeleteAllByUser(user string) error {
-	sql, args, err := squirrel.Delete("emergency_access").
-		Where(squirrel.Or{
-			squirrel.Eq{"grantor_uuid": user},
-			squirrel.Eq{"grantee_uuid": user},
-		}).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = eas.db.Exec(sql, args...)
-	return err
-}
+package raw
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type emergencyAccessStore struct {
+	db *DB
+}
+
+var _ store.EmergencyAccess = (*emergencyAccessStore)(nil)
+
+func NewEmergencyAccessStore(db *DB) store.EmergencyAccess {
+	return &emergencyAccessStore{db: db}
+}
+
+func (eas *emergencyAccessStore) Find(ctx context.Context, filter model.EAFilter) ([]*model.EmergencyAccess, error) {
+	builder := squirrel.Select(eas.fields()...).From("emergency_access")
+
+	if filter.GrantorUuid != nil {
+		builder = builder.Where(squirrel.Eq{"grantor_uuid": *filter.GrantorUuid})
+	}
+
+	if filter.GranteeUuid != nil {
+		builder = builder.Where(squirrel.Eq{"grantee_uuid": *filter.GranteeUuid})
+	}
+
+	if filter.Email != nil {
+		builder = builder.Where(squirrel.Eq{"email": *filter.Email})
+	}
+
+	if filter.Status != nil {
+		builder = builder.Where(squirrel.Eq{"status": *filter.Status})
+	}
+
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, eas.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*model.EmergencyAccess{}
+	for rows.Next() {
+		ea, err := eas.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, ea)
+	}
+
+	return list, rows.Err()
+}
+
+func (eas *emergencyAccessStore) FindByUuid(ctx context.Context, uuid string) (*model.EmergencyAccess, error) {
+	sqls, args, err := squirrel.Select(eas.fields()...).From("emergency_access").
+		Where(squirrel.Eq{"uuid": uuid}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, eas.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	return eas.scan(rows)
+}
+
+func (eas *emergencyAccessStore) Create(ctx context.Context, ea *model.EmergencyAccess) error {
+	now := time.Now()
+	ea.CreatedAt = now
+	ea.UpdatedAt = now
+
+	sqls, args, err := squirrel.Insert("emergency_access").
+		Columns(eas.fields()...).
+		Values(
+			ea.Uuid,
+			ea.GrantorUuid,
+			ea.GranteeUuid,
+			ea.Email,
+			ea.KeyEncrypted,
+			ea.Atype,
+			ea.Status,
+			ea.WaitTimeDays,
+			ea.RecoveryInitiatedAt,
+			ea.LastNotificationAt,
+			ea.UpdatedAt,
+			ea.CreatedAt,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, eas.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (eas *emergencyAccessStore) Save(ctx context.Context, ea *model.EmergencyAccess) error {
+	ea.UpdatedAt = time.Now()
+
+	sqls, args, err := squirrel.Update("emergency_access").
+		SetMap(map[string]any{
+			"grantee_uuid":          ea.GranteeUuid,
+			"email":                 ea.Email,
+			"key_encrypted":         ea.KeyEncrypted,
+			"atype":                 ea.Atype,
+			"status":                ea.Status,
+			"wait_time_days":        ea.WaitTimeDays,
+			"recovery_initiated_at": ea.RecoveryInitiatedAt,
+			"last_notification_at":  ea.LastNotificationAt,
+			"updated_at":            ea.UpdatedAt,
+		}).
+		Where(squirrel.Eq{"uuid": ea.Uuid}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, eas.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (eas *emergencyAccessStore) Delete(ctx context.Context, uuid string) error {
+	sqls, args, err := squirrel.Delete("emergency_access").
+		Where(squirrel.Eq{"uuid": uuid}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, eas.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (eas emergencyAccessStore) DeleteAllByUser(ctx context.Context, user string) error {
+	sql, args, err := squirrel.Delete("emergency_access").
+		Where(squirrel.Or{
+			squirrel.Eq{"grantor_uuid": user},
+			squirrel.Eq{"grantee_uuid": user},
+		}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, eas.db).ExecContext(ctx, sql, args...)
+	return err
+}
+
+func (emergencyAccessStore) fields() []string {
+	return []string{
+		"uuid",
+		"grantor_uuid",
+		"grantee_uuid",
+		"email",
+		"key_encrypted",
+		"atype",
+		"status",
+		"wait_time_days",
+		"recovery_initiated_at",
+		"last_notification_at",
+		"updated_at",
+		"created_at",
+	}
+}
+
+func (emergencyAccessStore) scan(rows scanner) (*model.EmergencyAccess, error) {
+	var ea model.EmergencyAccess
+	err := rows.Scan(
+		&ea.Uuid,
+		&ea.GrantorUuid,
+		&ea.GranteeUuid,
+		&ea.Email,
+		&ea.KeyEncrypted,
+		&ea.Atype,
+		&ea.Status,
+		&ea.WaitTimeDays,
+		&ea.RecoveryInitiatedAt,
+		&ea.LastNotificationAt,
+		&ea.UpdatedAt,
+		&ea.CreatedAt,
+	)
+
+	return &ea, err
+}
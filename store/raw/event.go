@@ -0,0 +1,145 @@
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type eventStore struct {
+	db *DB
+}
+
+var _ store.Event = (*eventStore)(nil)
+
+func NewEventStore(db *DB) store.Event {
+	return &eventStore{db: db}
+}
+
+func (es eventStore) Create(ctx context.Context, event *model.Event) error {
+	sqls, args, err := squirrel.Insert("event").
+		Columns(es.fields()...).
+		Values(
+			event.Uuid,
+			event.Atype,
+			event.UserUuid,
+			event.OrgUuid,
+			event.CipherUuid,
+			event.CollectionUuid,
+			event.GroupUuid,
+			event.OrgUserUuid,
+			event.ActUserUuid,
+			event.DeviceType,
+			event.IpAddress,
+			event.EventDate,
+			event.PolicyUuid,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, es.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (es eventStore) Find(ctx context.Context, filter *model.EventFilter) ([]*model.Event, error) {
+	builder := squirrel.Select(es.fields()...).From("event").
+		Where(squirrel.GtOrEq{"event_date": filter.Start}).
+		OrderBy("event_date DESC", "uuid DESC")
+
+	if filter.Cursor != nil {
+		builder = builder.Where(squirrel.Or{
+			squirrel.Lt{"event_date": filter.Cursor.Date},
+			squirrel.And{
+				squirrel.Eq{"event_date": filter.Cursor.Date},
+				squirrel.Lt{"uuid": filter.Cursor.Uuid},
+			},
+		})
+	} else {
+		builder = builder.Where(squirrel.Lt{"event_date": filter.End})
+	}
+
+	if filter.OrgUuid != nil {
+		builder = builder.Where(squirrel.Eq{"org_uuid": *filter.OrgUuid})
+	}
+
+	if filter.CipherUuid != nil {
+		builder = builder.Where(squirrel.Eq{"cipher_uuid": *filter.CipherUuid})
+	}
+
+	if filter.MemberUuid != nil {
+		builder = builder.Where(squirrel.Or{
+			squirrel.Eq{"user_uuid": *filter.MemberUuid},
+			squirrel.Eq{"act_user_uuid": *filter.MemberUuid},
+		})
+	}
+
+	if filter.Limit > 0 {
+		builder = builder.Limit(filter.Limit)
+	}
+
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, es.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.Event
+	for rows.Next() {
+		event, err := es.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, event)
+	}
+
+	return list, rows.Err()
+}
+
+func (eventStore) fields() []string {
+	return []string{
+		"uuid",
+		"event_type",
+		"user_uuid",
+		"org_uuid",
+		"cipher_uuid",
+		"collection_uuid",
+		"group_uuid",
+		"org_user_uuid",
+		"act_user_uuid",
+		"device_type",
+		"ip_address",
+		"event_date",
+		"policy_uuid",
+	}
+}
+
+func (eventStore) scan(rows scanner) (*model.Event, error) {
+	var event model.Event
+	err := rows.Scan(
+		&event.Uuid,
+		&event.Atype,
+		&event.UserUuid,
+		&event.OrgUuid,
+		&event.CipherUuid,
+		&event.CollectionUuid,
+		&event.GroupUuid,
+		&event.OrgUserUuid,
+		&event.ActUserUuid,
+		&event.DeviceType,
+		&event.IpAddress,
+		&event.EventDate,
+		&event.PolicyUuid,
+	)
+
+	return &event, err
+}
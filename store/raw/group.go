@@ -0,0 +1,339 @@
+package raw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type groupStore struct {
+	db *DB
+}
+
+var _ store.Group = (*groupStore)(nil)
+
+func NewGroupStore(db *DB) store.Group {
+	return &groupStore{db: db}
+}
+
+func (gs groupStore) FindByOrg(ctx context.Context, org string) ([]*model.Group, error) {
+	sqls, args, err := squirrel.Select(gs.fields()...).From("`groups`").
+		Where(squirrel.Eq{"organizations_uuid": org}).
+		OrderBy("name").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, gs.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.Group
+	for rows.Next() {
+		group, err := gs.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, group)
+	}
+
+	return list, rows.Err()
+}
+
+func (gs groupStore) FindByUuid(ctx context.Context, uuid string) (*model.Group, error) {
+	sqls, args, err := squirrel.Select(gs.fields()...).From("`groups`").
+		Where(squirrel.Eq{"uuid": uuid}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, gs.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	return gs.scan(rows)
+}
+
+func (gs groupStore) Create(ctx context.Context, group *model.Group) error {
+	now := time.Now()
+	group.CreatedAt = now
+	group.UpdatedAt = now
+
+	return gs.Save(ctx, group)
+}
+
+func (gs groupStore) Save(ctx context.Context, group *model.Group) error {
+	group.UpdatedAt = time.Now()
+
+	sqls, args, err := squirrel.Replace("`groups`").
+		Columns(gs.fields()...).
+		Values(
+			group.Uuid,
+			group.OrgUuid,
+			group.Name,
+			group.AccessAll,
+			group.ExternalId,
+			group.CreatedAt,
+			group.UpdatedAt,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, gs.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (gs groupStore) Delete(ctx context.Context, uuid string) error {
+	if err := gs.exec(ctx, squirrel.Delete("collections_groups").Where(squirrel.Eq{"groups_uuid": uuid})); err != nil {
+		return err
+	}
+
+	if err := gs.exec(ctx, squirrel.Delete("groups_users").Where(squirrel.Eq{"groups_uuid": uuid})); err != nil {
+		return err
+	}
+
+	return gs.exec(ctx, squirrel.Delete("`groups`").Where(squirrel.Eq{"uuid": uuid}))
+}
+
+func (gs groupStore) DeleteAllByOrg(ctx context.Context, org string) error {
+	groups, err := gs.FindByOrg(ctx, org)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		if err := gs.Delete(ctx, group.Uuid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (gs groupStore) FindCollections(ctx context.Context, group string) ([]*model.CollectionGroup, error) {
+	sqls, args, err := squirrel.Select(
+		"collections_uuid",
+		"groups_uuid",
+		"read_only",
+		"hide_passwords",
+	).From("collections_groups").
+		Where(squirrel.Eq{"groups_uuid": group}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, gs.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.CollectionGroup
+	for rows.Next() {
+		var cg model.CollectionGroup
+		err := rows.Scan(
+			&cg.CollectionUuid,
+			&cg.GroupUuid,
+			&cg.ReadOnly,
+			&cg.HidePasswords,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &cg)
+	}
+
+	return list, rows.Err()
+}
+
+func (gs groupStore) SaveCollections(ctx context.Context, group string, collections []*model.CollectionGroup) error {
+	if err := gs.exec(ctx, squirrel.Delete("collections_groups").Where(squirrel.Eq{"groups_uuid": group})); err != nil {
+		return err
+	}
+
+	if len(collections) == 0 {
+		return nil
+	}
+
+	builder := squirrel.Insert("collections_groups").
+		Columns("collections_uuid", "groups_uuid", "read_only", "hide_passwords")
+
+	for _, cg := range collections {
+		builder = builder.Values(cg.CollectionUuid, group, cg.ReadOnly, cg.HidePasswords)
+	}
+
+	return gs.exec(ctx, builder)
+}
+
+func (gs groupStore) DeleteAllByCollection(ctx context.Context, collection string) error {
+	return gs.exec(ctx, squirrel.Delete("collections_groups").Where(squirrel.Eq{"collections_uuid": collection}))
+}
+
+func (gs groupStore) FindMembers(ctx context.Context, group string) ([]string, error) {
+	return gs.findIds(ctx, squirrel.Select("users_organizations_uuid").From("groups_users").
+		Where(squirrel.Eq{"groups_uuid": group}))
+}
+
+func (gs groupStore) FindGroupIds(ctx context.Context, member string) ([]string, error) {
+	return gs.findIds(ctx, squirrel.Select("groups_uuid").From("groups_users").
+		Where(squirrel.Eq{"users_organizations_uuid": member}))
+}
+
+func (gs groupStore) SaveMembers(ctx context.Context, group string, members []string) error {
+	if err := gs.exec(ctx, squirrel.Delete("groups_users").Where(squirrel.Eq{"groups_uuid": group})); err != nil {
+		return err
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	builder := squirrel.Insert("groups_users").Columns("groups_uuid", "users_organizations_uuid")
+	for _, member := range members {
+		builder = builder.Values(group, member)
+	}
+
+	return gs.exec(ctx, builder)
+}
+
+func (gs groupStore) SaveMemberGroups(ctx context.Context, member string, groups []string) error {
+	if err := gs.DeleteAllByMember(ctx, member); err != nil {
+		return err
+	}
+
+	if len(groups) == 0 {
+		return nil
+	}
+
+	builder := squirrel.Insert("groups_users").Columns("groups_uuid", "users_organizations_uuid")
+	for _, group := range groups {
+		builder = builder.Values(group, member)
+	}
+
+	return gs.exec(ctx, builder)
+}
+
+func (gs groupStore) DeleteMember(ctx context.Context, group, member string) error {
+	return gs.exec(ctx, squirrel.Delete("groups_users").Where(squirrel.Eq{
+		"groups_uuid":              group,
+		"users_organizations_uuid": member,
+	}))
+}
+
+func (gs groupStore) DeleteAllByMember(ctx context.Context, member string) error {
+	return gs.exec(ctx, squirrel.Delete("groups_users").Where(squirrel.Eq{"users_organizations_uuid": member}))
+}
+
+func (gs groupStore) findIds(ctx context.Context, builder squirrel.SelectBuilder) ([]string, error) {
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, gs.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		list = append(list, id)
+	}
+
+	return list, rows.Err()
+}
+
+func (gs groupStore) exec(ctx context.Context, builder squirrel.Sqlizer) error {
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, gs.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (groupStore) fields() []string {
+	return []string{
+		"uuid",
+		"organizations_uuid",
+		"name",
+		"access_all",
+		"external_id",
+		"creation_date",
+		"revision_date",
+	}
+}
+
+func (groupStore) scan(rows scanner) (*model.Group, error) {
+	var group model.Group
+	err := rows.Scan(
+		&group.Uuid,
+		&group.OrgUuid,
+		&group.Name,
+		&group.AccessAll,
+		&group.ExternalId,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+
+	return &group, err
+}
+
+// collectionGrants lists the collections a user reaches directly or through
+// the groups of their confirmed memberships, one row per grant.
+var collectionGrants = fmt.Sprintf(`
+SELECT uc.collection_uuid, uc.read_only, uc.hide_passwords
+FROM users_collections AS uc
+INNER JOIN collections AS c ON c.uuid = uc.collection_uuid
+INNER JOIN users_organizations AS uo ON uo.org_uuid = c.org_uuid AND uo.user_uuid = uc.user_uuid
+WHERE uc.user_uuid = ? AND uo.status = %[1]d
+UNION ALL
+SELECT cg.collections_uuid, cg.read_only, cg.hide_passwords
+FROM collections_groups AS cg
+INNER JOIN groups_users AS gu ON gu.groups_uuid = cg.groups_uuid
+INNER JOIN users_organizations AS uo ON uo.uuid = gu.users_organizations_uuid
+WHERE uo.user_uuid = ? AND uo.status = %[1]d
+UNION ALL
+SELECT c.uuid, false, false
+FROM collections AS c
+INNER JOIN `+"`groups`"+` AS g ON g.organizations_uuid = c.org_uuid AND g.access_all = true
+INNER JOIN groups_users AS gu ON gu.groups_uuid = g.uuid
+INNER JOIN users_organizations AS uo ON uo.uuid = gu.users_organizations_uuid
+WHERE uo.user_uuid = ? AND uo.status = %[1]d`, model.UOStatusConfirmed)
+
+// userGrants merges the grants of the user per collection, a collection is
+// read only or hides passwords only when every grant on it does.
+func userGrants(user string) (string, []any) {
+	sqls := `SELECT collection_uuid, MIN(read_only) AS read_only, MIN(hide_passwords) AS hide_passwords
+FROM (` + collectionGrants + `) AS grants
+GROUP BY collection_uuid`
+
+	return sqls, []any{user, user, user}
+}
@@ -0,0 +1,22 @@
+package raw
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/togls/gowarden/store"
+)
+
+type healthStore struct {
+	db *sql.DB
+}
+
+var _ store.Health = (*healthStore)(nil)
+
+func NewHealthStore(db *sql.DB) store.Health {
+	return &healthStore{db: db}
+}
+
+func (hs healthStore) Ping(ctx context.Context) error {
+	return hs.db.PingContext(ctx)
+}
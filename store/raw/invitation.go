@@ -1,65 +1,66 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type invitationStore struct {
-	db *sql.DB
-}
-
-var _ store.Invitation = (*invitationStore)(nil)
-
-func NewInvitationStore(db *sql.DB) store.Invitation {
-	return &invitationStore{db: db}
-}
-
-func (is invitationStore) Save(invitation *model.Invitation) error {
-	sqls, args, err := squirrel.Insert("invitations").
-		Columns("email").
-		Values(invitation.Email).
-		ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = is.db.Exec(sqls, args...)
-	return err
-}
-
-func (is invitationStore) FindByEmail(email string) (*model.Invitation, error) {
-	sqls, args, err := squirrel.Select("email").
-		From("invitations").
-		Where(squirrel.Eq{"email": email}).
-		ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	var invitation model.Invitation
-	err = is.db.QueryRow(sqls, args...).Scan(&invitation.Email)
-	if err == nil {
-		return &invitation, nil
-	}
-
-	if err == sql.ErrNoRows {
-		return nil, model.ErrNotFound
-	}
-
-	return nil, err
-}
-
-func (is invitationStore) Delete(email string) error {
-	sqls, args, err := squirrel.Delete("invitations").
-		Where(squirrel.Eq{"email": email}).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = is.db.Exec(sqls, args...)
-	return err
-}
+package raw
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type invitationStore struct {
+	db *DB
+}
+
+var _ store.Invitation = (*invitationStore)(nil)
+
+func NewInvitationStore(db *DB) store.Invitation {
+	return &invitationStore{db: db}
+}
+
+func (is invitationStore) Save(ctx context.Context, invitation *model.Invitation) error {
+	sqls, args, err := squirrel.Insert("invitations").
+		Columns("email").
+		Values(invitation.Email).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, is.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (is invitationStore) FindByEmail(ctx context.Context, email string) (*model.Invitation, error) {
+	sqls, args, err := squirrel.Select("email").
+		From("invitations").
+		Where(squirrel.Eq{"email": email}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation model.Invitation
+	err = conn(ctx, is.db).QueryRowContext(ctx, sqls, args...).Scan(&invitation.Email)
+	if err == nil {
+		return &invitation, nil
+	}
+
+	if err == sql.ErrNoRows {
+		return nil, model.ErrNotFound
+	}
+
+	return nil, err
+}
+
+func (is invitationStore) Delete(ctx context.Context, email string) error {
+	sqls, args, err := squirrel.Delete("invitations").
+		Where(squirrel.Eq{"email": email}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, is.db).ExecContext(ctx, sqls, args...)
+	return err
+}
@@ -0,0 +1,94 @@
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type orgApiKeyStore struct {
+	db *DB
+}
+
+var _ store.OrgApiKey = (*orgApiKeyStore)(nil)
+
+func NewOrgApiKeyStore(db *DB) store.OrgApiKey {
+	return &orgApiKeyStore{db: db}
+}
+
+func (ks orgApiKeyStore) FindByOrgAndType(ctx context.Context, org string, atype model.OrgApiKeyType) (*model.OrgApiKey, error) {
+	sqls, args, err := squirrel.Select(ks.fields()...).From("organization_api_key").
+		Where(squirrel.Eq{
+			"org_uuid": org,
+			"atype":    atype,
+		}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, ks.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	key := new(model.OrgApiKey)
+	err = rows.Scan(
+		&key.Uuid,
+		&key.OrgUuid,
+		&key.Atype,
+		&key.ApiKey,
+		&key.RevisionDate,
+	)
+
+	return key, err
+}
+
+func (ks orgApiKeyStore) Save(ctx context.Context, key *model.OrgApiKey) error {
+	sqls, args, err := squirrel.Replace("organization_api_key").
+		Columns(ks.fields()...).
+		Values(
+			key.Uuid,
+			key.OrgUuid,
+			key.Atype,
+			key.ApiKey,
+			key.RevisionDate,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, ks.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (ks orgApiKeyStore) DeleteAllByOrg(ctx context.Context, org string) error {
+	sqls, args, err := squirrel.Delete("organization_api_key").
+		Where(squirrel.Eq{"org_uuid": org}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, ks.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (orgApiKeyStore) fields() []string {
+	return []string{
+		"uuid",
+		"org_uuid",
+		"atype",
+		"api_key",
+		"revision_date",
+	}
+}
@@ -1,56 +1,151 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type opStore struct {
-	db *sql.DB
-}
-
-var _ store.OrgPolicy = (*opStore)(nil)
-
-func NewOrgPolicyStore(db *sql.DB) store.OrgPolicy {
-	return &opStore{db: db}
-}
-
-func (ops opStore) FindConfirmedByUser(user string) ([]*model.OrgPolicy, error) {
-	sqls, args, err := squirrel.Select("op.*").From("org_policies AS op").
-		InnerJoin("users_organizations AS uo ON uo.org_uuid = op.org_uuid").
-		Where(squirrel.Eq{
-			"uo.user_uuid": user,
-			"uo.status":    model.UOStatusConfirmed,
-		}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	raws, err := ops.db.Query(sqls, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	var list []*model.OrgPolicy
-	for raws.Next() {
-		var op model.OrgPolicy
-		err = raws.Scan(
-			&op.Uuid,
-			&op.OrgUuid,
-			&op.Atype,
-			&op.Enabled,
-			&op.Data,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		list = append(list, &op)
-	}
-
-	return list, nil
-}
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type opStore struct {
+	db *DB
+}
+
+var _ store.OrgPolicy = (*opStore)(nil)
+
+func NewOrgPolicyStore(db *DB) store.OrgPolicy {
+	return &opStore{db: db}
+}
+
+func (ops opStore) FindConfirmedByUser(ctx context.Context, user string) ([]*model.OrgPolicy, error) {
+	sqls, args, err := squirrel.Select(ops.fields("op.")...).From("org_policies AS op").
+		InnerJoin("users_organizations AS uo ON uo.org_uuid = op.org_uuid").
+		Where(squirrel.Eq{
+			"uo.user_uuid": user,
+			"uo.status":    model.UOStatusConfirmed,
+		}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return ops.query(ctx, sqls, args...)
+}
+
+func (ops opStore) FindBindingByUser(ctx context.Context, user string, atype model.OrgPolicyType) ([]*model.OrgPolicy, error) {
+	sqls, args, err := squirrel.Select(ops.fields("op.")...).From("org_policies AS op").
+		InnerJoin("users_organizations AS uo ON uo.org_uuid = op.org_uuid").
+		Where(squirrel.Eq{
+			"uo.user_uuid": user,
+			"uo.status":    []model.UOStatus{model.UOStatusAccepted, model.UOStatusConfirmed},
+			"op.atype":     atype,
+			"op.enabled":   true,
+		}).
+		Where(squirrel.NotEq{"uo.atype": []model.UOType{model.UOTypeOwner, model.UOTypeAdmin}}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return ops.query(ctx, sqls, args...)
+}
+
+func (ops opStore) FindByOrg(ctx context.Context, org string) ([]*model.OrgPolicy, error) {
+	sqls, args, err := squirrel.Select(ops.fields("")...).From("org_policies").
+		Where(squirrel.Eq{"org_uuid": org}).
+		OrderBy("atype").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return ops.query(ctx, sqls, args...)
+}
+
+func (ops opStore) FindByOrgAndType(ctx context.Context, org string, atype model.OrgPolicyType) (*model.OrgPolicy, error) {
+	sqls, args, err := squirrel.Select(ops.fields("")...).From("org_policies").
+		Where(squirrel.Eq{
+			"org_uuid": org,
+			"atype":    atype,
+		}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, ops.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	return ops.scan(rows)
+}
+
+func (ops opStore) Save(ctx context.Context, policy *model.OrgPolicy) error {
+	sqls, args, err := squirrel.Replace("org_policies").
+		Columns(ops.fields("")...).
+		Values(
+			policy.Uuid,
+			policy.OrgUuid,
+			policy.Atype,
+			policy.Enabled,
+			policy.Data,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, ops.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (ops opStore) query(ctx context.Context, sqls string, args ...any) ([]*model.OrgPolicy, error) {
+	rows, err := conn(ctx, ops.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.OrgPolicy
+	for rows.Next() {
+		op, err := ops.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, op)
+	}
+
+	return list, rows.Err()
+}
+
+func (opStore) fields(prefix string) []string {
+	return []string{
+		prefix + "uuid",
+		prefix + "org_uuid",
+		prefix + "atype",
+		prefix + "enabled",
+		prefix + "data",
+	}
+}
+
+func (opStore) scan(rows scanner) (*model.OrgPolicy, error) {
+	var op model.OrgPolicy
+	err := rows.Scan(
+		&op.Uuid,
+		&op.OrgUuid,
+		&op.Atype,
+		&op.Enabled,
+		&op.Data,
+	)
+
+	return &op, err
+}
@@ -1,24 +1,30 @@
-package raw
-
-import (
-	"github.com/google/wire"
-)
-
-var WireSet = wire.NewSet(
-	NewAttachmentStore,
-	NewCipherStore,
-	NewCollectionStore,
-	NewDeviceStore,
-	NewEmergencyAccessStore,
-	NewFavoriteStore,
-	NewFolderStore,
-	NewInvitationStore,
-	NewOrgPolicyStore,
-	NewOrganizationStore,
-	NewSendStore,
-	NewTwoFactorStore,
-	NewTwoFactorIncompleteStore,
-	NewUserCollectionStore,
-	NewUserOrganizationStore,
-	NewUserStore,
-)
+package raw
+
+import (
+	"github.com/google/wire"
+)
+
+var WireSet = wire.NewSet(
+	NewDB,
+	NewAttachmentStore,
+	NewCipherStore,
+	NewCollectionStore,
+	NewDeviceStore,
+	NewEmergencyAccessStore,
+	NewEventStore,
+	NewFavoriteStore,
+	NewFolderStore,
+	NewGroupStore,
+	NewHealthStore,
+	NewInvitationStore,
+	NewOrgApiKeyStore,
+	NewOrgPolicyStore,
+	NewOrganizationStore,
+	NewSendStore,
+	NewTwoFactorStore,
+	NewTwoFactorIncompleteStore,
+	NewTxStore,
+	NewUserCollectionStore,
+	NewUserOrganizationStore,
+	NewUserStore,
+)
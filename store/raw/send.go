@@ -1,110 +1,269 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type sendStore struct {
-	db *sql.DB
-}
-
-var _ store.Send = (*sendStore)(nil)
-
-func NewSendStore(db *sql.DB) store.Send {
-	return &sendStore{db: db}
-}
-
-func (ss sendStore) Find(filter *model.SendFilter) ([]*model.Send, error) {
-	builder := squirrel.Select("*").From("sends")
-
-	if filter.UserUuid != nil {
-		builder = builder.Where(
-			squirrel.Eq{"user_uuid": *filter.UserUuid},
-		)
-	}
-
-	sqls, args, err := builder.ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := ss.db.Query(sqls, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-
-	var list []*model.Send
-	for rows.Next() {
-		send, err := ss.scan(rows)
-		if err != nil {
-			return nil, err
-		}
-
-		list = append(list, send)
-	}
-
-	return list, nil
-}
-
-func (ss sendStore) DeleteAllByUser(userUuid string) error {
-	_, err := ss.db.Exec("DELETE FROM sends WHERE user_uuid = ?", userUuid)
-	return err
-}
-
-func (sendStore) fields() []string {
-	return []string{
-		"uuid",
-		"user_uuid",
-		"organization_uuid",
-		"name",
-		"notes",
-		"atype",
-		"data",
-		"akey",
-		"password_hash",
-		"password_salt",
-		"password_iter",
-		"max_access_count",
-		"access_count",
-		"creation_date",
-		"revision_date",
-		"expiration_date",
-		"deletion_date",
-		"disabled",
-		"hide_email",
-	}
-}
-
-func (sendStore) scan(rows *sql.Rows) (*model.Send, error) {
-	var send model.Send
-	err := rows.Scan(
-		&send.Uuid,
-		&send.UserUuid,
-		&send.OrganizationUuid,
-		&send.Name,
-		&send.Notes,
-		&send.Atype,
-		&send.Data,
-		&send.Akey,
-		&send.PasswordHash,
-		&send.PasswordSalt,
-		&send.PasswordIter,
-		&send.MaxAccessCount,
-		&send.AccessCount,
-		&send.CreationDate,
-		&send.RevisionDate,
-		&send.ExpirationDate,
-		&send.DeletionDate,
-		&send.Disabled,
-		&send.HideEmail,
-	)
-
-	return &send, err
-}
+package raw
+
+import (
+	"context"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type sendStore struct {
+	db *DB
+}
+
+var _ store.Send = (*sendStore)(nil)
+
+func NewSendStore(db *DB) store.Send {
+	return &sendStore{db: db}
+}
+
+func (ss sendStore) Find(ctx context.Context, filter *model.SendFilter) ([]*model.Send, error) {
+	builder := squirrel.Select(ss.fields()...).From("sends")
+
+	if filter.UserUuid != nil {
+		builder = builder.Where(
+			squirrel.Eq{"user_uuid": *filter.UserUuid},
+		)
+	}
+
+	if filter.DeletedBefore != nil {
+		builder = builder.Where(
+			squirrel.LtOrEq{"deletion_date": *filter.DeletedBefore},
+		)
+	}
+
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, ss.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var list []*model.Send
+	for rows.Next() {
+		send, err := ss.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, send)
+	}
+
+	return list, nil
+}
+
+func (ss sendStore) FindByUuid(ctx context.Context, uuid string) (*model.Send, error) {
+	sqls, args, err := squirrel.Select(ss.fields()...).From("sends").
+		Where(squirrel.Eq{"uuid": uuid}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, ss.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	return ss.scan(rows)
+}
+
+func (ss sendStore) Create(ctx context.Context, send *model.Send) error {
+	now := time.Now()
+	send.CreationDate = now
+	send.RevisionDate = now
+
+	sqls, args, err := squirrel.Insert("sends").
+		Columns(ss.fields()...).
+		Values(
+			send.Uuid,
+			send.UserUuid,
+			send.OrganizationUuid,
+			send.Name,
+			send.Notes,
+			send.Atype,
+			send.Data,
+			send.Akey,
+			send.PasswordHash,
+			send.PasswordSalt,
+			send.PasswordIter,
+			send.MaxAccessCount,
+			send.AccessCount,
+			send.CreationDate,
+			send.RevisionDate,
+			send.ExpirationDate,
+			send.DeletionDate,
+			send.Disabled,
+			send.HideEmail,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, ss.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (ss sendStore) Save(ctx context.Context, send *model.Send) error {
+	send.RevisionDate = time.Now()
+
+	sqls, args, err := squirrel.Update("sends").
+		SetMap(map[string]any{
+			"name":             send.Name,
+			"notes":            send.Notes,
+			"data":             send.Data,
+			"akey":             send.Akey,
+			"password_hash":    send.PasswordHash,
+			"password_salt":    send.PasswordSalt,
+			"password_iter":    send.PasswordIter,
+			"max_access_count": send.MaxAccessCount,
+			"access_count":     send.AccessCount,
+			"revision_date":    send.RevisionDate,
+			"expiration_date":  send.ExpirationDate,
+			"deletion_date":    send.DeletionDate,
+			"disabled":         send.Disabled,
+			"hide_email":       send.HideEmail,
+		}).
+		Where(squirrel.Eq{"uuid": send.Uuid}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, ss.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (ss sendStore) Access(ctx context.Context, uuid string) error {
+	sqls, args, err := squirrel.Update("sends").
+		Set("access_count", squirrel.Expr("access_count + 1")).
+		Set("revision_date", time.Now()).
+		Where(squirrel.Eq{"uuid": uuid}).
+		Where(squirrel.Or{
+			squirrel.Eq{"max_access_count": nil},
+			squirrel.Expr("access_count < max_access_count"),
+		}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	result, err := conn(ctx, ss.db).ExecContext(ctx, sqls, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return model.ErrSendMaxAccess
+	}
+
+	return nil
+}
+
+func (ss sendStore) Delete(ctx context.Context, uuid string) error {
+	_, err := conn(ctx, ss.db).ExecContext(ctx, "DELETE FROM sends WHERE uuid = ?", uuid)
+	return err
+}
+
+func (ss sendStore) DeleteAllByUser(ctx context.Context, userUuid string) error {
+	_, err := conn(ctx, ss.db).ExecContext(ctx, "DELETE FROM sends WHERE user_uuid = ?", userUuid)
+	return err
+}
+
+func (ss sendStore) SpendToken(ctx context.Context, id string, expires time.Time) (bool, error) {
+	// the primary key lets only the first use of the token in
+	sqls, args, err := squirrel.Insert("send_download_tokens").Options("IGNORE").
+		Columns("uuid", "expiration_date").
+		Values(id, expires).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	result, err := conn(ctx, ss.db).ExecContext(ctx, sqls, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func (ss sendStore) DeleteTokensExpiredBefore(ctx context.Context, before time.Time) error {
+	_, err := conn(ctx, ss.db).ExecContext(ctx, "DELETE FROM send_download_tokens WHERE expiration_date < ?", before)
+	return err
+}
+
+func (sendStore) fields() []string {
+	return []string{
+		"uuid",
+		"user_uuid",
+		"organization_uuid",
+		"name",
+		"notes",
+		"atype",
+		"data",
+		"akey",
+		"password_hash",
+		"password_salt",
+		"password_iter",
+		"max_access_count",
+		"access_count",
+		"creation_date",
+		"revision_date",
+		"expiration_date",
+		"deletion_date",
+		"disabled",
+		"hide_email",
+	}
+}
+
+func (sendStore) scan(rows scanner) (*model.Send, error) {
+	var send model.Send
+	err := rows.Scan(
+		&send.Uuid,
+		&send.UserUuid,
+		&send.OrganizationUuid,
+		&send.Name,
+		&send.Notes,
+		&send.Atype,
+		&send.Data,
+		&send.Akey,
+		&send.PasswordHash,
+		&send.PasswordSalt,
+		&send.PasswordIter,
+		&send.MaxAccessCount,
+		&send.AccessCount,
+		&send.CreationDate,
+		&send.RevisionDate,
+		&send.ExpirationDate,
+		&send.DeletionDate,
+		&send.Disabled,
+		&send.HideEmail,
+	)
+
+	return &send, err
+}
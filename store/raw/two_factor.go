@@ -1,37 +1,85 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/togls/gowarden/store"
-)
-
-type tfStore struct {
-	db *sql.DB
-}
-
-var _ store.TwoFactor = (*tfStore)(nil)
-
-func NewTwoFactorStore(db *sql.DB) store.TwoFactor {
-	return &tfStore{db}
-}
-
-func (tfs tfStore) DeleteAllByUser(user string) error {
-	_, err := tfs.db.Exec("DELETE FROM two_factor WHERE user_uuid = ?", user)
-	return err
-}
-
-type tfiStore struct {
-	db *sql.DB
-}
-
-var _ store.TwoFactorIncomplete = (*tfiStore)(nil)
-
-func NewTwoFactorIncompleteStore(db *sql.DB) store.TwoFactorIncomplete {
-	return &tfiStore{db: db}
-}
-
-func (tfis tfiStore) DeleteAllByUser(user string) error {
-	_, err := tfis.db.Exec("DELETE FROM two_factor_incomplete WHERE user_uuid = ?", user)
-	return err
-}
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type tfStore struct {
+	db *DB
+}
+
+var _ store.TwoFactor = (*tfStore)(nil)
+
+func NewTwoFactorStore(db *DB) store.TwoFactor {
+	return &tfStore{db}
+}
+
+// FindByUser returns the two step login methods of the user, leaving out the
+// challenges stored in the same table.
+func (tfs tfStore) FindByUser(ctx context.Context, user string) ([]*model.TwoFactor, error) {
+	sqls, args, err := squirrel.Select(
+		"uuid",
+		"user_uuid",
+		"atype",
+		"enabled",
+		"data",
+		"last_used",
+	).From("twofactor").
+		Where(squirrel.Eq{"user_uuid": user}).
+		Where(squirrel.Lt{"atype": model.TwoFactorChallengeTypes}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, tfs.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.TwoFactor
+	for rows.Next() {
+		var tf model.TwoFactor
+		err := rows.Scan(
+			&tf.Uuid,
+			&tf.UserUuid,
+			&tf.Atype,
+			&tf.Enabled,
+			&tf.Data,
+			&tf.LastUsed,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, &tf)
+	}
+
+	return list, rows.Err()
+}
+
+func (tfs tfStore) DeleteAllByUser(ctx context.Context, user string) error {
+	_, err := conn(ctx, tfs.db).ExecContext(ctx, "DELETE FROM twofactor WHERE user_uuid = ?", user)
+	return err
+}
+
+type tfiStore struct {
+	db *DB
+}
+
+var _ store.TwoFactorIncomplete = (*tfiStore)(nil)
+
+func NewTwoFactorIncompleteStore(db *DB) store.TwoFactorIncomplete {
+	return &tfiStore{db: db}
+}
+
+func (tfis tfiStore) DeleteAllByUser(ctx context.Context, user string) error {
+	_, err := conn(ctx, tfis.db).ExecContext(ctx, "DELETE FROM twofactor_incomplete WHERE user_uuid = ?", user)
+	return err
+}
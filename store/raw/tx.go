@@ -0,0 +1,148 @@
+package raw
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/config"
+	"github.com/togls/gowarden/store"
+)
+
+type txKey struct{}
+
+// DB is the database of the stores, every statement run on it gets the
+// timeout as its deadline.
+type DB struct {
+	*sql.DB
+
+	timeout time.Duration
+}
+
+func NewDB(db *sql.DB, cfg *config.Core) *DB {
+	return &DB{
+		DB:      db,
+		timeout: time.Duration(cfg.DatabaseTimeout) * time.Second,
+	}
+}
+
+// conn returns the transaction carried by ctx, or db outside of one.
+func conn(ctx context.Context, db *DB) querier {
+	var c squirrel.StdSqlCtx = db.DB
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		c = tx
+	}
+
+	return querier{c: c, timeout: db.timeout}
+}
+
+// querier runs every statement under its own deadline, zero leaves them to
+// the context of the caller.
+type querier struct {
+	c       squirrel.StdSqlCtx
+	timeout time.Duration
+}
+
+func (q querier) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if q.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, q.timeout)
+}
+
+func (q querier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, cancel := q.context(ctx)
+	defer cancel()
+
+	return q.c.ExecContext(ctx, query, args...)
+}
+
+// QueryContext bounds the query and the reading of its rows, the deadline
+// is released when the rows are closed.
+func (q querier) QueryContext(ctx context.Context, query string, args ...any) (*rows, error) {
+	ctx, cancel := q.context(ctx)
+
+	r, err := q.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &rows{Rows: r, cancel: cancel}, nil
+}
+
+// QueryRowContext bounds the query, the deadline is released once the row
+// is scanned.
+func (q querier) QueryRowContext(ctx context.Context, query string, args ...any) *row {
+	ctx, cancel := q.context(ctx)
+
+	return &row{Row: q.c.QueryRowContext(ctx, query, args...), cancel: cancel}
+}
+
+type rows struct {
+	*sql.Rows
+
+	cancel context.CancelFunc
+}
+
+func (r *rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+type row struct {
+	*sql.Row
+
+	cancel context.CancelFunc
+}
+
+func (r *row) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// scanner is the row a scan function reads from.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type txStore struct {
+	db *sql.DB
+}
+
+var _ store.Tx = (*txStore)(nil)
+
+func NewTxStore(db *sql.DB) store.Tx {
+	return &txStore{db: db}
+}
+
+func (ts txStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := ts.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
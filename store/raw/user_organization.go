@@ -1,249 +1,226 @@
-package raw
-
-import (
-	"database/sql"
-
-	"github.com/Masterminds/squirrel"
-
-	"github.com/togls/gowarden/model"
-	"github.com/togls/gowarden/store"
-)
-
-type uoStore struct {
-	db *sql.DB
-}
-
-var _ store.UserOrganization = (*uoStore)(nil)
-
-func NewUserOrganizationStore(db *sql.DB) store.UserOrganization {
-	return &uoStore{db: db}
-}
-
-func (uos uoStore) Find(filter *model.UOFilter) ([]*model.UserOrganization, error) {
-	fields := []string{
-		"uo.uuid",
-		"uo.user_uuid",
-		"uo.org_uuid",
-		"uo.access_all",
-		"uo.akey",
-		"uo.status",
-		"uo.atype",
-		"o.name",
-		"o.private_key",
-		"o.public_key",
-	}
-
-	builder := squirrel.Select(fields...).From("users_organizations AS uo").
-		LeftJoin("organizations AS o ON uo.org_uuid = o.uuid")
-
-	if filter.UserUuid != nil {
-		builder = builder.Where(squirrel.Eq{"user_uuid": *filter.UserUuid})
-	}
-
-	if filter.OrgUuid != nil {
-		builder = builder.Where(squirrel.Eq{"org_uuid": *filter.OrgUuid})
-	}
-
-	if filter.Status != nil {
-		builder = builder.Where(squirrel.Eq{"status": *filter.Status})
-	}
-
-	if filter.Atype != nil {
-		builder = builder.Where(squirrel.Eq{"atype": *filter.Atype})
-	}
-
-	sqls, args, err := builder.ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := uos.db.Query(sqls, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	var list []*model.UserOrganization
-	for rows.Next() {
-		var item model.UserOrganization
-		err = rows.Scan(
-			&item.Uuid,
-			&item.UserUuid,
-			&item.OrgUuid,
-			&item.AccessAll,
-			&item.AKey,
-			&item.Status,
-			&item.Atype,
-			&item.Name,
-			&item.PrivateKey,
-			&item.PublicKey,
-		)
-
-		list = append(list, &item)
-	}
-
-	return list, nil
-}
-
-func (uos uoStore) FindByUuid(uuid string) (*model.UserOrganization, error) {
-	fields := []string{
-		"uo.uuid",
-		"uo.user_uuid",
-		"uo.org_uuid",
-		"uo.access_all",
-		"uo.akey",
-		"uo.status",
-		"uo.atype",
-		"o.name",
-		"o.private_key",
-		"o.public_key",
-	}
-	sqls, args, err := squirrel.Select(fields...).From("users_organizations").
-		LeftJoin("organizations AS o ON uo.org_uuid = o.uuid").
-		Where(squirrel.Eq{"uuid": uuid}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := uos.db.Query(sqls, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	if !rows.Next() {
-		return nil, model.ErrNotFound
-	}
-
-	var item model.UserOrganization
-	err = rows.Scan(
-		&item.Uuid,
-		&item.UserUuid,
-		&item.OrgUuid,
-		&item.AccessAll,
-		&item.AKey,
-		&item.Status,
-		&item.Atype,
-		&item.Name,
-		&item.PrivateKey,
-		&item.PublicKey,
-	)
-	return &item, err
-}
-
-func (uos uoStore) FindByUserAndOrg(user string, org string) (*model.UserOrganization, error) {
-	fields := []string{
-		"uo.uuid",
-		"uo.user_uuid",
-		"uo.org_uuid",
-		"uo.access_all",
-		"uo.akey",
-		"uo.status",
-		"uo.atype",
-		"o.name",
-		"o.private_key",
-		"o.public_key",
-	}
-	sqls, args, err := squirrel.Select(fields...).From("users_organizations AS uo").
-		LeftJoin("organizations AS o ON uo.org_uuid = o.uuid").
-		Where(squirrel.Eq{"user_uuid": user, "org_uuid": org}).ToSql()
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := uos.db.Query(sqls, args...)
-	if err != nil {
-		return nil, err
-	}
-
-	if !rows.Next() {
-		return nil, model.ErrNotFound
-	}
-
-	var item model.UserOrganization
-	err = rows.Scan(
-		&item.Uuid,
-		&item.UserUuid,
-		&item.OrgUuid,
-		&item.AccessAll,
-		&item.AKey,
-		&item.Status,
-		&item.Atype,
-		&item.Name,
-		&item.PrivateKey,
-		&item.PublicKey,
-	)
-	return &item, err
-}
-
-func (uos uoStore) Create(uo *model.UserOrganization) error {
-	sqls, args, err := squirrel.Insert("users_organizations").
-		Columns(uos.fields()...).
-		Values(
-			uo.Uuid,
-			uo.UserUuid,
-			uo.OrgUuid,
-			uo.AccessAll,
-			uo.AKey,
-			uo.Status,
-			uo.Atype,
-		).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = uos.db.Exec(sqls, args...)
-	return err
-}
-
-func (uos uoStore) Save(uo *model.UserOrganization) error {
-	sqls, args, err := squirrel.Replace("users_organizations").
-		Columns(uos.fields()...).
-		Values(
-			uo.Uuid,
-			uo.UserUuid,
-			uo.OrgUuid,
-			uo.AccessAll,
-			uo.AKey,
-			uo.Status,
-			uo.Atype,
-		).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = uos.db.Exec(sqls, args...)
-	return err
-}
-
-func (uos uoStore) Delete(uuid string) error {
-	sqls, args, err := squirrel.Delete("users_organizations").
-		Where(squirrel.Eq{"uuid": uuid}).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = uos.db.Exec(sqls, args...)
-	return err
-}
-
-func (uos uoStore) DeleteAllByUser(user string) error {
-	sqls, args, err := squirrel.Delete("users_organizations").
-		Where(squirrel.Eq{"user_uuid": user}).ToSql()
-	if err != nil {
-		return err
-	}
-
-	_, err = uos.db.Exec(sqls, args...)
-	return err
-}
-
-func (uos uoStore) fields() []string {
-	return []string{
-		"uuid",
-		"user_uuid",
-		"org_uuid",
-		"access_all",
-		"akey",
-		"status",
-		"atype",
-	}
-}
+package raw
+
+import (
+	"context"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/togls/gowarden/model"
+	"github.com/togls/gowarden/store"
+)
+
+type uoStore struct {
+	db *DB
+}
+
+var _ store.UserOrganization = (*uoStore)(nil)
+
+func NewUserOrganizationStore(db *DB) store.UserOrganization {
+	return &uoStore{db: db}
+}
+
+func (uos uoStore) Find(ctx context.Context, filter *model.UOFilter) ([]*model.UserOrganization, error) {
+	builder := uos.selectBuilder()
+
+	if filter.UserUuid != nil {
+		builder = builder.Where(squirrel.Eq{"uo.user_uuid": *filter.UserUuid})
+	}
+
+	if filter.OrgUuid != nil {
+		builder = builder.Where(squirrel.Eq{"uo.org_uuid": *filter.OrgUuid})
+	}
+
+	if filter.Status != nil {
+		builder = builder.Where(squirrel.Eq{"uo.status": *filter.Status})
+	}
+
+	if filter.Atype != nil {
+		builder = builder.Where(squirrel.Eq{"uo.atype": *filter.Atype})
+	}
+
+	sqls, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn(ctx, uos.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*model.UserOrganization
+	for rows.Next() {
+		item, err := uos.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, item)
+	}
+
+	return list, rows.Err()
+}
+
+func (uos uoStore) FindByUuid(ctx context.Context, uuid string) (*model.UserOrganization, error) {
+	sqls, args, err := uos.selectBuilder().
+		Where(squirrel.Eq{"uo.uuid": uuid}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return uos.findOne(ctx, sqls, args...)
+}
+
+func (uos uoStore) FindByUserAndOrg(ctx context.Context, user string, org string) (*model.UserOrganization, error) {
+	sqls, args, err := uos.selectBuilder().
+		Where(squirrel.Eq{"uo.user_uuid": user, "uo.org_uuid": org}).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	return uos.findOne(ctx, sqls, args...)
+}
+
+func (uos uoStore) Create(ctx context.Context, uo *model.UserOrganization) error {
+	sqls, args, err := squirrel.Insert("users_organizations").
+		Columns(uos.fields()...).
+		Values(
+			uo.Uuid,
+			uo.UserUuid,
+			uo.OrgUuid,
+			uo.AccessAll,
+			uo.AKey,
+			uo.Status,
+			uo.Atype,
+			uo.ResetPasswordKey,
+			uo.ExternalId,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, uos.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (uos uoStore) Save(ctx context.Context, uo *model.UserOrganization) error {
+	sqls, args, err := squirrel.Replace("users_organizations").
+		Columns(uos.fields()...).
+		Values(
+			uo.Uuid,
+			uo.UserUuid,
+			uo.OrgUuid,
+			uo.AccessAll,
+			uo.AKey,
+			uo.Status,
+			uo.Atype,
+			uo.ResetPasswordKey,
+			uo.ExternalId,
+		).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, uos.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (uos uoStore) Delete(ctx context.Context, uuid string) error {
+	sqls, args, err := squirrel.Delete("users_organizations").
+		Where(squirrel.Eq{"uuid": uuid}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, uos.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (uos uoStore) DeleteAllByUser(ctx context.Context, user string) error {
+	sqls, args, err := squirrel.Delete("users_organizations").
+		Where(squirrel.Eq{"user_uuid": user}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = conn(ctx, uos.db).ExecContext(ctx, sqls, args...)
+	return err
+}
+
+func (uos uoStore) fields() []string {
+	return []string{
+		"uuid",
+		"user_uuid",
+		"org_uuid",
+		"access_all",
+		"akey",
+		"status",
+		"atype",
+		"reset_password_key",
+		"external_id",
+	}
+}
+
+// selectBuilder selects the memberships along with the name, keys and
+// limits of their organization.
+func (uos uoStore) selectBuilder() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"uo.uuid",
+		"uo.user_uuid",
+		"uo.org_uuid",
+		"uo.access_all",
+		"uo.akey",
+		"uo.status",
+		"uo.atype",
+		"uo.reset_password_key",
+		"uo.external_id",
+		"o.name",
+		"o.private_key",
+		"o.public_key",
+		"o.max_seats",
+		"o.max_collections",
+		"o.max_storage_gb",
+	).From("users_organizations AS uo").
+		LeftJoin("organizations AS o ON uo.org_uuid = o.uuid")
+}
+
+func (uos uoStore) findOne(ctx context.Context, sqls string, args ...any) (*model.UserOrganization, error) {
+	rows, err := conn(ctx, uos.db).QueryContext(ctx, sqls, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, model.ErrNotFound
+	}
+
+	return uos.scan(rows)
+}
+
+func (uoStore) scan(rows scanner) (*model.UserOrganization, error) {
+	var item model.UserOrganization
+	err := rows.Scan(
+		&item.Uuid,
+		&item.UserUuid,
+		&item.OrgUuid,
+		&item.AccessAll,
+		&item.AKey,
+		&item.Status,
+		&item.Atype,
+		&item.ResetPasswordKey,
+		&item.ExternalId,
+		&item.Name,
+		&item.PrivateKey,
+		&item.PublicKey,
+		&item.Limits.MaxSeats,
+		&item.Limits.MaxCollections,
+		&item.Limits.MaxStorageGb,
+	)
+
+	return &item, err
+}
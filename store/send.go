@@ -1,8 +1,30 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type Send interface {
-	Find(*model.SendFilter) ([]*model.Send, error)
-	DeleteAllByUser(userUuid string) error
-}
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/togls/gowarden/model"
+)
+
+type Send interface {
+	Find(ctx context.Context, filter *model.SendFilter) ([]*model.Send, error)
+	FindByUuid(ctx context.Context, uuid string) (*model.Send, error)
+
+	Create(ctx context.Context, send *model.Send) error
+	Save(ctx context.Context, send *model.Send) error
+
+	// Access counts one access of the send, it fails with
+	// model.ErrSendMaxAccess once the send has been accessed too often.
+	Access(ctx context.Context, uuid string) error
+
+	Delete(ctx context.Context, uuid string) error
+	DeleteAllByUser(ctx context.Context, userUuid string) error
+
+	// SpendToken records the download token id as used, it reports false
+	// when it already was.
+	SpendToken(ctx context.Context, id string, expires time.Time) (bool, error)
+	// DeleteTokensExpiredBefore forgets the tokens that can't be used
+	// anymore.
+	DeleteTokensExpiredBefore(ctx context.Context, before time.Time) error
+}
@@ -1,9 +1,16 @@
-package store
-
-type TwoFactor interface {
-	DeleteAllByUser(user string) error
-}
-
-type TwoFactorIncomplete interface {
-	DeleteAllByUser(user string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type TwoFactor interface {
+	FindByUser(ctx context.Context, user string) ([]*model.TwoFactor, error)
+	DeleteAllByUser(ctx context.Context, user string) error
+}
+
+type TwoFactorIncomplete interface {
+	DeleteAllByUser(ctx context.Context, user string) error
+}
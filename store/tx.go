@@ -0,0 +1,10 @@
+package store
+
+import "context"
+
+type Tx interface {
+	// WithTx runs fn in a database transaction carried by its context, the
+	// transaction is rolled back when fn returns an error. Nested calls join
+	// the outer transaction.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
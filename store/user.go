@@ -1,12 +1,16 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type User interface {
-	Create(user *model.User) error
-	Update(user *model.UpdateUser) error
-	UpdateRevision(uuid string) error
-
-	FindByEmail(string) (*model.User, error)
-	FindByUuid(string) (*model.User, error)
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type User interface {
+	Create(ctx context.Context, user *model.User) error
+	Update(ctx context.Context, user *model.UpdateUser) error
+	UpdateRevision(ctx context.Context, uuid string) error
+
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByUuid(ctx context.Context, uuid string) (*model.User, error)
+}
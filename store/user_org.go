@@ -1,14 +1,18 @@
-package store
-
-import "github.com/togls/gowarden/model"
-
-type UserOrganization interface {
-	Find(*model.UOFilter) ([]*model.UserOrganization, error)
-	FindByUuid(uuid string) (*model.UserOrganization, error)
-	FindByUserAndOrg(user, org string) (*model.UserOrganization, error)
-
-	Create(uo *model.UserOrganization) error
-	Save(uo *model.UserOrganization) error
-	Delete(uuid string) error
-	DeleteAllByUser(user string) error
-}
+package store
+
+import (
+	"context"
+
+	"github.com/togls/gowarden/model"
+)
+
+type UserOrganization interface {
+	Find(ctx context.Context, filter *model.UOFilter) ([]*model.UserOrganization, error)
+	FindByUuid(ctx context.Context, uuid string) (*model.UserOrganization, error)
+	FindByUserAndOrg(ctx context.Context, user, org string) (*model.UserOrganization, error)
+
+	Create(ctx context.Context, uo *model.UserOrganization) error
+	Save(ctx context.Context, uo *model.UserOrganization) error
+	Delete(ctx context.Context, uuid string) error
+	DeleteAllByUser(ctx context.Context, user string) error
+}